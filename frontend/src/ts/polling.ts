@@ -89,9 +89,11 @@ class PollingController {
             if (this.lastPollTime !== null) {
                 elapsed -= this.lastPollTime;
             }
-            progress = Math.min(elapsed / this.pollInterval, 1);
+            const duration =
+                this.currentProgressSource.duration ?? this.pollInterval;
+            progress = Math.min(elapsed / duration, 1);
 
-            if (elapsed >= this.pollInterval) {
+            if (elapsed >= duration) {
                 this.triggerNewAsset();
                 return;
             }
@@ -122,6 +124,19 @@ class PollingController {
         this.triggerNewAsset();
     };
 
+    /**
+     * Returns how long the current asset is shown for. Views such as stories
+     * set their own duration, in seconds, with a data-asset-duration attribute
+     */
+    private assetInterval = (): number => {
+        const element = this.kioskElement?.querySelector<HTMLElement>(
+            "[data-asset-duration]",
+        );
+        const seconds = Number(element?.dataset.assetDuration);
+
+        return seconds > 0 ? seconds * 1000 : this.pollInterval;
+    };
+
     /**
      * Starts the polling process
      */
@@ -134,7 +149,7 @@ class PollingController {
         this.currentProgressSource = {
             type: "image",
             startTime: this.lastPollTime,
-            duration: this.pollInterval,
+            duration: this.assetInterval(),
         };
 
         this.animationFrameId = requestAnimationFrame(this.updateProgress);
@@ -187,7 +202,7 @@ class PollingController {
             this.currentProgressSource = {
                 type: "image",
                 startTime: performance.now(),
-                duration: this.pollInterval,
+                duration: this.assetInterval(),
             };
         }
 
@@ -0,0 +1,124 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/damongolding/immich-kiosk/internal/kiosk"
+)
+
+// BackendType is the kind of store cached items are kept in.
+type BackendType string
+
+const (
+	// BackendMemory keeps items in memory with no size limit
+	BackendMemory = BackendType(kiosk.CacheBackendMemory)
+	// BackendLRU keeps items in memory, removing the least recently used once MaxMemory is reached
+	BackendLRU = BackendType(kiosk.CacheBackendLRU)
+	// BackendDisk keeps Immich API responses in an on-disk store and everything else in a bounded LRU
+	BackendDisk = BackendType(kiosk.CacheBackendDisk)
+
+	// DefaultMaxMemory how many bytes of items the lru backend keeps
+	DefaultMaxMemory int64 = 256 << 20
+
+	// DefaultPath the file the disk backend keeps items in
+	DefaultPath = "./kiosk-data/cache.db"
+)
+
+const (
+	// NoExpiration items set with this duration never expire
+	NoExpiration time.Duration = -1
+	// DefaultExpiration items set with this duration use the cache's default expiration
+	DefaultExpiration time.Duration = 0
+)
+
+// ItemInfo describes a cached item without its value.
+type ItemInfo struct {
+	Key string
+	// Size the estimated size of the item in bytes, recorded when it was set
+	Size int64
+	// Expiration when the item expires, zero if it never does
+	Expiration time.Time
+}
+
+// ErrNotFound is returned when replacing an item that is not in the cache.
+var ErrNotFound = errors.New("item not found in cache")
+
+// Cache is a store for cached items. Implementations must be safe for concurrent use.
+type Cache interface {
+	// Get returns an item and whether it was found. Expired items are not found.
+	Get(key string) (any, bool)
+	// GetWithExpiration returns an item, when it expires and whether it was found.
+	// The expiration is zero for items that never expire.
+	GetWithExpiration(key string) (any, time.Time, bool)
+	// Set stores an item, replacing any existing item, for d.
+	Set(key string, value any, d time.Duration)
+	// Replace stores an item only if it already exists, returning ErrNotFound otherwise.
+	Replace(key string, value any, d time.Duration) error
+	// Delete removes an item.
+	Delete(key string)
+	// Flush removes every item.
+	Flush()
+	// ItemCount returns the number of items, including any that have expired but not
+	// yet been removed.
+	ItemCount() int
+	// Keys returns the keys of every item, including any that have expired but not yet
+	// been removed.
+	Keys() []string
+	// Items describes every unexpired item. Describing an item does not count as using it.
+	Items() []ItemInfo
+	// Close stops any background work and releases resources held by the cache.
+	Close() error
+}
+
+// newBackend creates the cache backend of the given type.
+func newBackend(backendType BackendType, expiration, cleanup time.Duration, maxMemory int64, path string) (Cache, error) {
+	switch backendType {
+	case BackendMemory:
+		return newMemoryCache(expiration, cleanup), nil
+	case BackendLRU:
+		return newLRUCache(expiration, cleanup, maxMemory), nil
+	case BackendDisk:
+		return newDiskCache(expiration, cleanup, maxMemory, path)
+	default:
+		return nil, fmt.Errorf("unknown cache backend: %s", backendType)
+	}
+}
+
+// expiresAt returns when an item set for d expires, the zero time if it never does.
+func expiresAt(d, defaultExpiration time.Duration) time.Time {
+	if d == DefaultExpiration {
+		d = defaultExpiration
+	}
+
+	if d <= 0 {
+		return time.Time{}
+	}
+
+	return time.Now().Add(d)
+}
+
+// expired reports whether an item expiring at expiration has expired.
+func expired(expiration time.Time) bool {
+	return !expiration.IsZero() && time.Now().After(expiration)
+}
+
+// janitor calls cleanup every interval until stop is closed.
+func janitor(interval time.Duration, cleanup func(), stop <-chan struct{}) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			cleanup()
+		}
+	}
+}
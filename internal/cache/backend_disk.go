@@ -0,0 +1,299 @@
+package cache
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"charm.land/log/v2"
+	bolt "go.etcd.io/bbolt"
+)
+
+// diskBucket the bbolt bucket items are kept in
+var diskBucket = []byte("cache")
+
+// diskCache keeps byte items, the Immich API responses, in an embedded on-disk store so
+// they survive restarts. Other items hold values that only make sense in the running
+// process, such as decoded images, and are kept in a bounded in-memory LRU.
+//
+// Each stored item is its expiration, as Unix nanoseconds or 0 if it never expires,
+// followed by its value.
+type diskCache struct {
+	db                *bolt.DB
+	memory            *lruCache
+	defaultExpiration time.Duration
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// newDiskCache opens, or creates, the on-disk store at path. A path of "" uses DefaultPath.
+// Items that are not bytes are kept in memory, up to maxMemory bytes.
+func newDiskCache(expiration, cleanup time.Duration, maxMemory int64, path string) (*diskCache, error) {
+	if path == "" {
+		path = DefaultPath
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("creating cache directory: %w", err)
+	}
+
+	db, err := bolt.Open(path, 0o644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("opening cache: %w", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, bucketErr := tx.CreateBucketIfNotExists(diskBucket)
+		return bucketErr
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("creating cache bucket: %w", err)
+	}
+
+	d := &diskCache{
+		db:                db,
+		memory:            newLRUCache(expiration, cleanup, maxMemory),
+		defaultExpiration: expiration,
+		stop:              make(chan struct{}),
+	}
+
+	d.deleteExpired()
+	go janitor(cleanup, d.deleteExpired, d.stop)
+
+	return d, nil
+}
+
+func (d *diskCache) Get(key string) (any, bool) {
+	value, _, found := d.GetWithExpiration(key)
+	return value, found
+}
+
+func (d *diskCache) GetWithExpiration(key string) (any, time.Time, bool) {
+	if value, expiration, found := d.memory.GetWithExpiration(key); found {
+		return value, expiration, true
+	}
+
+	var value []byte
+	var expiration time.Time
+
+	err := d.db.View(func(tx *bolt.Tx) error {
+		stored := tx.Bucket(diskBucket).Get([]byte(key))
+		if stored == nil {
+			return nil
+		}
+
+		var valid bool
+		value, expiration, valid = decodeDiskItem(stored)
+		if !valid {
+			value = nil
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Error("Reading cache", "key", key, "err", err)
+		return nil, time.Time{}, false
+	}
+
+	if value == nil {
+		return nil, time.Time{}, false
+	}
+
+	if expired(expiration) {
+		d.deleteFromDisk(key)
+		return nil, time.Time{}, false
+	}
+
+	return value, expiration, true
+}
+
+func (d *diskCache) Set(key string, value any, duration time.Duration) {
+	data, isBytes := value.([]byte)
+	if !isBytes {
+		d.deleteFromDisk(key)
+		d.memory.Set(key, value, duration)
+		return
+	}
+
+	d.memory.Delete(key)
+
+	err := d.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(diskBucket).Put([]byte(key), encodeDiskItem(data, expiresAt(duration, d.defaultExpiration)))
+	})
+	if err != nil {
+		log.Error("Writing cache", "key", key, "err", err)
+	}
+}
+
+func (d *diskCache) Replace(key string, value any, duration time.Duration) error {
+	if _, found := d.Get(key); !found {
+		return ErrNotFound
+	}
+
+	d.Set(key, value, duration)
+	return nil
+}
+
+func (d *diskCache) Delete(key string) {
+	d.memory.Delete(key)
+	d.deleteFromDisk(key)
+}
+
+func (d *diskCache) Flush() {
+	d.memory.Flush()
+
+	err := d.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(diskBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucket(diskBucket)
+		return err
+	})
+	if err != nil {
+		log.Error("Flushing cache", "err", err)
+	}
+}
+
+func (d *diskCache) ItemCount() int {
+	count := d.memory.ItemCount()
+
+	_ = d.db.View(func(tx *bolt.Tx) error {
+		count += tx.Bucket(diskBucket).Stats().KeyN
+		return nil
+	})
+
+	return count
+}
+
+func (d *diskCache) Keys() []string {
+	keys := d.memory.Keys()
+
+	_ = d.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(diskBucket).ForEach(func(k, _ []byte) error {
+			keys = append(keys, string(k))
+			return nil
+		})
+	})
+
+	return keys
+}
+
+func (d *diskCache) Items() []ItemInfo {
+	items := d.memory.Items()
+
+	_ = d.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(diskBucket).ForEach(func(k, v []byte) error {
+			expiration, valid := diskItemExpiration(v)
+			if !valid || expired(expiration) {
+				return nil
+			}
+
+			items = append(items, ItemInfo{
+				Key:        string(k),
+				Size:       int64(len(v) - 8 + len(k)),
+				Expiration: expiration,
+			})
+			return nil
+		})
+	})
+
+	return items
+}
+
+// Close stops the janitors and closes the on-disk store. Items on disk are kept.
+func (d *diskCache) Close() error {
+	d.once.Do(func() {
+		close(d.stop)
+	})
+
+	if err := d.memory.Close(); err != nil {
+		return err
+	}
+
+	return d.db.Close()
+}
+
+// deleteFromDisk removes an item from the on-disk store.
+func (d *diskCache) deleteFromDisk(key string) {
+	err := d.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(diskBucket).Delete([]byte(key))
+	})
+	if err != nil {
+		log.Error("Deleting from cache", "key", key, "err", err)
+	}
+}
+
+// deleteExpired removes every expired or unreadable item from the on-disk store.
+func (d *diskCache) deleteExpired() {
+	err := d.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(diskBucket)
+
+		var keys [][]byte
+		err := bucket.ForEach(func(k, v []byte) error {
+			if expiration, valid := diskItemExpiration(v); !valid || expired(expiration) {
+				keys = append(keys, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, k := range keys {
+			if err = bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Error("Removing expired cache items", "err", err)
+	}
+}
+
+// encodeDiskItem prefixes a value with its expiration.
+func encodeDiskItem(value []byte, expiration time.Time) []byte {
+	var nanos int64
+	if !expiration.IsZero() {
+		nanos = expiration.UnixNano()
+	}
+
+	stored := make([]byte, 8+len(value))
+	binary.BigEndian.PutUint64(stored, uint64(nanos))
+	copy(stored[8:], value)
+
+	return stored
+}
+
+// diskItemExpiration returns when a stored item expires and whether it could be read.
+func diskItemExpiration(stored []byte) (time.Time, bool) {
+	if len(stored) < 8 {
+		return time.Time{}, false
+	}
+
+	if nanos := int64(binary.BigEndian.Uint64(stored)); nanos != 0 {
+		return time.Unix(0, nanos), true
+	}
+
+	return time.Time{}, true
+}
+
+// decodeDiskItem splits a stored item into its value and expiration. The value is copied
+// as bbolt's memory is only valid during a transaction.
+func decodeDiskItem(stored []byte) ([]byte, time.Time, bool) {
+	expiration, valid := diskItemExpiration(stored)
+	if !valid {
+		return nil, time.Time{}, false
+	}
+
+	value := make([]byte, len(stored)-8)
+	copy(value, stored[8:])
+
+	return value, expiration, true
+}
@@ -0,0 +1,204 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// lruItem is an item in an lruCache.
+type lruItem struct {
+	key        string
+	value      any
+	expiration time.Time
+	size       int64
+}
+
+// lruCache keeps items in memory up to a total size, removing the least recently used
+// items once it is reached. Item sizes are estimated with sizeOf.
+type lruCache struct {
+	mu                sync.Mutex
+	items             map[string]*list.Element
+	recent            *list.List
+	size              int64
+	maxSize           int64
+	defaultExpiration time.Duration
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// newLRUCache creates an in-memory cache holding up to maxSize bytes of items.
+// A maxSize of 0 or less uses DefaultMaxMemory.
+func newLRUCache(expiration, cleanup time.Duration, maxSize int64) *lruCache {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxMemory
+	}
+
+	l := &lruCache{
+		items:             map[string]*list.Element{},
+		recent:            list.New(),
+		maxSize:           maxSize,
+		defaultExpiration: expiration,
+		stop:              make(chan struct{}),
+	}
+
+	go janitor(cleanup, l.deleteExpired, l.stop)
+
+	return l
+}
+
+func (l *lruCache) Get(key string) (any, bool) {
+	value, _, found := l.GetWithExpiration(key)
+	return value, found
+}
+
+func (l *lruCache) GetWithExpiration(key string) (any, time.Time, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	el, found := l.items[key]
+	if !found {
+		return nil, time.Time{}, false
+	}
+
+	item := el.Value.(*lruItem)
+	if expired(item.expiration) {
+		l.remove(el)
+		return nil, time.Time{}, false
+	}
+
+	l.recent.MoveToFront(el)
+
+	return item.value, item.expiration, true
+}
+
+func (l *lruCache) Set(key string, value any, d time.Duration) {
+	item := &lruItem{
+		key:        key,
+		value:      value,
+		expiration: expiresAt(d, l.defaultExpiration),
+		size:       sizeOf(value) + int64(len(key)),
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if el, found := l.items[key]; found {
+		l.remove(el)
+	}
+
+	l.items[key] = l.recent.PushFront(item)
+	l.size += item.size
+
+	l.evict()
+}
+
+func (l *lruCache) Replace(key string, value any, d time.Duration) error {
+	if _, found := l.Get(key); !found {
+		return ErrNotFound
+	}
+
+	l.Set(key, value, d)
+	return nil
+}
+
+func (l *lruCache) Delete(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if el, found := l.items[key]; found {
+		l.remove(el)
+	}
+}
+
+func (l *lruCache) Flush() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.items = map[string]*list.Element{}
+	l.recent.Init()
+	l.size = 0
+}
+
+func (l *lruCache) ItemCount() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return len(l.items)
+}
+
+func (l *lruCache) Keys() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	keys := make([]string, 0, len(l.items))
+	for key := range l.items {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+func (l *lruCache) Items() []ItemInfo {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	items := make([]ItemInfo, 0, len(l.items))
+	for key, el := range l.items {
+		item := el.Value.(*lruItem)
+		if expired(item.expiration) {
+			continue
+		}
+
+		items = append(items, ItemInfo{Key: key, Size: item.size, Expiration: item.expiration})
+	}
+
+	return items
+}
+
+// Close stops the janitor and flushes the cache.
+func (l *lruCache) Close() error {
+	l.once.Do(func() {
+		close(l.stop)
+	})
+	l.Flush()
+	return nil
+}
+
+// Size returns the estimated size in bytes of the items in the cache.
+func (l *lruCache) Size() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.size
+}
+
+// deleteExpired removes every expired item.
+func (l *lruCache) deleteExpired() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for el := l.recent.Front(); el != nil; {
+		next := el.Next()
+		if expired(el.Value.(*lruItem).expiration) {
+			l.remove(el)
+		}
+		el = next
+	}
+}
+
+// remove removes an item. Callers must hold mu.
+func (l *lruCache) remove(el *list.Element) {
+	item := l.recent.Remove(el).(*lruItem)
+	delete(l.items, item.key)
+	l.size -= item.size
+}
+
+// evict removes the least recently used items until the cache is within maxSize.
+// The most recently used item is always kept. Callers must hold mu.
+func (l *lruCache) evict() {
+	for l.size > l.maxSize && l.recent.Len() > 1 {
+		l.remove(l.recent.Back())
+	}
+}
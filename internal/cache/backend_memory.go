@@ -0,0 +1,112 @@
+package cache
+
+import (
+	"sync"
+	"time"
+
+	gocache "github.com/patrickmn/go-cache"
+)
+
+// memoryCache keeps items in memory with no size limit, using github.com/patrickmn/go-cache.
+// Item sizes are estimated with sizeOf when they are set.
+type memoryCache struct {
+	c *gocache.Cache
+
+	sizesMu sync.Mutex
+	sizes   map[string]int64
+}
+
+// newMemoryCache creates an unbounded in-memory cache.
+func newMemoryCache(expiration, cleanup time.Duration) *memoryCache {
+	m := &memoryCache{
+		c:     gocache.New(expiration, cleanup),
+		sizes: map[string]int64{},
+	}
+
+	m.c.OnEvicted(func(key string, _ any) {
+		m.sizesMu.Lock()
+		delete(m.sizes, key)
+		m.sizesMu.Unlock()
+	})
+
+	return m
+}
+
+func (m *memoryCache) Get(key string) (any, bool) {
+	return m.c.Get(key)
+}
+
+func (m *memoryCache) GetWithExpiration(key string) (any, time.Time, bool) {
+	return m.c.GetWithExpiration(key)
+}
+
+func (m *memoryCache) Set(key string, value any, d time.Duration) {
+	size := sizeOf(value) + int64(len(key))
+
+	m.sizesMu.Lock()
+	m.sizes[key] = size
+	m.sizesMu.Unlock()
+
+	m.c.Set(key, value, d)
+}
+
+func (m *memoryCache) Replace(key string, value any, d time.Duration) error {
+	if _, found := m.c.Get(key); !found {
+		return ErrNotFound
+	}
+
+	m.Set(key, value, d)
+	return nil
+}
+
+func (m *memoryCache) Delete(key string) {
+	m.c.Delete(key)
+}
+
+func (m *memoryCache) Flush() {
+	m.c.Flush()
+
+	m.sizesMu.Lock()
+	m.sizes = map[string]int64{}
+	m.sizesMu.Unlock()
+}
+
+func (m *memoryCache) ItemCount() int {
+	return m.c.ItemCount()
+}
+
+func (m *memoryCache) Keys() []string {
+	items := m.c.Items()
+
+	keys := make([]string, 0, len(items))
+	for key := range items {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+func (m *memoryCache) Items() []ItemInfo {
+	cached := m.c.Items()
+
+	m.sizesMu.Lock()
+	defer m.sizesMu.Unlock()
+
+	items := make([]ItemInfo, 0, len(cached))
+	for key, item := range cached {
+		info := ItemInfo{Key: key, Size: m.sizes[key]}
+		if item.Expiration > 0 {
+			info.Expiration = time.Unix(0, item.Expiration)
+		}
+
+		items = append(items, info)
+	}
+
+	return items
+}
+
+// Close flushes the cache. go-cache's janitor stops once the cache is garbage collected.
+func (m *memoryCache) Close() error {
+	m.Flush()
+	return nil
+}
@@ -0,0 +1,133 @@
+package cache
+
+import (
+	"errors"
+	"image"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackends(t *testing.T) {
+	for _, backendType := range []BackendType{BackendMemory, BackendLRU, BackendDisk} {
+		t.Run(string(backendType), func(t *testing.T) {
+			backend, err := newBackend(backendType, time.Minute, 0, DefaultMaxMemory, filepath.Join(t.TempDir(), "cache.db"))
+			require.NoError(t, err)
+			t.Cleanup(func() {
+				require.NoError(t, backend.Close())
+			})
+
+			backend.Set("bytes", []byte("response"), DefaultExpiration)
+			backend.Set("value", []string{"a", "b"}, time.Hour)
+
+			value, expiration, found := backend.GetWithExpiration("bytes")
+			require.True(t, found)
+			assert.Equal(t, []byte("response"), value)
+			assert.WithinDuration(t, time.Now().Add(time.Minute), expiration, time.Second)
+
+			value, found = backend.Get("value")
+			require.True(t, found)
+			assert.Equal(t, []string{"a", "b"}, value)
+			assert.Equal(t, 2, backend.ItemCount())
+
+			items := backend.Items()
+			require.Len(t, items, 2)
+			for _, item := range items {
+				assert.Positive(t, item.Size, item.Key)
+			}
+
+			require.NoError(t, backend.Replace("bytes", []byte("replaced"), DefaultExpiration))
+			value, _ = backend.Get("bytes")
+			assert.Equal(t, []byte("replaced"), value)
+			assert.True(t, errors.Is(backend.Replace("missing", 1, DefaultExpiration), ErrNotFound))
+
+			backend.Delete("value")
+			_, found = backend.Get("value")
+			assert.False(t, found)
+
+			backend.Set("expired", []byte("old"), time.Nanosecond)
+			time.Sleep(time.Millisecond)
+			_, found = backend.Get("expired")
+			assert.False(t, found)
+
+			backend.Flush()
+			assert.Equal(t, 0, backend.ItemCount())
+		})
+	}
+}
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	l := newLRUCache(time.Minute, 0, 30)
+	t.Cleanup(func() {
+		_ = l.Close()
+	})
+
+	l.Set("a", []byte("0123456789"), DefaultExpiration)
+	l.Set("b", []byte("0123456789"), DefaultExpiration)
+
+	// using a makes b the least recently used
+	_, found := l.Get("a")
+	require.True(t, found)
+
+	l.Set("c", []byte("0123456789"), DefaultExpiration)
+
+	_, found = l.Get("b")
+	assert.False(t, found)
+
+	for _, key := range []string{"a", "c"} {
+		_, found = l.Get(key)
+		assert.True(t, found)
+	}
+
+	assert.LessOrEqual(t, l.Size(), int64(30))
+}
+
+func TestDiskCacheSurvivesReopening(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.db")
+
+	d, err := newDiskCache(time.Minute, 0, DefaultMaxMemory, path)
+	require.NoError(t, err)
+
+	d.Set("api", []byte("response"), time.Hour)
+	d.Set("view", []string{"in memory"}, time.Hour)
+	require.NoError(t, d.Close())
+
+	d, err = newDiskCache(time.Minute, 0, DefaultMaxMemory, path)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = d.Close()
+	})
+
+	value, found := d.Get("api")
+	require.True(t, found)
+	assert.Equal(t, []byte("response"), value)
+
+	_, found = d.Get("view")
+	assert.False(t, found, "only bytes are kept on disk")
+}
+
+func TestSizeOf(t *testing.T) {
+	type item struct {
+		Name   string
+		Values []int64
+		Parent *item
+	}
+
+	parent := &item{Name: "parent"}
+	shared := &item{Name: "child", Values: make([]int64, 100), Parent: parent}
+
+	assert.Equal(t, int64(10), sizeOf([]byte("0123456789")))
+	assert.Equal(t, int64(0), sizeOf(nil))
+	assert.GreaterOrEqual(t, sizeOf(image.NewNRGBA(image.Rect(0, 0, 100, 100))), int64(100*100*4))
+	assert.GreaterOrEqual(t, sizeOf(shared), int64(100*8+len("child")+len("parent")))
+
+	// memory referenced twice is counted once
+	assert.Less(t, sizeOf([]*item{shared, shared}), 2*sizeOf(shared))
+
+	// cycles are followed once
+	parent.Parent = parent
+	assert.Positive(t, sizeOf(parent))
+}
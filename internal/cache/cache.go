@@ -9,7 +9,6 @@ import (
 	"github.com/damongolding/immich-kiosk/internal/config"
 	"github.com/damongolding/immich-kiosk/internal/kiosk"
 	"github.com/damongolding/immich-kiosk/internal/utils"
-	gocache "github.com/patrickmn/go-cache"
 )
 
 type Position string
@@ -19,14 +18,21 @@ const (
 	APPEND  Position = "append"
 )
 
-// Package cache provides a simple in-memory cache implementation using github.com/patrickmn/go-cache
+// Package cache provides a simple cache with pluggable backends, see Cache
 var (
-	kioskCache *gocache.Cache
+	kioskCache Cache
 
 	defaultExpiration = 5 * time.Minute
 	cleanupInterval   = 10 * time.Minute
 
 	DemoMode = false
+
+	// Backend the kind of store Initialize creates
+	Backend = BackendLRU
+	// MaxMemory how many bytes of items the lru and disk backends keep in memory
+	MaxMemory = DefaultMaxMemory
+	// Path the file the disk backend keeps items in
+	Path = DefaultPath
 )
 
 // initialize sets up the kiosk cache based on the current mode:
@@ -35,19 +41,49 @@ var (
 //
 // The expiration time determines when items are considered stale and should be removed.
 // The cleanup interval determines how frequently the cache is scanned to remove expired items.
+//
+// The cache is kept in the store chosen by Backend. If that store cannot be created the
+// lru backend is used instead. Calling Initialize again closes the previous cache.
 func Initialize() {
-	// Setting up Immich api cache
+	expiration, cleanup := defaultExpiration, cleanupInterval
 	if DemoMode {
-		kioskCache = gocache.New(time.Minute, 2*time.Minute)
-	} else {
-		kioskCache = gocache.New(defaultExpiration, cleanupInterval)
+		expiration, cleanup = time.Minute, 2*time.Minute
+	}
+
+	if kioskCache != nil {
+		if err := kioskCache.Close(); err != nil {
+			log.Error("Closing cache", "err", err)
+		}
+	}
+
+	// Setting up Immich api cache
+	backend, err := newBackend(Backend, expiration, cleanup, MaxMemory, Path)
+	if err != nil {
+		log.Error("Creating cache, falling back to lru", "backend", Backend, "err", err)
+		backend = newLRUCache(expiration, cleanup, MaxMemory)
+	}
+
+	kioskCache = backend
+}
+
+// Close closes the cache, releasing any resources its backend holds.
+func Close() error {
+	if kioskCache == nil {
+		return nil
 	}
+
+	return kioskCache.Close()
 }
 
 // Flush removes all items from the cache, both expired and unexpired.
 // This operation cannot be undone.
 func Flush() {
 	kioskCache.Flush()
+
+	descriptionsMu.Lock()
+	descriptions = map[string]description{}
+	pruneAt = maxDescriptions
+	descriptionsMu.Unlock()
 }
 
 // ItemCount returns the number of items currently stored in the cache,
@@ -62,7 +98,9 @@ func ItemCount() int {
 func ViewCacheKey(apiURL, deviceID string) string {
 	dateStamp := time.Now().Local().Format(time.DateOnly)
 	key := fmt.Sprintf("%s:%s:view:%s", apiURL, deviceID, dateStamp)
-	return fmt.Sprintf("%x", sha256.Sum256([]byte(key)))
+	hashed := fmt.Sprintf("%x", sha256.Sum256([]byte(key)))
+	describe(hashed, description{category: CategoryView, deviceID: deviceID, url: apiURL})
+	return hashed
 }
 
 // APICacheKey generates a cache key from the API URL and device ID by combining them
@@ -71,33 +109,54 @@ func ViewCacheKey(apiURL, deviceID string) string {
 func APICacheKey(apiURL, deviceID string, user string) string {
 	dateStamp := time.Now().Local().Format(time.DateOnly)
 	key := fmt.Sprintf("%s:%s:%s:api:%s", apiURL, deviceID, user, dateStamp)
-	return fmt.Sprintf("%x", sha256.Sum256([]byte(key)))
+	hashed := fmt.Sprintf("%x", sha256.Sum256([]byte(key)))
+	describeAPI(hashed, apiURL, deviceID, user)
+	return hashed
+}
+
+// SmartCropCacheKey generates a cache key for the area an asset is cropped to for a client's width and height.
+// Crops of the original image and of the preview are kept apart as their sizes differ,
+// as are crops made with different face privacy, so a crop never shows hidden faces.
+// The key is hashed using SHA-256 for consistent length and character set.
+func SmartCropCacheKey(assetID string, width, height int, isOriginal bool, facePrivacy string) string {
+	key := fmt.Sprintf("%s:%dx%d:%t:%s:smartcrop", assetID, width, height, isOriginal, facePrivacy)
+	hashed := fmt.Sprintf("%x", sha256.Sum256([]byte(key)))
+	describe(hashed, description{category: CategoryImage, url: assetID})
+	return hashed
 }
 
 // Get retrieves an item from the cache by key, returning the item and a boolean indicating
 // whether the key was found in the cache. If the key is not found or the item has expired,
 // the boolean will be false.
+// Each lookup is counted as a hit or a miss in Stats.
 func Get(s string) (any, bool) {
-	return kioskCache.Get(s)
+	value, found := kioskCache.Get(s)
+	record(s, found)
+	return value, found
 }
 
 // Set stores a value in the cache under the given key, replacing any existing entry.
 // The expiration is determined by taking the larger of deviceDuration and cacheDuration
 // (each extended by one minute), with defaultExpiration used as a lower bound.
-// If either duration is negative, gocache.DefaultExpiration is used and a warning is logged.
+// If either duration is negative, DefaultExpiration is used and a warning is logged.
 func Set(key string, value any, deviceDuration, cacheDuration int) {
 	if deviceDuration < 0 || cacheDuration < 0 {
 		log.Warn("Negative duration or cache duration provided, using default expiration", "deviceDuration", deviceDuration, "cacheDuration", cacheDuration)
-		kioskCache.Set(key, value, gocache.DefaultExpiration)
+		kioskCache.Set(key, value, DefaultExpiration)
 		return
 	}
 
+	kioskCache.Set(key, value, Expiration(deviceDuration, cacheDuration))
+}
+
+// Expiration returns how long items set with deviceDuration and cacheDuration, both in
+// seconds, are kept: the longer of the two plus a minute, and at least the default
+// expiration.
+func Expiration(deviceDuration, cacheDuration int) time.Duration {
 	deviceDurationPlusMin := (time.Duration(deviceDuration) * time.Second) + time.Minute
 	cacheDurationPlusMin := (time.Duration(cacheDuration) * time.Second) + time.Minute
 
-	d := max(deviceDurationPlusMin, cacheDurationPlusMin, defaultExpiration)
-
-	kioskCache.Set(key, value, d)
+	return max(deviceDurationPlusMin, cacheDurationPlusMin, defaultExpiration)
 }
 
 // SetWithExpiration adds an item to the cache with the specified expiration duration.
@@ -111,12 +170,13 @@ func SetWithExpiration(key string, x any, t time.Duration) {
 // If the key does not exist, no action is taken.
 func Delete(key string) {
 	kioskCache.Delete(key)
+	forget(key)
 }
 
 // Replace updates an existing item in the cache with a new value.
 // Returns an error if the key does not exist.
 func Replace(key string, x any) error {
-	return kioskCache.Replace(key, x, gocache.DefaultExpiration)
+	return kioskCache.Replace(key, x, DefaultExpiration)
 }
 
 // AssetToCache adds a new item of type T to the cache array by appending it to the end.
@@ -0,0 +1,393 @@
+package cache
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/damongolding/immich-kiosk/internal/pathmatch"
+)
+
+// Category groups cache entries by what they hold.
+type Category string
+
+const (
+	// CategoryAPI Immich API responses
+	CategoryAPI Category = "api"
+	// CategoryMemories Immich memories responses
+	CategoryMemories Category = "memories"
+	// CategoryView prefetched views waiting to be shown on a device
+	CategoryView Category = "view"
+	// CategoryVideo downloaded videos
+	CategoryVideo Category = "video"
+	// CategoryImage processed images, such as smart crops
+	CategoryImage Category = "image"
+	// CategoryOther anything else, such as the latest release shown on the about page
+	CategoryOther Category = "other"
+
+	// maxDescriptions how many entry descriptions are kept before those of removed
+	// entries are first pruned
+	maxDescriptions = 10000
+
+	// describedGrace how long a description is kept before its entry is set, as keys
+	// are made before the item they hold is fetched
+	describedGrace = time.Minute
+)
+
+// Categories every category, in the order they are reported.
+var Categories = []Category{CategoryAPI, CategoryMemories, CategoryView, CategoryVideo, CategoryImage, CategoryOther}
+
+// description records what a key was made from, as keys are hashes.
+type description struct {
+	category Category
+	deviceID string
+	user     string
+	url      string
+	// described when the description was recorded
+	described time.Time
+}
+
+// Entry describes a cached item.
+type Entry struct {
+	Key      string   `json:"key"`
+	Category Category `json:"category"`
+	DeviceID string   `json:"deviceID,omitempty"`
+	User     string   `json:"user,omitempty"`
+	URL      string   `json:"url,omitempty"`
+	// Size the estimated size of the item in bytes
+	Size int64 `json:"size"`
+	// Expires when the item expires, zero if it never does
+	Expires time.Time `json:"expires,omitzero"`
+	// TTL seconds until the item expires, -1 if it never does
+	TTL int64 `json:"ttl"`
+}
+
+// Filter selects cache entries. Empty fields match every entry.
+type Filter struct {
+	Category Category `json:"category,omitempty"`
+	DeviceID string   `json:"deviceID,omitempty"`
+	// URL a glob or "re:" regular expression matched against the URL an entry was made
+	// from, e.g. "/albums/<album id>"
+	URL string `json:"url,omitempty"`
+}
+
+// Report summarises a set of cache entries, such as those removed by Invalidate.
+type Report struct {
+	Filter     Filter           `json:"filter"`
+	Count      int              `json:"count"`
+	Size       int64            `json:"size"`
+	Categories map[Category]int `json:"categories"`
+	Entries    []Entry          `json:"entries"`
+}
+
+// CategoryStats how often a category of entries was found in the cache.
+type CategoryStats struct {
+	Hits    uint64  `json:"hits"`
+	Misses  uint64  `json:"misses"`
+	HitRate float64 `json:"hitRate"`
+	Items   int     `json:"items"`
+	Size    int64   `json:"size"`
+}
+
+// Stats how often entries were found in the cache since Since.
+type Stats struct {
+	Backend    BackendType                `json:"backend"`
+	Since      time.Time                  `json:"since"`
+	Total      CategoryStats              `json:"total"`
+	Categories map[Category]CategoryStats `json:"categories"`
+}
+
+var (
+	descriptionsMu sync.RWMutex
+	descriptions   = map[string]description{}
+	// pruneAt how many descriptions are held before those of removed entries are pruned.
+	// It is twice what is left after each prune, so prunes get rarer as more entries
+	// are cached.
+	pruneAt = maxDescriptions
+	pruning atomic.Bool
+
+	statsMu    sync.Mutex
+	hits       = map[Category]uint64{}
+	misses     = map[Category]uint64{}
+	statsSince = time.Now()
+)
+
+// describe records what a key was made from, pruning the descriptions of removed
+// entries once pruneAt are held.
+func describe(key string, d description) {
+	d.described = time.Now()
+
+	descriptionsMu.Lock()
+	descriptions[key] = d
+	full := len(descriptions) >= pruneAt
+	descriptionsMu.Unlock()
+
+	if full && pruning.CompareAndSwap(false, true) {
+		defer pruning.Store(false)
+		pruneDescriptions()
+	}
+}
+
+// describeAPI records an Immich API key. Memories get their own category.
+func describeAPI(key, apiURL, deviceID, user string) {
+	category := CategoryAPI
+	if strings.Contains(apiURL, "/memories") {
+		category = CategoryMemories
+	}
+
+	describe(key, description{category: category, deviceID: deviceID, user: user, url: apiURL})
+}
+
+// describeKey returns what a key was made from. Keys that were not made by one of the
+// key functions are in CategoryOther.
+func describeKey(key string) description {
+	descriptionsMu.RLock()
+	defer descriptionsMu.RUnlock()
+
+	if d, found := descriptions[key]; found {
+		return d
+	}
+
+	return description{category: CategoryOther}
+}
+
+// forget removes the descriptions of keys.
+func forget(keys ...string) {
+	descriptionsMu.Lock()
+	defer descriptionsMu.Unlock()
+
+	for _, key := range keys {
+		delete(descriptions, key)
+	}
+}
+
+// pruneDescriptions removes the descriptions of keys that are no longer cached. The
+// cache's keys are listed without holding descriptionsMu, and descriptions recorded
+// within describedGrace are kept as their items may not have been set yet.
+func pruneDescriptions() {
+	if kioskCache == nil {
+		return
+	}
+
+	cutoff := time.Now().Add(-describedGrace)
+
+	cached := map[string]struct{}{}
+	for _, key := range kioskCache.Keys() {
+		cached[key] = struct{}{}
+	}
+
+	descriptionsMu.Lock()
+	defer descriptionsMu.Unlock()
+
+	for key, d := range descriptions {
+		if _, found := cached[key]; !found && d.described.Before(cutoff) {
+			delete(descriptions, key)
+		}
+	}
+
+	pruneAt = max(maxDescriptions, 2*len(descriptions))
+}
+
+// record counts a lookup of key as a hit or a miss.
+func record(key string, found bool) {
+	category := describeKey(key).category
+
+	statsMu.Lock()
+	defer statsMu.Unlock()
+
+	if found {
+		hits[category]++
+	} else {
+		misses[category]++
+	}
+}
+
+// Matches reports whether an entry is selected by the filter.
+// An invalid URL pattern matches nothing.
+func (f Filter) Matches(entry Entry) bool {
+	if f.Category != "" && f.Category != entry.Category {
+		return false
+	}
+
+	if f.DeviceID != "" && f.DeviceID != entry.DeviceID {
+		return false
+	}
+
+	if f.URL != "" {
+		re, err := pathmatch.Compile(f.URL)
+		if err != nil || !re.MatchString(entry.URL) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Validate returns an error if the filter's category or URL pattern is invalid.
+func (f Filter) Validate() error {
+	if f.Category != "" && !slices.Contains(Categories, f.Category) {
+		return fmt.Errorf("unknown cache category %q", f.Category)
+	}
+
+	if f.URL != "" {
+		if _, err := pathmatch.Compile(f.URL); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Entries returns the cached entries selected by filter, largest first.
+// Lookups made here are not counted in Stats, nor as uses of the entries.
+func Entries(filter Filter) []Entry {
+	var entries []Entry
+
+	for _, item := range kioskCache.Items() {
+		d := describeKey(item.Key)
+
+		entry := Entry{
+			Key:      item.Key,
+			Category: d.category,
+			DeviceID: d.deviceID,
+			User:     d.user,
+			URL:      d.url,
+			Size:     item.Size,
+			Expires:  item.Expiration,
+			TTL:      -1,
+		}
+
+		if !item.Expiration.IsZero() {
+			entry.TTL = int64(time.Until(item.Expiration).Seconds())
+		}
+
+		if filter.Matches(entry) {
+			entries = append(entries, entry)
+		}
+	}
+
+	SortEntries(entries)
+
+	return entries
+}
+
+// SortEntries sorts entries largest first, then by key.
+func SortEntries(entries []Entry) {
+	slices.SortFunc(entries, func(a, b Entry) int {
+		if a.Size != b.Size {
+			if a.Size > b.Size {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.Key, b.Key)
+	})
+}
+
+// Invalidate removes the cached entries selected by filter and reports what was removed.
+func Invalidate(filter Filter) Report {
+	return InvalidateFunc(filter, nil)
+}
+
+// InvalidateFunc removes the cached entries selected by filter for which match returns
+// true, and reports what was removed. A nil match removes every selected entry.
+func InvalidateFunc(filter Filter, match func(Entry) bool) Report {
+	var removed []Entry
+
+	for _, entry := range Entries(filter) {
+		if match != nil && !match(entry) {
+			continue
+		}
+
+		kioskCache.Delete(entry.Key)
+		removed = append(removed, entry)
+	}
+
+	keys := make([]string, len(removed))
+	for i, entry := range removed {
+		keys[i] = entry.Key
+	}
+
+	forget(keys...)
+
+	return NewReport(filter, removed)
+}
+
+// NewReport summarises entries selected by filter.
+func NewReport(filter Filter, entries []Entry) Report {
+	report := Report{
+		Filter:     filter,
+		Count:      len(entries),
+		Categories: map[Category]int{},
+		Entries:    entries,
+	}
+
+	for _, entry := range entries {
+		report.Size += entry.Size
+		report.Categories[entry.Category]++
+	}
+
+	if report.Entries == nil {
+		report.Entries = []Entry{}
+	}
+
+	return report
+}
+
+// GetStats returns how often each category of entries was found in the cache, and how
+// many entries of each are cached.
+func GetStats() Stats {
+	stats := Stats{
+		Backend:    Backend,
+		Categories: make(map[Category]CategoryStats, len(Categories)),
+	}
+
+	for _, entry := range Entries(Filter{}) {
+		categoryStats := stats.Categories[entry.Category]
+		categoryStats.Items++
+		categoryStats.Size += entry.Size
+		stats.Categories[entry.Category] = categoryStats
+	}
+
+	statsMu.Lock()
+	stats.Since = statsSince
+	for _, category := range Categories {
+		categoryStats := stats.Categories[category]
+		categoryStats.Hits = hits[category]
+		categoryStats.Misses = misses[category]
+		categoryStats.HitRate = hitRate(categoryStats.Hits, categoryStats.Misses)
+		stats.Categories[category] = categoryStats
+
+		stats.Total.Hits += categoryStats.Hits
+		stats.Total.Misses += categoryStats.Misses
+		stats.Total.Items += categoryStats.Items
+		stats.Total.Size += categoryStats.Size
+	}
+	statsMu.Unlock()
+
+	stats.Total.HitRate = hitRate(stats.Total.Hits, stats.Total.Misses)
+
+	return stats
+}
+
+// ResetStats clears the hit and miss counts.
+func ResetStats() {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+
+	hits = map[Category]uint64{}
+	misses = map[Category]uint64{}
+	statsSince = time.Now()
+}
+
+// hitRate returns hits as a fraction of all lookups, 0 if there were none.
+func hitRate(hits, misses uint64) float64 {
+	if hits+misses == 0 {
+		return 0
+	}
+
+	return float64(hits) / float64(hits+misses)
+}
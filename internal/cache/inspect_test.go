@@ -0,0 +1,109 @@
+package cache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInvalidate(t *testing.T) {
+	t.Cleanup(Flush)
+	Flush()
+
+	albumURL := "https://immich.example.com/api/albums/album-1"
+
+	Set(ViewCacheKey("/image", "device-1"), []string{"view"}, 60, 0)
+	Set(ViewCacheKey("/image", "device-2"), []string{"view"}, 60, 0)
+	Set(APICacheKey(albumURL, "device-1", ""), []byte("album"), 60, 0)
+	Set(APICacheKey("https://immich.example.com/api/memories?for=today", "device-1", ""), []byte("memories"), 60, 0)
+	Set("https://api.github.com/releases/latest", "release", 60, 0)
+
+	entries := Entries(Filter{})
+	require.Len(t, entries, 5)
+
+	categories := map[Category]int{}
+	for _, entry := range entries {
+		categories[entry.Category]++
+		assert.Positive(t, entry.Size)
+		assert.Positive(t, entry.TTL)
+	}
+	assert.Equal(t, map[Category]int{CategoryView: 2, CategoryAPI: 1, CategoryMemories: 1, CategoryOther: 1}, categories)
+
+	album := Entries(Filter{URL: "/albums/album-1"})
+	require.Len(t, album, 1)
+	assert.Equal(t, albumURL, album[0].URL)
+
+	report := Invalidate(Filter{Category: CategoryView, DeviceID: "device-1"})
+	assert.Equal(t, 1, report.Count)
+	assert.Equal(t, map[Category]int{CategoryView: 1}, report.Categories)
+
+	assert.Len(t, Entries(Filter{Category: CategoryView}), 1, "other devices' views are kept")
+
+	report = Invalidate(Filter{URL: "/albums/album-1"})
+	assert.Equal(t, 1, report.Count)
+	assert.Equal(t, 3, ItemCount())
+}
+
+func TestFilterValidate(t *testing.T) {
+	require.NoError(t, Filter{Category: CategoryAPI, URL: "/albums/*"}.Validate())
+	require.Error(t, Filter{Category: "unknown"}.Validate())
+	require.Error(t, Filter{URL: "re:("}.Validate())
+}
+
+func TestStats(t *testing.T) {
+	t.Cleanup(func() {
+		Flush()
+		ResetStats()
+	})
+	ResetStats()
+
+	key := APICacheKey("https://immich.example.com/api/people/person-1", "device-1", "")
+
+	_, found := Get(key)
+	require.False(t, found)
+
+	Set(key, []byte("person"), 60, 0)
+
+	for range 3 {
+		_, found = Get(key)
+		require.True(t, found)
+	}
+
+	stats := GetStats()
+	api := stats.Categories[CategoryAPI]
+	assert.Equal(t, uint64(3), api.Hits)
+	assert.Equal(t, uint64(1), api.Misses)
+	assert.InDelta(t, 0.75, api.HitRate, 0.001)
+	assert.Equal(t, 1, api.Items)
+	assert.Equal(t, uint64(3), stats.Total.Hits)
+}
+
+func TestPruneDescriptions(t *testing.T) {
+	t.Cleanup(Flush)
+	Flush()
+
+	cachedKey := APICacheKey("https://immich.example.com/api/albums/album-1", "device-1", "")
+	Set(cachedKey, []byte("album"), 60, 0)
+
+	recentKey := APICacheKey("https://immich.example.com/api/albums/album-2", "device-1", "")
+
+	descriptionsMu.Lock()
+	for i := range maxDescriptions - 3 {
+		descriptions[fmt.Sprintf("removed-%d", i)] = description{category: CategoryAPI, described: time.Now().Add(-2 * describedGrace)}
+	}
+	descriptionsMu.Unlock()
+
+	APICacheKey("https://immich.example.com/api/albums/album-3", "device-1", "")
+
+	descriptionsMu.RLock()
+	held, nextPrune := len(descriptions), pruneAt
+	descriptionsMu.RUnlock()
+
+	assert.Equal(t, 3, held, "only cached and recently made descriptions are kept")
+	assert.Equal(t, maxDescriptions, nextPrune)
+	assert.Equal(t, CategoryAPI, describeKey(cachedKey).category)
+	assert.Equal(t, CategoryAPI, describeKey(recentKey).category)
+}
@@ -0,0 +1,125 @@
+package cache
+
+import (
+	"reflect"
+)
+
+// sizeOf estimates how many bytes of memory value holds, following pointers, slices,
+// maps and interfaces. Memory shared between parts of value is only counted once.
+// Functions, channels and unsafe pointers are counted by their own size only.
+func sizeOf(value any) int64 {
+	switch v := value.(type) {
+	case nil:
+		return 0
+	case []byte:
+		return int64(cap(v))
+	case string:
+		return int64(len(v))
+	}
+
+	return deepSize(reflect.ValueOf(value), map[uintptr]struct{}{})
+}
+
+// deepSize returns the size of v and everything it references.
+func deepSize(v reflect.Value, seen map[uintptr]struct{}) int64 {
+	if !v.IsValid() {
+		return 0
+	}
+
+	return int64(v.Type().Size()) + referencedSize(v, seen)
+}
+
+// referencedSize returns the size of the memory v references, not counting v itself.
+func referencedSize(v reflect.Value, seen map[uintptr]struct{}) int64 {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() || !visit(v.Pointer(), seen) {
+			return 0
+		}
+		return deepSize(v.Elem(), seen)
+
+	case reflect.Interface:
+		if v.IsNil() {
+			return 0
+		}
+		return deepSize(v.Elem(), seen)
+
+	case reflect.String:
+		return int64(v.Len())
+
+	case reflect.Slice:
+		if v.IsNil() || !visit(v.Pointer(), seen) {
+			return 0
+		}
+
+		size := int64(v.Cap()) * int64(v.Type().Elem().Size())
+		if hasReferences(v.Type().Elem()) {
+			for i := range v.Len() {
+				size += referencedSize(v.Index(i), seen)
+			}
+		}
+		return size
+
+	case reflect.Array:
+		var size int64
+		if hasReferences(v.Type().Elem()) {
+			for i := range v.Len() {
+				size += referencedSize(v.Index(i), seen)
+			}
+		}
+		return size
+
+	case reflect.Struct:
+		var size int64
+		for i := range v.NumField() {
+			size += referencedSize(v.Field(i), seen)
+		}
+		return size
+
+	case reflect.Map:
+		if v.IsNil() || !visit(v.Pointer(), seen) {
+			return 0
+		}
+
+		var size int64
+		iter := v.MapRange()
+		for iter.Next() {
+			size += deepSize(iter.Key(), seen) + deepSize(iter.Value(), seen)
+		}
+		return size
+
+	default:
+		return 0
+	}
+}
+
+// visit records a pointer as seen, returning false if it already was.
+func visit(ptr uintptr, seen map[uintptr]struct{}) bool {
+	if _, found := seen[ptr]; found {
+		return false
+	}
+
+	seen[ptr] = struct{}{}
+	return true
+}
+
+// hasReferences reports whether values of t can reference other memory.
+func hasReferences(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return false
+	case reflect.Array:
+		return hasReferences(t.Elem())
+	case reflect.Struct:
+		for i := range t.NumField() {
+			if hasReferences(t.Field(i).Type) {
+				return true
+			}
+		}
+		return false
+	default:
+		return true
+	}
+}
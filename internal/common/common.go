@@ -15,6 +15,7 @@ import (
 	"github.com/damongolding/immich-kiosk/internal/config"
 	"github.com/damongolding/immich-kiosk/internal/immich"
 	"github.com/damongolding/immich-kiosk/internal/kiosk"
+	"github.com/damongolding/immich-kiosk/internal/story"
 	"github.com/damongolding/immich-kiosk/internal/utils"
 	"github.com/labstack/echo/v5"
 )
@@ -85,12 +86,21 @@ type RouteRequestData struct {
 type ViewImageData struct {
 	ImageData          string       // ImageData contains the image as base64 data
 	ImageBlurData      string       // ImageBlurData contains the blurred image as base64 data
+	ImagePlaceholder   string       // ImagePlaceholder contains the asset's thumbhash as a PNG data URL
+	ImageBackground    string       // ImageBackground contains the CSS background for the colour and gradient background styles
 	ImageDate          string       // ImageDate contains the date of the image
 	User               string       // User the user api key used
 	ImmichAsset        immich.Asset // ImmichAsset contains immich asset data
 	ImageDominantColor color.RGBA   // ImageDominantColor contains the dominant color of the image
 }
 
+// StoryCard contains the title card shown before an album or memory is played as a story
+type StoryCard struct {
+	Title  string   // Title contains the album name or memory title
+	Dates  string   // Dates contains the formatted date span of the story
+	People []string // People contains the names of people in the story
+}
+
 // ViewData contains all the data needed to render a view in the application
 type ViewData struct {
 	KioskVersion  string          // KioskVersion contains the current build version of Kiosk
@@ -99,6 +109,10 @@ type ViewData struct {
 	Assets        []ViewImageData // Assets contains the collection of assets to display in view
 	Queries       url.Values      // Queries contains the URL query parameters
 	CustomCSS     []byte          // CustomCSS contains custom CSS styling as bytes
+	StoryCard     *StoryCard      // StoryCard contains the story title card to show, if any
+	Story         *story.Story    // Story contains the story the view is the title card of, started once the view is served
+	StoryAssetID  string          // StoryAssetID contains the story asset the view shows, the story advances past it once the view is served
+	AssetDuration int             // AssetDuration overrides Duration, in seconds, for this view when set
 	config.Config                 // Config contains the instance configuration
 }
 
@@ -197,8 +211,11 @@ type URLBuilderRequest struct {
 
 	// Image
 	ImageFit          *string `form:"image_fit" url:"image_fit,omitempty"`
+	SmartCrop         *bool   `form:"smart_crop" url:"smart_crop,omitempty"`
 	ImageEffect       *string `form:"image_effect" url:"image_effect,omitempty"`
 	ImageEffectAmount *uint64 `form:"image_effect_amount" url:"image_effect_amount,omitempty"`
+	ImageFormat       *string `form:"image_format" url:"image_format,omitempty"`
+	ImageQuality      *uint64 `form:"image_quality" url:"image_quality,omitempty"`
 	UseOriginalImage  *bool   `form:"use_original_image" url:"use_original_image,omitempty"`
 
 	// Metadata
@@ -28,6 +28,7 @@ import (
 	"net/url"
 	"os"
 	"reflect"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -81,6 +82,14 @@ type OfflineMode struct {
 	Enabled bool `yaml:"enabled" mapstructure:"enabled" default:"false"`
 }
 
+// BucketWeight custom weighting for an asset bucket.
+type BucketWeight struct {
+	// Multiplier multiplies the bucket's asset count based weight
+	Multiplier float64
+	// Share fixed percentage (0-100) of picks that should come from the bucket
+	Share float64
+}
+
 // Redirect represents a URL redirection configuration with a friendly name.
 type Redirect struct {
 	// Name is the friendly identifier used to access the redirect
@@ -91,6 +100,36 @@ type Redirect struct {
 	Type string `yaml:"type" mapstructure:"type"`
 }
 
+// CacheBackend where cached Immich API responses and view data are kept.
+type CacheBackend struct {
+	// Type the cache backend: "memory" (no size limit), "lru" (limited to MaxMemory) or "disk"
+	Type string `json:"type" yaml:"type" mapstructure:"type" default:"lru"`
+	// MaxMemory how much memory cached items may use, e.g. "256MB". The disk backend only keeps non API items in memory
+	MaxMemory string `json:"maxMemory" yaml:"max_memory" mapstructure:"max_memory" default:"256MB"`
+	// MaxMemoryBytes MaxMemory parsed to bytes
+	MaxMemoryBytes int64 `json:"-" yaml:"-"`
+	// Path the file the disk backend keeps items in
+	Path string `json:"path" yaml:"path" mapstructure:"path" default:"./kiosk-data/cache.db"`
+}
+
+// WarmUp fills the cache for every known device so the first request after waking is fast.
+type WarmUp struct {
+	// Enabled warm up at startup, on Schedule and before each device's sleep_end
+	Enabled bool `json:"enabled" yaml:"enabled" mapstructure:"enabled" default:"false"`
+	// Schedule a cron expression for extra warm ups, e.g. "50 6 * * *"
+	Schedule string `json:"schedule" yaml:"schedule" mapstructure:"schedule" default:""`
+	// BeforeSleepEnd minutes before a device's sleep_end to warm up. 0 disables
+	BeforeSleepEnd int `json:"beforeSleepEnd" yaml:"before_sleep_end" mapstructure:"before_sleep_end" default:"3"`
+}
+
+// Processing how CPU heavy image work, such as resizing and blurring, is run.
+type Processing struct {
+	// Workers how many images are processed at once. 0 uses one worker per CPU
+	Workers int `json:"workers" yaml:"workers" mapstructure:"workers" default:"0"`
+	// QueueSize how many images may wait to be processed before prefetches are skipped
+	QueueSize int `json:"queueSize" yaml:"queue_size" mapstructure:"queue_size" default:"64"`
+}
+
 type KioskSettings struct {
 	// RedirectsMap provides O(1) lookup of redirect URLs by their friendly name
 	RedirectsMap map[string]Redirect `json:"-" yaml:"-"`
@@ -128,6 +167,9 @@ type KioskSettings struct {
 
 	// WatchConfig if kiosk should watch config file for changes
 	WatchConfig bool `json:"watchConfig" yaml:"watch_config" mapstructure:"watch_config" default:"false"`
+	// WatchImmichInterval seconds between checks of Immich for changed assets, albums and people,
+	// whose cached API responses are then removed. 0 disables
+	WatchImmichInterval int `json:"watchImmichInterval" yaml:"watch_immich_interval" mapstructure:"watch_immich_interval" default:"0"`
 
 	// Cache enable/disable api call and image caching
 	Cache bool `json:"cache" yaml:"cache" mapstructure:"cache" default:"true"`
@@ -138,6 +180,23 @@ type KioskSettings struct {
 	// AssetWeighting use weighting when picking assets
 	AssetWeighting bool `json:"assetWeighting" yaml:"asset_weighting" mapstructure:"asset_weighting" default:"true"`
 
+	// PersistPlaybackPosition save the position of ordered albums and memories to disk
+	PersistPlaybackPosition bool `json:"persistPlaybackPosition" yaml:"persist_playback_position" mapstructure:"persist_playback_position" default:"true"`
+	// PersistSeenAssets save which assets each device has shown to disk
+	PersistSeenAssets bool `json:"persistSeenAssets" yaml:"persist_seen_assets" mapstructure:"persist_seen_assets" default:"true"`
+	// PersistDecks save each device's deck to disk
+	PersistDecks bool `json:"persistDecks" yaml:"persist_decks" mapstructure:"persist_decks" default:"true"`
+	// CacheBackend where cached items are kept and how much memory they may use
+	CacheBackend CacheBackend `json:"cacheBackend" yaml:"cache_backend" mapstructure:"cache_backend"`
+	// DiskCacheSize how much disk space fetched and processed images are cached in, e.g. "2GB". "0" disables
+	DiskCacheSize string `json:"diskCacheSize" yaml:"disk_cache_size" mapstructure:"disk_cache_size" default:"2GB"`
+	// DiskCacheSizeBytes DiskCacheSize parsed to bytes
+	DiskCacheSizeBytes int64 `json:"-" yaml:"-"`
+	// WarmUp fill the cache for known devices at startup and before they wake
+	WarmUp WarmUp `json:"warmUp" yaml:"warm_up" mapstructure:"warm_up"`
+	// Processing the worker pool images are processed on
+	Processing Processing `json:"processing" yaml:"processing" mapstructure:"processing"`
+
 	AllowedOrigins []string `json:"allowedOrigins" yaml:"allowed_origins" mapstructure:"allowed_origins" default:"[]"`
 
 	// debug modes
@@ -340,7 +399,53 @@ type Config struct {
 	Memories       bool    `json:"memories" yaml:"memories" mapstructure:"memories" query:"memories" form:"memories" default:"false"`
 	MemoriesOnly   bool    `json:"memoriesOnly" yaml:"memories_only" mapstructure:"memories_only" query:"memories_only" form:"memories_only" default:"false"`
 	PastMemoryDays int     `json:"pastMemoryDays" yaml:"past_memory_days" mapstructure:"past_memory_days" query:"past_memory_days" form:"past_memory_days" default:"0"`
-	MemoryWeight   float64 `json:"memoryWeight" yaml:"memory_weight" mapstructure:"memory_weight" default:"1.0"`
+	MemoryWeight   float64 `json:"memoryWeight" yaml:"memory_weight" mapstructure:"memory_weight" query:"memory_weight" form:"memory_weight" default:"1.0"`
+	// MemoryShare fixed percentage of assets to pick from memories. 0 uses MemoryWeight
+	MemoryShare float64 `json:"memoryShare" yaml:"memory_share" mapstructure:"memory_share" query:"memory_share" form:"memory_share" default:"0"`
+
+	// TagWeights custom weights for tags, keyed by tag name, as "3" or "25%"
+	TagWeights map[string]string `json:"tagWeights" yaml:"tag_weights" mapstructure:"tag_weights" default:"{}"`
+
+	// BucketWeights custom weights for people, albums and dates parsed from an "ID:3" or "ID:25%" suffix,
+	// and for tags from TagWeights
+	BucketWeights map[string]BucketWeight `json:"-" yaml:"-"`
+
+	// WeightingStrategy bias which assets are picked within a bucket (recency, rating, favourites, least-shown)
+	WeightingStrategy []string `json:"weightingStrategy" yaml:"weighting_strategy" mapstructure:"weighting_strategy" query:"weighting_strategy" form:"weighting_strategy" default:"[]" lowercase:"true"`
+	// WeightingHalfLife days after which the recency strategy halves an asset's weight
+	WeightingHalfLife int `json:"weightingHalfLife" yaml:"weighting_half_life" mapstructure:"weighting_half_life" query:"weighting_half_life" form:"weighting_half_life" default:"365"`
+	// WeightingFavouriteBoost weight multiplier the favourites strategy gives favourited assets
+	WeightingFavouriteBoost float64 `json:"weightingFavouriteBoost" yaml:"weighting_favourite_boost" mapstructure:"weighting_favourite_boost" query:"weighting_favourite_boost" form:"weighting_favourite_boost" default:"3"`
+
+	// Deck show every asset from a source once, in a shuffled order, before repeating
+	Deck bool `json:"deck" yaml:"deck" mapstructure:"deck" query:"deck" form:"deck" default:"false"`
+
+	// Story play an album or memory in order, after a title card, when one of its assets is picked
+	Story bool `json:"story" yaml:"story" mapstructure:"story" query:"story" form:"story" default:"false"`
+	// StoryDuration seconds each asset of a story is shown for
+	StoryDuration int `json:"storyDuration" yaml:"story_duration" mapstructure:"story_duration" query:"story_duration" form:"story_duration" default:"10"`
+	// StoryMaxAssets the most assets a story shows. 0 shows them all
+	StoryMaxAssets int `json:"storyMaxAssets" yaml:"story_max_assets" mapstructure:"story_max_assets" query:"story_max_assets" form:"story_max_assets" default:"0"`
+
+	// NoRepeatDays do not show an asset again within this many days. 0 disables
+	NoRepeatDays int `json:"noRepeatDays" yaml:"no_repeat_days" mapstructure:"no_repeat_days" query:"no_repeat_days" form:"no_repeat_days" default:"0"`
+	// NoRepeatGroup devices in the same group share which assets they have shown
+	NoRepeatGroup string `json:"noRepeatGroup" yaml:"no_repeat_group" mapstructure:"no_repeat_group" query:"no_repeat_group" form:"no_repeat_group" default:""`
+
+	// Journey walk the library (or a filtered subset) in capture date order
+	Journey bool `json:"journey" yaml:"journey" mapstructure:"journey" query:"journey" form:"journey" default:"false"`
+	// JourneyStart date (YYYY-MM-DD) the journey starts from. Empty starts from the oldest asset
+	JourneyStart string `json:"journeyStart" yaml:"journey_start" mapstructure:"journey_start" query:"journey_start" form:"journey_start" default:""`
+	// JourneySkipBursts skip assets taken within this many seconds of the previous asset. 0 disables
+	JourneySkipBursts int `json:"journeySkipBursts" yaml:"journey_skip_bursts" mapstructure:"journey_skip_bursts" query:"journey_skip_bursts" form:"journey_skip_bursts" default:"0"`
+	// JourneyPeople limit the journey to assets containing these people
+	JourneyPeople []string `json:"journeyPeople" yaml:"journey_people" mapstructure:"journey_people" query:"journey_person" form:"journey_person" default:"[]" redact:"true"`
+	// JourneyAlbums limit the journey to assets in these albums
+	JourneyAlbums []string `json:"journeyAlbums" yaml:"journey_albums" mapstructure:"journey_albums" query:"journey_album" form:"journey_album" default:"[]" redact:"true"`
+	// JourneyWeight weight multiplier for the journey, which is weighted like the whole library
+	JourneyWeight float64 `json:"journeyWeight" yaml:"journey_weight" mapstructure:"journey_weight" query:"journey_weight" form:"journey_weight" default:"1.0"`
+	// JourneyShare fixed percentage of assets to pick from the journey. 0 uses JourneyWeight
+	JourneyShare float64 `json:"journeyShare" yaml:"journey_share" mapstructure:"journey_share" query:"journey_share" form:"journey_share" default:"0"`
 
 	// FilterDate filter certain asset bucket assets by date range
 	FilterDate string `json:"filterDate" yaml:"filter_date" mapstructure:"filter_date" query:"filter_date" form:"filter_date" default:""`
@@ -348,6 +453,22 @@ type Config struct {
 	FilterNewest int `json:"filterNewest" yaml:"filter_newest" mapstructure:"filter_newest" query:"filter_newest" form:"filter_newest" default:"0"`
 	// FilterExcludeFaces filter certain asset bucket assets by the presence of faces
 	FilterExcludeFaces bool `json:"filterExcludeFaces" yaml:"filter_exclude_faces" mapstructure:"filter_exclude_faces" query:"filter_exclude_faces" form:"filter_exclude_faces" default:"false"`
+	// FilterMinWidth exclude assets narrower than this many pixels. 0 disables
+	FilterMinWidth int `json:"filterMinWidth" yaml:"filter_min_width" mapstructure:"filter_min_width" query:"filter_min_width" form:"filter_min_width" default:"0"`
+	// FilterMinHeight exclude assets shorter than this many pixels. 0 disables
+	FilterMinHeight int `json:"filterMinHeight" yaml:"filter_min_height" mapstructure:"filter_min_height" query:"filter_min_height" form:"filter_min_height" default:"0"`
+	// FilterMinFileSize exclude assets smaller than this size, e.g. "500KB". Empty disables
+	FilterMinFileSize string `json:"filterMinFileSize" yaml:"filter_min_file_size" mapstructure:"filter_min_file_size" query:"filter_min_file_size" form:"filter_min_file_size" default:""`
+	// FilterMinFileSizeBytes FilterMinFileSize parsed to bytes
+	FilterMinFileSizeBytes int64 `json:"-" yaml:"-"`
+	// FilterExcludeMimeTypes exclude assets with these mime types, e.g. "image/png"
+	FilterExcludeMimeTypes []string `json:"filterExcludeMimeTypes" yaml:"filter_exclude_mime_types" mapstructure:"filter_exclude_mime_types" query:"filter_exclude_mime_type" form:"filter_exclude_mime_type" default:"[]" lowercase:"true"`
+	// FilterExcludeScreenshots exclude assets that look like screenshots
+	FilterExcludeScreenshots bool `json:"filterExcludeScreenshots" yaml:"filter_exclude_screenshots" mapstructure:"filter_exclude_screenshots" query:"filter_exclude_screenshots" form:"filter_exclude_screenshots" default:"false"`
+	// FilterIncludePaths only show assets whose filename or path matches one of these glob (or "re:" regex) patterns
+	FilterIncludePaths []string `json:"filterIncludePaths" yaml:"filter_include_paths" mapstructure:"filter_include_paths" query:"filter_include_path" form:"filter_include_path" default:"[]" redact:"true"`
+	// FilterExcludePaths exclude assets whose filename or path matches one of these glob (or "re:" regex) patterns
+	FilterExcludePaths []string `json:"filterExcludePaths" yaml:"filter_exclude_paths" mapstructure:"filter_exclude_paths" query:"filter_exclude_path" form:"filter_exclude_path" default:"[]" redact:"true"`
 
 	// ShowClearCacheButton display a button to clear cache
 	ShowClearCacheButton bool `json:"showClearCacheButton" yaml:"show_clear_cache_button" mapstructure:"show_clear_cache_button" query:"show_clear_cache_button" form:"show_clear_cache_button" default:"false"`
@@ -371,10 +492,22 @@ type Config struct {
 	BackgroundBlur bool `json:"backgroundBlur" yaml:"background_blur" mapstructure:"background_blur" query:"background_blur" form:"background_blur" default:"true"`
 	// BackgroundBlurAmount the amount of blur to apply
 	BackgroundBlurAmount int `json:"backgroundBlurAmount" yaml:"background_blur_amount" mapstructure:"background_blur_amount" query:"background_blur_amount" form:"background_blur_amount" default:"10"`
+	// BackgroundStyle how the background is made: "blur" blurs the image, "thumbhash" uses the asset's thumbhash,
+	// "colour" the image's dominant colour, "gradient" a gradient between its edge colours and "mirror" mirrors its edges
+	BackgroundStyle string `json:"backgroundStyle" yaml:"background_style" mapstructure:"background_style" query:"background_style" form:"background_style" default:"blur" lowercase:"true"`
+	// Effects server side effects applied to images in order, as "name" or "name:amount", e.g. "grayscale", "vignette:0.3"
+	Effects []string `json:"effects" yaml:"effects" mapstructure:"effects" query:"effects" form:"effects" default:"[]" lowercase:"true"`
+	// FacePrivacy hides faces that are unnamed, or not in FacePrivacyAllowedPeople: "off", "blur" or "pixelate".
+	// It cannot be changed by URL queries
+	FacePrivacy string `json:"facePrivacy" yaml:"face_privacy" mapstructure:"face_privacy" default:"off" lowercase:"true"`
+	// FacePrivacyAllowedPeople IDs of the people whose faces are shown when FacePrivacy is on. When empty every named person is shown
+	FacePrivacyAllowedPeople []string `json:"facePrivacyAllowedPeople" yaml:"face_privacy_allowed_people" mapstructure:"face_privacy_allowed_people" default:"[]"`
 	// Theme which theme to use
 	Theme string `json:"theme" yaml:"theme" mapstructure:"theme" query:"theme" form:"theme" default:"fade" lowercase:"true"`
 	// Layout which layout to use
 	Layout string `json:"layout" yaml:"layout" mapstructure:"layout" query:"layout" form:"layout" default:"single" lowercase:"true"`
+	// SplitViewPairing how the second split view asset is chosen, tried in order before falling back to the same source
+	SplitViewPairing []string `json:"splitViewPairing" yaml:"split_view_pairing" mapstructure:"split_view_pairing" query:"split_view_pairing" form:"split_view_pairing" default:"[]" lowercase:"true"`
 
 	// UpArrowAction action to perform when up arrow is pressed
 	UpArrowAction string `json:"upArrowAction" yaml:"up_arrow_action" mapstructure:"up_arrow_action" query:"up_arrow_action" form:"up_arrow_action" default:"" lowercase:"true"`
@@ -401,10 +534,16 @@ type Config struct {
 
 	// ImageFit the fit style for main image
 	ImageFit string `json:"imageFit" yaml:"image_fit" mapstructure:"image_fit" query:"image_fit" form:"image_fit" default:"contain" lowercase:"true"`
+	// SmartCrop crop cover fit images on the server, keeping faces in frame
+	SmartCrop bool `json:"smartCrop" yaml:"smart_crop" mapstructure:"smart_crop" query:"smart_crop" form:"smart_crop" default:"false"`
 	// ImageEffect which effect to apply to image (if any)
 	ImageEffect string `json:"imageEffect" yaml:"image_effect" mapstructure:"image_effect" query:"image_effect" form:"image_effect" default:"" lowercase:"true"`
 	// ImageEffectAmount the amount of effect to apply
 	ImageEffectAmount int `json:"imageEffectAmount" yaml:"image_effect_amount" mapstructure:"image_effect_amount" query:"image_effect_amount" form:"image_effect_amount" default:"120"`
+	// ImageFormat the format processed images are sent to the browser in
+	ImageFormat string `json:"imageFormat" yaml:"image_format" mapstructure:"image_format" query:"image_format" form:"image_format" default:"auto" lowercase:"true"`
+	// ImageQuality the encoding quality of processed images, 0 uses each format's default
+	ImageQuality int `json:"imageQuality" yaml:"image_quality" mapstructure:"image_quality" query:"image_quality" form:"image_quality" default:"0"`
 	// UseOriginalImage use the original image
 	UseOriginalImage bool `json:"useOriginalImage" yaml:"use_original_image" mapstructure:"use_original_image" query:"use_original_image" form:"use_original_image" default:"false"`
 
@@ -424,6 +563,8 @@ type Config struct {
 	ShowOwner bool `json:"showOwner" yaml:"show_owner" mapstructure:"show_owner" query:"show_owner" form:"show_owner" default:"false"`
 	// ShowAlbumName whether to display the album name
 	ShowAlbumName bool `json:"showAlbumName" yaml:"show_album_name" mapstructure:"show_album_name" query:"show_album_name" form:"show_album_name" default:"false"`
+	// ShowJourneyDate whether to display where in time the journey is, e.g. "March 2014"
+	ShowJourneyDate bool `json:"showJourneyDate" yaml:"show_journey_date" mapstructure:"show_journey_date" query:"show_journey_date" form:"show_journey_date" default:"true"`
 	// ShowPersonName whether to display the person name
 	ShowPersonName bool `json:"showPersonName" yaml:"show_person_name" mapstructure:"show_person_name" query:"show_person_name" form:"show_person_name" default:"false"`
 	// ShowPersonAge whether to display the person age
@@ -537,6 +678,7 @@ func bindEnvironmentVariables(v *viper.Viper) error {
 		{"kiosk.port", "KIOSK_PORT"},
 		{"kiosk.behind_proxy", "KIOSK_BEHIND_PROXY"},
 		{"kiosk.watch_config", "KIOSK_WATCH_CONFIG"},
+		{"kiosk.watch_immich_interval", "KIOSK_WATCH_IMMICH_INTERVAL"},
 		{"kiosk.disable_url_queries", "KIOSK_DISABLE_URL_QUERIES"},
 		{"kiosk.disable_config_endpoint", "KIOSK_DISABLE_CONFIG_ENDPOINT"},
 		{"kiosk.enable_url_builder", "KIOSK_ENABLE_URL_BUILDER"},
@@ -546,6 +688,18 @@ func bindEnvironmentVariables(v *viper.Viper) error {
 		{"kiosk.cache", "KIOSK_CACHE"},
 		{"kiosk.prefetch", "KIOSK_PREFETCH"},
 		{"kiosk.asset_weighting", "KIOSK_ASSET_WEIGHTING"},
+		{"kiosk.persist_playback_position", "KIOSK_PERSIST_PLAYBACK_POSITION"},
+		{"kiosk.persist_seen_assets", "KIOSK_PERSIST_SEEN_ASSETS"},
+		{"kiosk.persist_decks", "KIOSK_PERSIST_DECKS"},
+		{"kiosk.disk_cache_size", "KIOSK_DISK_CACHE_SIZE"},
+		{"kiosk.warm_up.enabled", "KIOSK_WARM_UP_ENABLED"},
+		{"kiosk.warm_up.schedule", "KIOSK_WARM_UP_SCHEDULE"},
+		{"kiosk.warm_up.before_sleep_end", "KIOSK_WARM_UP_BEFORE_SLEEP_END"},
+		{"kiosk.processing.workers", "KIOSK_PROCESSING_WORKERS"},
+		{"kiosk.processing.queue_size", "KIOSK_PROCESSING_QUEUE_SIZE"},
+		{"kiosk.cache_backend.type", "KIOSK_CACHE_BACKEND_TYPE"},
+		{"kiosk.cache_backend.max_memory", "KIOSK_CACHE_BACKEND_MAX_MEMORY"},
+		{"kiosk.cache_backend.path", "KIOSK_CACHE_BACKEND_PATH"},
 		{"kiosk.debug", "KIOSK_DEBUG"},
 		{"kiosk.debug_verbose", "KIOSK_DEBUG_VERBOSE"},
 		{"kiosk.demo_mode", "KIOSK_DEMO_MODE"},
@@ -685,6 +839,7 @@ func (c *Config) Load() error {
 	c.checkUsersAPIKeys()
 	c.checkLowercaseTaggedFields()
 	c.checkAssetBuckets()
+	c.checkBucketWeights()
 	c.checkAlbumOrder()
 	c.checkExcludedAlbums()
 	c.checkTags()
@@ -696,17 +851,34 @@ func (c *Config) Load() error {
 	c.checkWeatherRotationInterval()
 	c.checkDebuging()
 	c.checkFetchedAssetsSize()
+	c.checkDiskCacheSize()
+	c.checkCacheBackend()
+	c.checkWatchImmichInterval()
+	c.checkWarmUp()
+	c.checkProcessing()
 	c.checkRedirects()
 	c.checkOffline()
 	c.checkBurnIn()
 	c.checkFilterNewest()
+	c.checkQualityFilters()
+	c.checkPathFilters()
+	c.checkJourney()
+	c.checkWeightingStrategy()
+	c.checkNoRepeat()
+	c.checkSplitViewPairing()
+	c.checkStory()
+	c.checkImageFormat()
+	c.checkBackgroundStyle()
+	c.checkEffects()
+	c.checkLayout()
+	c.checkFacePrivacy()
 
 	return nil
 }
 
-// ResetBuckets clears all the asset bucket slice fields (Person, Album, Date)
-// in the Config structure. This is typically used when applying new query parameters
-// to ensure old values don't persist. When querying specific buckets, the previous
+// ResetBuckets clears all the asset bucket slice fields (Person, Album, Date), and their
+// custom weights, in the Config structure. This is typically used when applying new query
+// parameters to ensure old values don't persist. When querying specific buckets, the previous
 // values need to be cleared to avoid mixing unintended assets.
 func (c *Config) ResetBuckets() {
 	c.People = []string{}
@@ -714,6 +886,17 @@ func (c *Config) ResetBuckets() {
 	c.Dates = []string{}
 	c.Tags = []string{}
 	c.Rating = -1
+	c.BucketWeights = nil
+}
+
+// FacePrivacyKey identifies how faces are hidden, for the cache keys of images made once
+// faces have been hidden. It is empty when face privacy is off.
+func (c *Config) FacePrivacyKey() string {
+	if c.FacePrivacy == "" || c.FacePrivacy == kiosk.FacePrivacyOff {
+		return ""
+	}
+
+	return c.FacePrivacy + ":" + strings.Join(c.FacePrivacyAllowedPeople, ",")
 }
 
 func getHistory(queries url.Values) []string {
@@ -736,7 +919,7 @@ func (c *Config) ConfigWithOverrides(queries url.Values, e *echo.Context) error
 	}
 
 	// check for person or album in quries and empty baseconfig slice if found
-	if queries.Has("person") || queries.Has("album") || queries.Has("date") || queries.Has("tag") || queries.Has("memories") || queries.Has("rating") {
+	if queries.Has("person") || queries.Has("album") || queries.Has("date") || queries.Has("tag") || queries.Has("memories") || queries.Has("rating") || queries.Has("journey") {
 		c.ResetBuckets()
 	}
 
@@ -754,13 +937,30 @@ func (c *Config) ConfigWithOverrides(queries url.Values, e *echo.Context) error
 		c.ExcludedPartners = []string{}
 	}
 
+	// face privacy is only set by the server, so a request can never show hidden faces
+	facePrivacy, facePrivacyAllowedPeople := c.FacePrivacy, slices.Clone(c.FacePrivacyAllowedPeople)
+
 	err := e.Bind(c)
 	if err != nil {
 		return err
 	}
 
+	c.FacePrivacy, c.FacePrivacyAllowedPeople = facePrivacy, facePrivacyAllowedPeople
+
 	c.checkFilterNewest()
+	c.checkQualityFilters()
+	c.checkPathFilters()
+	c.checkBucketWeights()
 	c.checkExcludedAlbums()
+	c.checkJourney()
+	c.checkWeightingStrategy()
+	c.checkNoRepeat()
+	c.checkSplitViewPairing()
+	c.checkStory()
+	c.checkImageFormat()
+	c.checkBackgroundStyle()
+	c.checkEffects()
+	c.checkLayout()
 
 	// Disabled features in demo mode
 	if c.Kiosk.DemoMode {
@@ -11,6 +11,7 @@ import (
 
 	"charm.land/log/v2"
 
+	"github.com/damongolding/immich-kiosk/internal/kiosk"
 	"github.com/labstack/echo/v5"
 	"github.com/stretchr/testify/assert"
 )
@@ -48,6 +49,32 @@ func TestImmichURLImmichApiKeyImmutability(t *testing.T) {
 	assert.Equal(t, originalUsersAPIKeys, c.ImmichUsersAPIKeys, "ImmichUsersAPIKeys field was allowed to be changed")
 }
 
+// TestFacePrivacyImmutability tests face privacy cannot be turned off by a request
+func TestFacePrivacyImmutability(t *testing.T) {
+	c := New()
+	c.FacePrivacy = kiosk.FacePrivacyBlur
+	c.FacePrivacyAllowedPeople = []string{"alex"}
+
+	e := echo.New()
+
+	q := make(url.Values)
+	q.Add("face_privacy", "off")
+	q.Add("face_privacy_allowed_people", "sam")
+
+	body := strings.NewReader(`{"facePrivacy": "off", "facePrivacyAllowedPeople": ["sam"]}`)
+	req := httptest.NewRequest(http.MethodPost, "/?"+q.Encode(), body)
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+
+	echoContenx := e.NewContext(req, rec)
+
+	err := c.ConfigWithOverrides(echoContenx.QueryParams(), echoContenx)
+	assert.NoError(t, err, "ConfigWithOverrides should not return an error")
+
+	assert.Equal(t, kiosk.FacePrivacyBlur, c.FacePrivacy, "FacePrivacy field was allowed to be changed")
+	assert.Equal(t, []string{"alex"}, c.FacePrivacyAllowedPeople, "FacePrivacyAllowedPeople field was allowed to be changed")
+}
+
 // TestImmichURLImmichMultiplePerson tests the addition of multiple persons to the config
 func TestImmichURLImmichMultiplePerson(t *testing.T) {
 	c := New()
@@ -317,3 +344,142 @@ func TestCheckWeatherLocations(t *testing.T) {
 		})
 	}
 }
+
+func TestBucketWeightsFromQueries(t *testing.T) {
+	c := New()
+
+	e := echo.New()
+
+	q := make(url.Values)
+	q.Add("album", "ALBUM_1:3")
+	q.Add("album", "ALBUM_2:25%")
+	q.Add("album", "ALBUM_3")
+	q.Add("person", "PERSON_1@user:0.5")
+
+	req := httptest.NewRequest(http.MethodGet, "/?"+q.Encode(), nil)
+	rec := httptest.NewRecorder()
+
+	echoContenx := e.NewContext(req, rec)
+
+	err := c.ConfigWithOverrides(echoContenx.QueryParams(), echoContenx)
+	assert.NoError(t, err, "ConfigWithOverrides should not return an error")
+
+	assert.Equal(t, []string{"ALBUM_1", "ALBUM_2", "ALBUM_3"}, c.Albums, "Weight suffixes should be removed from album IDs")
+	assert.Equal(t, []string{"PERSON_1@user"}, c.People, "Weight suffixes should be removed from person IDs")
+
+	w, ok := c.BucketWeight(kiosk.SourceAlbum, "ALBUM_1")
+	assert.True(t, ok)
+	assert.InDelta(t, 3.0, w.Multiplier, 0.0001)
+
+	w, ok = c.BucketWeight(kiosk.SourceAlbum, "ALBUM_2")
+	assert.True(t, ok)
+	assert.InDelta(t, 25.0, w.Share, 0.0001)
+
+	_, ok = c.BucketWeight(kiosk.SourceAlbum, "ALBUM_3")
+	assert.False(t, ok)
+
+	w, ok = c.BucketWeight(kiosk.SourcePerson, "PERSON_1@user")
+	assert.True(t, ok)
+	assert.InDelta(t, 0.5, w.Multiplier, 0.0001)
+}
+
+func TestBucketWeightsQueriesReplaceConfigWeights(t *testing.T) {
+	c := New()
+	c.Albums = []string{"ALBUM_1:3", "ALBUM_2:25%"}
+	c.Tags = []string{"Trip:2024"}
+	c.checkBucketWeights()
+
+	assert.Equal(t, []string{"Trip:2024"}, c.Tags, "Tags should keep their full name")
+
+	e := echo.New()
+
+	q := make(url.Values)
+	q.Add("album", "ALBUM_1")
+	q.Add("album", "ALBUM_2:2")
+
+	req := httptest.NewRequest(http.MethodGet, "/?"+q.Encode(), nil)
+	rec := httptest.NewRecorder()
+
+	echoContenx := e.NewContext(req, rec)
+
+	err := c.ConfigWithOverrides(echoContenx.QueryParams(), echoContenx)
+	assert.NoError(t, err, "ConfigWithOverrides should not return an error")
+
+	_, ok := c.BucketWeight(kiosk.SourceAlbum, "ALBUM_1")
+	assert.False(t, ok, "Albums from the URL should not keep weights from the config file")
+
+	w, ok := c.BucketWeight(kiosk.SourceAlbum, "ALBUM_2")
+	assert.True(t, ok)
+	assert.InDelta(t, 2.0, w.Multiplier, 0.0001)
+	assert.Zero(t, w.Share)
+}
+
+// TestMosaicLayoutCoversTiles tests mosaic layouts set from the URL cover their tiles
+func TestMosaicLayoutCoversTiles(t *testing.T) {
+	c := New()
+	c.ImageFit = "contain"
+
+	e := echo.New()
+
+	req := httptest.NewRequest(http.MethodGet, "/?layout=mosaic", nil)
+	rec := httptest.NewRecorder()
+
+	echoContenx := e.NewContext(req, rec)
+
+	err := c.ConfigWithOverrides(echoContenx.QueryParams(), echoContenx)
+	assert.NoError(t, err, "ConfigWithOverrides should not return an error")
+
+	assert.Equal(t, kiosk.LayoutMosaic, c.Layout)
+	assert.Equal(t, "cover", c.ImageFit, "Mosaic tiles should be covered")
+
+	c.Layout = kiosk.LayoutGrid2x2
+	c.ImageFit = "contain"
+	c.checkLayout()
+	assert.Equal(t, "contain", c.ImageFit, "Other layouts should keep their image fit")
+}
+
+func TestTagWeights(t *testing.T) {
+	c := New()
+	c.Tags = []string{"Trip:2024", "Family", "Pets:25%"}
+	c.TagWeights = map[string]string{
+		"trip:2024": "3",
+		"Family":    "25%",
+		"Garden":    "lots",
+	}
+	c.checkBucketWeights()
+
+	assert.Equal(t, []string{"Trip:2024", "Family", "Pets:25%"}, c.Tags, "Tags should keep their full name")
+
+	w, ok := c.BucketWeight(kiosk.SourceTag, "Trip:2024")
+	assert.True(t, ok, "Tag weights should match tag names regardless of case")
+	assert.InDelta(t, 3.0, w.Multiplier, 0.0001)
+
+	w, ok = c.BucketWeight(kiosk.SourceTag, "family")
+	assert.True(t, ok)
+	assert.InDelta(t, 25.0, w.Share, 0.0001)
+
+	_, ok = c.BucketWeight(kiosk.SourceTag, "Pets")
+	assert.False(t, ok, "A suffix in a tag name should not be read as a weight")
+
+	_, ok = c.BucketWeight(kiosk.SourceTag, "Garden")
+	assert.False(t, ok, "Invalid tag weights should be ignored")
+
+	e := echo.New()
+
+	q := make(url.Values)
+	q.Add("tag", "Trip:2024")
+
+	req := httptest.NewRequest(http.MethodGet, "/?"+q.Encode(), nil)
+	rec := httptest.NewRecorder()
+
+	echoContenx := e.NewContext(req, rec)
+
+	err := c.ConfigWithOverrides(echoContenx.QueryParams(), echoContenx)
+	assert.NoError(t, err, "ConfigWithOverrides should not return an error")
+
+	assert.Equal(t, []string{"Trip:2024"}, c.Tags)
+
+	w, ok = c.BucketWeight(kiosk.SourceTag, "Trip:2024")
+	assert.True(t, ok, "Tags from the URL should use the configured tag weights")
+	assert.InDelta(t, 3.0, w.Multiplier, 0.0001)
+}
@@ -9,9 +9,16 @@ import (
 	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"charm.land/log/v2"
+	"github.com/damongolding/immich-kiosk/internal/effects"
 	"github.com/damongolding/immich-kiosk/internal/kiosk"
+	"github.com/damongolding/immich-kiosk/internal/pathmatch"
+	"github.com/damongolding/immich-kiosk/internal/seen"
+	"github.com/damongolding/immich-kiosk/internal/utils"
+	"github.com/damongolding/immich-kiosk/internal/warmup"
+	"github.com/damongolding/immich-kiosk/internal/weighting"
 	"github.com/xeipuuv/gojsonschema"
 )
 
@@ -379,6 +386,93 @@ func (c *Config) checkFetchedAssetsSize() {
 	}
 }
 
+// checkWatchImmichInterval ensures Immich is not checked for changes more than every 30 seconds.
+// Negative values disable watching.
+func (c *Config) checkWatchImmichInterval() {
+	switch {
+	case c.Kiosk.WatchImmichInterval < 0:
+		log.Warn("Invalid watch_immich_interval value. Disabling", "value", c.Kiosk.WatchImmichInterval)
+		c.Kiosk.WatchImmichInterval = 0
+	case c.Kiosk.WatchImmichInterval > 0 && c.Kiosk.WatchImmichInterval < 30:
+		log.Warn("watch_immich_interval too low, setting to minimum", "value", c.Kiosk.WatchImmichInterval, "minimum", 30)
+		c.Kiosk.WatchImmichInterval = 30
+	}
+}
+
+// checkWarmUp disables an invalid warm up schedule and a negative before_sleep_end.
+func (c *Config) checkWarmUp() {
+	warmUp := &c.Kiosk.WarmUp
+
+	warmUp.Schedule = strings.TrimSpace(warmUp.Schedule)
+	if warmUp.Schedule != "" {
+		if _, err := warmup.ParseSchedule(warmUp.Schedule); err != nil {
+			log.Warn("Invalid warm_up schedule. Disabling schedule", "schedule", warmUp.Schedule, "err", err)
+			warmUp.Schedule = ""
+		}
+	}
+
+	if warmUp.BeforeSleepEnd < 0 {
+		log.Warn("Invalid warm_up before_sleep_end value. Disabling", "before_sleep_end", warmUp.BeforeSleepEnd)
+		warmUp.BeforeSleepEnd = 0
+	}
+}
+
+// checkProcessing corrects a negative worker count and a queue with no room.
+func (c *Config) checkProcessing() {
+	processing := &c.Kiosk.Processing
+
+	if processing.Workers < 0 {
+		log.Warn("Invalid processing workers value. Using one worker per CPU", "workers", processing.Workers)
+		processing.Workers = 0
+	}
+
+	if processing.QueueSize < 1 {
+		log.Warn("Invalid processing queue_size value. Using default: 64", "queue_size", processing.QueueSize)
+		processing.QueueSize = 64
+	}
+}
+
+// checkDiskCacheSize parses the image disk cache size, falling back to the default if it is invalid.
+func (c *Config) checkDiskCacheSize() {
+	c.Kiosk.DiskCacheSize = strings.TrimSpace(c.Kiosk.DiskCacheSize)
+
+	size, err := utils.ParseSize(c.Kiosk.DiskCacheSize)
+	if err != nil || size < 0 {
+		log.Warn("Invalid disk_cache_size value. Using default: 2GB", "disk_cache_size", c.Kiosk.DiskCacheSize, "err", err)
+		c.Kiosk.DiskCacheSize = "2GB"
+		size, _ = utils.ParseSize(c.Kiosk.DiskCacheSize)
+	}
+
+	c.Kiosk.DiskCacheSizeBytes = size
+}
+
+// checkCacheBackend validates the cache backend type and parses its memory limit,
+// falling back to the defaults if either is invalid.
+func (c *Config) checkCacheBackend() {
+	backend := &c.Kiosk.CacheBackend
+
+	backend.Type = strings.ToLower(strings.TrimSpace(backend.Type))
+	if !slices.Contains(kiosk.CacheBackends, backend.Type) {
+		log.Warn("Invalid cache_backend type. Using default: lru", "type", backend.Type, "valid", kiosk.CacheBackends)
+		backend.Type = kiosk.CacheBackendLRU
+	}
+
+	backend.MaxMemory = strings.TrimSpace(backend.MaxMemory)
+
+	size, err := utils.ParseSize(backend.MaxMemory)
+	if err != nil || size <= 0 {
+		log.Warn("Invalid cache_backend max_memory value. Using default: 256MB", "max_memory", backend.MaxMemory, "err", err)
+		backend.MaxMemory = "256MB"
+		size, _ = utils.ParseSize(backend.MaxMemory)
+	}
+
+	backend.MaxMemoryBytes = size
+
+	if strings.TrimSpace(backend.Path) == "" {
+		backend.Path = "./kiosk-data/cache.db"
+	}
+}
+
 // checkRedirects validates and processes the configured redirects in the Config.
 // It performs several checks and validations:
 // - Skips redirects with empty names or URLs
@@ -687,3 +781,370 @@ func (c *Config) checkFilterNewest() {
 		c.FilterNewest = 1000
 	}
 }
+
+// checkQualityFilters validates the minimum dimensions and file size and normalises
+// excluded mime types. Shorthand such as "png" is expanded to "image/png".
+func (c *Config) checkQualityFilters() {
+	if c.FilterMinWidth < 0 {
+		log.Warn("FilterMinWidth must be 0 or greater; setting to 0", "value", c.FilterMinWidth)
+		c.FilterMinWidth = 0
+	}
+
+	if c.FilterMinHeight < 0 {
+		log.Warn("FilterMinHeight must be 0 or greater; setting to 0", "value", c.FilterMinHeight)
+		c.FilterMinHeight = 0
+	}
+
+	c.FilterMinFileSizeBytes = 0
+	c.FilterMinFileSize = strings.TrimSpace(c.FilterMinFileSize)
+	if c.FilterMinFileSize != "" {
+		size, err := utils.ParseSize(c.FilterMinFileSize)
+		if err != nil {
+			log.Warn("Invalid filter_min_file_size, disabling", "value", c.FilterMinFileSize, "err", err)
+			c.FilterMinFileSize = ""
+		} else {
+			c.FilterMinFileSizeBytes = size
+		}
+	}
+
+	mimeTypes := make([]string, 0, len(c.FilterExcludeMimeTypes))
+	for _, mimeType := range c.FilterExcludeMimeTypes {
+		mimeType = strings.ToLower(strings.TrimSpace(mimeType))
+		if mimeType == "" {
+			continue
+		}
+		if !strings.Contains(mimeType, "/") {
+			mimeType = "image/" + strings.TrimPrefix(mimeType, ".")
+		}
+		if !slices.Contains(mimeTypes, mimeType) {
+			mimeTypes = append(mimeTypes, mimeType)
+		}
+	}
+	c.FilterExcludeMimeTypes = mimeTypes
+}
+
+// checkPathFilters removes path patterns that fail to compile.
+func (c *Config) checkPathFilters() {
+	valid := func(patterns []string, option string) []string {
+		var kept []string
+		for _, pattern := range patterns {
+			pattern = strings.TrimSpace(pattern)
+			if pattern == "" {
+				continue
+			}
+			if _, err := pathmatch.Compile(pattern); err != nil {
+				log.Warn("Invalid "+option+" pattern, ignoring", "pattern", pattern, "err", err)
+				continue
+			}
+			kept = append(kept, pattern)
+		}
+		return kept
+	}
+
+	c.FilterIncludePaths = valid(c.FilterIncludePaths, "filter_include_paths")
+	c.FilterExcludePaths = valid(c.FilterExcludePaths, "filter_exclude_paths")
+}
+
+// checkJourney validates the journey start date and burst window.
+// Invalid start dates are cleared so the journey starts from the oldest asset.
+func (c *Config) checkJourney() {
+	c.JourneyPeople = c.cleanupSlice(c.JourneyPeople, "PERSON_ID")
+	c.JourneyAlbums = c.cleanupSlice(c.JourneyAlbums, "ALBUM_ID")
+
+	c.JourneyStart = strings.TrimSpace(c.JourneyStart)
+	if c.JourneyStart != "" {
+		if _, err := time.Parse(time.DateOnly, c.JourneyStart); err != nil {
+			log.Warn("Invalid journey_start, expected YYYY-MM-DD. Starting from the oldest asset", "value", c.JourneyStart)
+			c.JourneyStart = ""
+		}
+	}
+
+	if c.JourneySkipBursts < 0 {
+		log.Warn("JourneySkipBursts must be 0 or greater; setting to 0", "value", c.JourneySkipBursts)
+		c.JourneySkipBursts = 0
+	}
+}
+
+// checkWeightingStrategy removes unknown weighting strategies and validates their options.
+func (c *Config) checkWeightingStrategy() {
+	var strategies []string
+
+	for _, name := range c.WeightingStrategy {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "favorites" {
+			name = weighting.StrategyFavourites
+		}
+
+		switch {
+		case name == "":
+		case !slices.Contains(weighting.Names, name):
+			log.Warn("Unknown weighting_strategy, ignoring", "value", name, "valid", weighting.Names)
+		case !slices.Contains(strategies, name):
+			strategies = append(strategies, name)
+		}
+	}
+
+	c.WeightingStrategy = strategies
+
+	if c.WeightingHalfLife <= 0 {
+		log.Warn("WeightingHalfLife must be greater than 0; setting to 365", "value", c.WeightingHalfLife)
+		c.WeightingHalfLife = 365
+	}
+
+	if c.WeightingFavouriteBoost < 1 {
+		log.Warn("WeightingFavouriteBoost must be 1 or greater; setting to 1", "value", c.WeightingFavouriteBoost)
+		c.WeightingFavouriteBoost = 1
+	}
+}
+
+// checkSplitViewPairing removes unknown split view pairing strategies.
+func (c *Config) checkSplitViewPairing() {
+	var pairings []string
+
+	for _, name := range c.SplitViewPairing {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "similar-color" {
+			name = kiosk.PairingSimilarColour
+		}
+
+		switch {
+		case name == "":
+		case !slices.Contains(kiosk.SplitViewPairings, name):
+			log.Warn("Unknown split_view_pairing, ignoring", "value", name, "valid", kiosk.SplitViewPairings)
+		case !slices.Contains(pairings, name):
+			pairings = append(pairings, name)
+		}
+	}
+
+	c.SplitViewPairing = pairings
+}
+
+// checkStory validates the story pace and length.
+func (c *Config) checkStory() {
+	if c.StoryDuration < 1 {
+		log.Warn("StoryDuration must be at least 1 second; setting to 10", "value", c.StoryDuration)
+		c.StoryDuration = 10
+	}
+
+	if c.StoryMaxAssets < 0 {
+		log.Warn("StoryMaxAssets must be 0 or greater; setting to 0", "value", c.StoryMaxAssets)
+		c.StoryMaxAssets = 0
+	}
+}
+
+// checkImageFormat validates the processed image format and quality.
+func (c *Config) checkImageFormat() {
+	c.ImageFormat = strings.ToLower(strings.TrimSpace(c.ImageFormat))
+	if c.ImageFormat == "jpg" {
+		c.ImageFormat = kiosk.ImageFormatJpeg
+	}
+
+	if !slices.Contains(kiosk.ImageFormats, c.ImageFormat) {
+		log.Warn("Unknown image_format; setting to auto", "value", c.ImageFormat, "valid", kiosk.ImageFormats)
+		c.ImageFormat = kiosk.ImageFormatAuto
+	}
+
+	if c.ImageQuality < 0 || c.ImageQuality > 100 {
+		log.Warn("ImageQuality must be between 0 and 100; setting to 0", "value", c.ImageQuality)
+		c.ImageQuality = 0
+	}
+}
+
+// checkBackgroundStyle falls back to blurred backgrounds for unknown background styles.
+func (c *Config) checkBackgroundStyle() {
+	c.BackgroundStyle = strings.ToLower(strings.TrimSpace(c.BackgroundStyle))
+	if c.BackgroundStyle == "color" {
+		c.BackgroundStyle = kiosk.BackgroundStyleColour
+	}
+
+	if !slices.Contains(kiosk.BackgroundStyles, c.BackgroundStyle) {
+		log.Warn("Unknown background_style; setting to blur", "value", c.BackgroundStyle, "valid", kiosk.BackgroundStyles)
+		c.BackgroundStyle = kiosk.BackgroundStyleBlur
+	}
+}
+
+// checkEffects drops unknown effects and rewrites the rest as "name:amount", with
+// amounts clamped to each effect's range. Comma separated effects are split.
+func (c *Config) checkEffects() {
+	parsed, err := effects.ParseAll(c.Effects)
+	if err != nil {
+		log.Warn("Ignoring invalid effects", "err", err, "valid", effects.Names())
+	}
+
+	c.Effects = make([]string, len(parsed))
+	for i, effect := range parsed {
+		c.Effects[i] = effect.String()
+	}
+}
+
+// checkLayout covers mosaic tiles with their assets. Each tile matches its asset's aspect
+// ratio, so covering it crops very little.
+func (c *Config) checkLayout() {
+	if c.Layout == kiosk.LayoutMosaic && c.ImageFit != "cover" {
+		log.Debug("Mosaic layout; setting image_fit to cover", "value", c.ImageFit)
+		c.ImageFit = "cover"
+	}
+}
+
+// checkFacePrivacy falls back to blurring faces for unknown face privacy modes, so a typo
+// never shows faces that were meant to be hidden.
+func (c *Config) checkFacePrivacy() {
+	c.FacePrivacy = strings.ToLower(strings.TrimSpace(c.FacePrivacy))
+	if c.FacePrivacy == "" {
+		c.FacePrivacy = kiosk.FacePrivacyOff
+	}
+
+	if !slices.Contains(kiosk.FacePrivacyModes, c.FacePrivacy) {
+		log.Warn("Unknown face_privacy; setting to blur", "value", c.FacePrivacy, "valid", kiosk.FacePrivacyModes)
+		c.FacePrivacy = kiosk.FacePrivacyBlur
+	}
+
+	c.FacePrivacyAllowedPeople = c.cleanupSlice(c.FacePrivacyAllowedPeople, "PERSON_ID")
+}
+
+// checkNoRepeat clamps the no-repeat window to the range the seen record keeps.
+func (c *Config) checkNoRepeat() {
+	maxDays := int(seen.MaxWindow.Hours() / 24)
+
+	switch {
+	case c.NoRepeatDays < 0:
+		log.Warn("NoRepeatDays must be 0 or greater; setting to 0", "value", c.NoRepeatDays)
+		c.NoRepeatDays = 0
+	case c.NoRepeatDays > maxDays:
+		log.Warn("NoRepeatDays is too large; setting to maximum", "value", c.NoRepeatDays, "max", maxDays)
+		c.NoRepeatDays = maxDays
+	}
+
+	c.NoRepeatGroup = strings.TrimSpace(c.NoRepeatGroup)
+}
+
+// splitBucketWeight separates a custom weight suffix from a bucket ID.
+// "ID:3" sets a weight multiplier of 3 and "ID:25%" a fixed 25% share.
+// IDs without a valid suffix are returned unchanged.
+func splitBucketWeight(item string) (string, BucketWeight, bool) {
+	id, suffix, found := cutLast(item, ":")
+	if !found || id == "" {
+		return item, BucketWeight{}, false
+	}
+
+	weight, ok := parseBucketWeight(suffix)
+	if !ok {
+		return item, BucketWeight{}, false
+	}
+
+	return strings.TrimSpace(id), weight, true
+}
+
+// parseBucketWeight parses a custom weight, "3" for a weight multiplier of 3 or "25%"
+// for a fixed 25% share.
+func parseBucketWeight(s string) (BucketWeight, bool) {
+	s = strings.TrimSpace(s)
+
+	if share, isShare := strings.CutSuffix(s, "%"); isShare {
+		value, err := strconv.ParseFloat(share, 64)
+		if err != nil || value <= 0 || value > 100 {
+			return BucketWeight{}, false
+		}
+		return BucketWeight{Share: value}, true
+	}
+
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil || value <= 0 {
+		return BucketWeight{}, false
+	}
+
+	return BucketWeight{Multiplier: value}, true
+}
+
+// cutLast slices s around the last instance of sep.
+func cutLast(s, sep string) (string, string, bool) {
+	i := strings.LastIndex(s, sep)
+	if i < 0 {
+		return s, "", false
+	}
+	return s[:i], s[i+len(sep):], true
+}
+
+// BucketWeightKey returns the key used to store a custom weight for a bucket.
+func BucketWeightKey(source kiosk.Source, id string) string {
+	return string(source) + ":" + id
+}
+
+// TagWeightKey returns the key used to store a custom weight for a tag. Tag names are
+// matched regardless of case, as config keys are read in lower case.
+func TagWeightKey(tag string) string {
+	return BucketWeightKey(kiosk.SourceTag, strings.ToLower(tag))
+}
+
+// BucketWeight returns the custom weight for a bucket, if one was set.
+// Tags are looked up by name.
+func (c *Config) BucketWeight(source kiosk.Source, id string) (BucketWeight, bool) {
+	key := BucketWeightKey(source, id)
+	if source == kiosk.SourceTag {
+		key = TagWeightKey(id)
+	}
+
+	w, ok := c.BucketWeights[key]
+	return w, ok
+}
+
+// checkBucketWeights strips custom weight suffixes (e.g. "ID:3" or "ID:25%") from
+// people, albums and dates, storing the parsed weights in BucketWeights. Weights already
+// held, such as those from the config file, are kept unless the same bucket was given a
+// new weight. Tag names may contain ":", so tags are weighted by TagWeights instead.
+// New slices and a new map are created so copies of the config are not modified.
+func (c *Config) checkBucketWeights() {
+	weights := make(map[string]BucketWeight, len(c.BucketWeights))
+
+	strip := func(source kiosk.Source, items []string) []string {
+		out := make([]string, 0, len(items))
+		for _, item := range items {
+			id, weight, ok := splitBucketWeight(item)
+			if ok {
+				weights[BucketWeightKey(source, id)] = weight
+			}
+			out = append(out, id)
+		}
+		return out
+	}
+
+	c.People = strip(kiosk.SourcePerson, c.People)
+	c.Albums = strip(kiosk.SourceAlbum, c.Albums)
+	c.Dates = strip(kiosk.SourceDateRange, c.Dates)
+
+	for tag, value := range c.TagWeights {
+		weight, ok := parseBucketWeight(value)
+		if !ok {
+			log.Warn("Invalid tag weight; ignoring", "tag", tag, "weight", value)
+			continue
+		}
+		weights[TagWeightKey(tag)] = weight
+	}
+
+	for key, weight := range c.BucketWeights {
+		if _, set := weights[key]; !set {
+			weights[key] = weight
+		}
+	}
+
+	if c.MemoryShare < 0 || c.MemoryShare > 100 {
+		log.Warn("MemoryShare must be between 0 and 100; disabling", "value", c.MemoryShare)
+		c.MemoryShare = 0
+	}
+
+	if c.JourneyShare < 0 || c.JourneyShare > 100 {
+		log.Warn("JourneyShare must be between 0 and 100; disabling", "value", c.JourneyShare)
+		c.JourneyShare = 0
+	}
+
+	totalShare := c.MemoryShare + c.JourneyShare
+	for _, w := range weights {
+		totalShare += w.Share
+	}
+
+	if totalShare > 100 {
+		log.Warn("Bucket shares add up to more than 100%, shares will be scaled down", "total", totalShare)
+	}
+
+	c.BucketWeights = weights
+}
@@ -0,0 +1,351 @@
+// Package deck deals assets like a shuffled deck of cards: every asset matching a
+// source is shown once, in a random order, before any asset is repeated.
+//
+// Decks are kept per device and source. Assets added to a source after the deck was
+// shuffled are inserted at random positions into the remaining deck, and assets that
+// no longer match the source are dropped from it.
+//
+// A dealt asset only counts as shown once Played is called for it. Assets dealt but not
+// played, such as those rejected for their orientation, are returned to the remaining
+// deck when the next hand is dealt, so they are still shown later in the cycle. Each
+// asset is returned at most once a cycle, so assets that are always rejected cannot
+// stop the deck from being reshuffled.
+//
+// Decks are always tracked in memory. Once Initialize has been called they are also
+// written to disk, shortly after changing, so a deck survives restarts.
+package deck
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"charm.land/log/v2"
+)
+
+const (
+	// DefaultPath is the file decks are persisted to
+	DefaultPath = "./kiosk-data/decks.json"
+
+	// maxDeckAge decks not dealt from within this window are pruned
+	maxDeckAge = 90 * 24 * time.Hour
+
+	// saveDelay batches changes into a single write
+	saveDelay = 30 * time.Second
+)
+
+// Deck holds the asset IDs still to be dealt, those in the current hand and those
+// already played.
+type Deck struct {
+	// Remaining asset IDs yet to be dealt, in the order they will be dealt
+	Remaining []string `json:"remaining"`
+	// Hand asset IDs dealt in the last hand that have not been played yet
+	Hand []string `json:"hand"`
+	// Dealt asset IDs played since the deck was last shuffled
+	Dealt []string `json:"dealt"`
+	// Returned asset IDs returned to the deck unplayed since it was last shuffled
+	Returned []string `json:"returned"`
+	// UpdatedAt when the deck was last dealt from
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+var (
+	mu    sync.Mutex
+	decks = map[string]*Deck{}
+
+	path      = DefaultPath
+	persist   = false
+	saveTimer *time.Timer
+)
+
+// key returns the key a device's deck for a source is stored under.
+func key(deviceID, deckID string) string {
+	return deviceID + "|" + deckID
+}
+
+// Initialize enables persisting decks to disk and loads any previously persisted
+// decks from filePath. An empty filePath uses DefaultPath.
+func Initialize(filePath string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if filePath != "" {
+		path = filePath
+	}
+
+	persist = true
+	decks = map[string]*Deck{}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("reading decks: %w", err)
+	}
+
+	if err = json.Unmarshal(data, &decks); err != nil {
+		decks = map[string]*Deck{}
+		return fmt.Errorf("parsing decks: %w", err)
+	}
+
+	return nil
+}
+
+// Deal returns up to n asset IDs from a device's deck for a source.
+//
+// ids is every asset ID currently matching the source. The deck is brought up to date
+// with ids before dealing and reshuffled once every asset has been played.
+func Deal(deviceID, deckID string, ids []string, n int) []string {
+	mu.Lock()
+	defer mu.Unlock()
+
+	k := key(deviceID, deckID)
+
+	d, ok := decks[k]
+	if !ok {
+		d = &Deck{}
+		decks[k] = d
+	}
+
+	d.Sync(ids, nil)
+	dealt := d.Deal(n, nil)
+	d.UpdatedAt = time.Now()
+
+	scheduleSave()
+
+	return dealt
+}
+
+// Played marks an asset dealt from a device's deck for a source as shown.
+func Played(deviceID, deckID, id string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	d, ok := decks[key(deviceID, deckID)]
+	if !ok {
+		return
+	}
+
+	if d.Play(id) {
+		scheduleSave()
+	}
+}
+
+// HasDevice reports whether any decks are held for a device.
+func HasDevice(deviceID string) bool {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for k := range decks {
+		if strings.HasPrefix(k, key(deviceID, "")) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Reset discards a device's decks, or every deck if deviceID is empty,
+// and returns how many were discarded.
+func Reset(deviceID string) int {
+	mu.Lock()
+	defer mu.Unlock()
+
+	count := 0
+	for k := range decks {
+		if deviceID == "" || strings.HasPrefix(k, deviceID+"|") {
+			delete(decks, k)
+			count++
+		}
+	}
+
+	scheduleSave()
+
+	return count
+}
+
+// Flush writes any pending changes to disk immediately.
+func Flush() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if saveTimer != nil {
+		saveTimer.Stop()
+		saveTimer = nil
+	}
+
+	save()
+}
+
+// Sync brings the deck up to date with the asset IDs currently matching its source.
+// New IDs are inserted at random positions in the remaining deck and IDs that no longer
+// match are dropped. A nil rng uses the global random source.
+func (d *Deck) Sync(ids []string, rng *rand.Rand) {
+	intN := rand.IntN
+	if rng != nil {
+		intN = rng.IntN
+	}
+
+	current := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		current[id] = struct{}{}
+	}
+
+	known := make(map[string]struct{}, len(d.Remaining)+len(d.Hand)+len(d.Dealt))
+
+	keep := func(list []string) []string {
+		kept := list[:0]
+		for _, id := range list {
+			if _, ok := current[id]; !ok {
+				continue
+			}
+			if _, dup := known[id]; dup {
+				continue
+			}
+			known[id] = struct{}{}
+			kept = append(kept, id)
+		}
+		return kept
+	}
+
+	d.Remaining = keep(d.Remaining)
+	d.Hand = keep(d.Hand)
+	d.Dealt = keep(d.Dealt)
+	d.Returned = slices.DeleteFunc(d.Returned, func(id string) bool {
+		_, ok := current[id]
+		return !ok
+	})
+
+	for _, id := range ids {
+		if _, ok := known[id]; ok {
+			continue
+		}
+		known[id] = struct{}{}
+
+		d.insert(id, intN)
+	}
+}
+
+// insert adds id to the remaining deck at a random position.
+func (d *Deck) insert(id string, intN func(int) int) {
+	i := intN(len(d.Remaining) + 1)
+	d.Remaining = append(d.Remaining, "")
+	copy(d.Remaining[i+1:], d.Remaining[i:])
+	d.Remaining[i] = id
+}
+
+// Play moves id from the hand to the played IDs, reporting whether it was in the hand.
+func (d *Deck) Play(id string) bool {
+	i := slices.Index(d.Hand, id)
+	if i < 0 {
+		return false
+	}
+
+	d.Hand = slices.Delete(d.Hand, i, i+1)
+	d.Dealt = append(d.Dealt, id)
+
+	return true
+}
+
+// Deal returns up to n IDs from the top of the deck as the new hand. IDs left unplayed
+// in the previous hand are first returned to random positions in the deck, unless they
+// have already been returned once this cycle. When the deck is empty the played IDs are
+// shuffled back in. A nil rng uses the global random source.
+func (d *Deck) Deal(n int, rng *rand.Rand) []string {
+	intN := rand.IntN
+	if rng != nil {
+		intN = rng.IntN
+	}
+
+	for _, id := range d.Hand {
+		if slices.Contains(d.Returned, id) {
+			d.Dealt = append(d.Dealt, id)
+			continue
+		}
+
+		d.Returned = append(d.Returned, id)
+		d.insert(id, intN)
+	}
+	d.Hand = nil
+
+	if len(d.Remaining) == 0 {
+		d.Remaining, d.Dealt, d.Returned = d.Dealt, nil, nil
+
+		shuffle := rand.Shuffle
+		if rng != nil {
+			shuffle = rng.Shuffle
+		}
+
+		shuffle(len(d.Remaining), func(i, j int) {
+			d.Remaining[i], d.Remaining[j] = d.Remaining[j], d.Remaining[i]
+		})
+	}
+
+	n = min(max(n, 0), len(d.Remaining))
+
+	dealt := make([]string, n)
+	copy(dealt, d.Remaining[:n])
+
+	d.Remaining = d.Remaining[n:]
+	d.Hand = append(d.Hand, dealt...)
+
+	return dealt
+}
+
+// scheduleSave queues a write to disk if one is not already pending. mu must be held.
+func scheduleSave() {
+	if !persist || saveTimer != nil {
+		return
+	}
+
+	saveTimer = time.AfterFunc(saveDelay, func() {
+		mu.Lock()
+		defer mu.Unlock()
+
+		saveTimer = nil
+		save()
+	})
+}
+
+// save writes decks to disk. mu must be held.
+func save() {
+	cutoff := time.Now().Add(-maxDeckAge)
+	for k, d := range decks {
+		if d.UpdatedAt.Before(cutoff) {
+			delete(decks, k)
+		}
+	}
+
+	if !persist {
+		return
+	}
+
+	data, err := json.Marshal(decks)
+	if err != nil {
+		log.Error("Failed to marshal decks", "err", err)
+		return
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		log.Error("Failed to create decks directory", "err", err)
+		return
+	}
+
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0o600); err != nil {
+		log.Error("Failed to write decks", "err", err)
+		return
+	}
+
+	if err = os.Rename(tmp, path); err != nil {
+		log.Error("Failed to save decks", "err", err)
+	}
+}
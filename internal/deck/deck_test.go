@@ -0,0 +1,124 @@
+package deck
+
+import (
+	"math/rand/v2"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDealEveryAssetOnce(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	ids := []string{"a", "b", "c", "d", "e"}
+
+	d := &Deck{}
+	d.Sync(ids, rng)
+
+	var dealt []string
+	for range len(ids) {
+		hand := d.Deal(1, rng)
+		for _, id := range hand {
+			d.Play(id)
+		}
+		dealt = append(dealt, hand...)
+	}
+
+	assert.ElementsMatch(t, ids, dealt)
+	assert.Empty(t, d.Remaining)
+
+	// the next deal reshuffles
+	next := d.Deal(2, rng)
+	assert.Len(t, next, 2)
+	assert.Len(t, d.Remaining, 3)
+}
+
+func TestSyncInsertsNewAndDropsRemoved(t *testing.T) {
+	rng := rand.New(rand.NewPCG(3, 4))
+
+	d := &Deck{}
+	d.Sync([]string{"a", "b", "c"}, rng)
+
+	dealt := d.Deal(1, rng)
+	require.Len(t, dealt, 1)
+	require.True(t, d.Play(dealt[0]))
+
+	// one asset removed, one added
+	removed := d.Remaining[0]
+	current := slices.DeleteFunc([]string{"a", "b", "c"}, func(id string) bool { return id == removed })
+	current = append(current, "new")
+
+	d.Sync(current, rng)
+
+	assert.Contains(t, d.Remaining, "new")
+	assert.NotContains(t, d.Remaining, removed)
+	assert.Equal(t, dealt, d.Dealt, "played assets stay played")
+	assert.Len(t, d.Remaining, 2)
+}
+
+func TestUnplayedAssetsAreDealtLaterInTheCycle(t *testing.T) {
+	rng := rand.New(rand.NewPCG(5, 6))
+	ids := []string{"a", "b", "c", "d", "e", "f"}
+
+	d := &Deck{}
+	d.Sync(ids, rng)
+
+	// the first hand's second asset is rejected, for example for its orientation
+	hand := d.Deal(2, rng)
+	require.Len(t, hand, 2)
+	require.True(t, d.Play(hand[0]))
+	rejected := hand[1]
+
+	var later []string
+	for len(d.Dealt) < len(ids) {
+		next := d.Deal(1, rng)
+		require.Len(t, next, 1)
+		require.True(t, d.Play(next[0]))
+		later = append(later, next[0])
+	}
+
+	assert.Contains(t, later, rejected, "a rejected asset is dealt again before the deck is reshuffled")
+	assert.ElementsMatch(t, ids, d.Dealt)
+}
+
+func TestAlwaysRejectedAssetsDoNotBlockReshuffle(t *testing.T) {
+	rng := rand.New(rand.NewPCG(7, 8))
+	ids := []string{"a", "b", "c"}
+
+	d := &Deck{}
+	d.Sync(ids, rng)
+
+	// nothing is ever played, each asset is returned once then given up on
+	for range 10 {
+		d.Deal(3, rng)
+	}
+
+	assert.Len(t, d.Deal(3, rng), 3, "the deck reshuffles rather than running dry")
+}
+
+func TestDealEmpty(t *testing.T) {
+	d := &Deck{}
+	assert.Empty(t, d.Deal(3, nil))
+}
+
+func TestPersistence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "decks.json")
+
+	require.NoError(t, Initialize(file))
+
+	first := Deal("device", "random", []string{"a", "b", "c"}, 1)
+	require.Len(t, first, 1)
+	Played("device", "random", first[0])
+	Flush()
+
+	// reload from disk
+	require.NoError(t, Initialize(file))
+
+	rest := Deal("device", "random", []string{"a", "b", "c"}, 2)
+	assert.ElementsMatch(t, []string{"a", "b", "c"}, append(first, rest...))
+
+	assert.Equal(t, 1, Reset("device"))
+	assert.Equal(t, 0, Reset("device"))
+}
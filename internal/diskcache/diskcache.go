@@ -0,0 +1,399 @@
+// Package diskcache keeps images fetched from Immich and processed by Kiosk on disk, so
+// they survive restarts.
+//
+// Entries are files named after the asset they belong to and a hash of the parameters
+// they were processed with. Once the cache grows past its size limit the least recently
+// used files are removed. When an entry is used its modification time is updated, so the
+// order is kept across restarts.
+//
+// The cache does nothing until Initialize has been called.
+package diskcache
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"charm.land/log/v2"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/damongolding/immich-kiosk/internal/utils"
+)
+
+const (
+	// DefaultPath is the directory cached files are kept in
+	DefaultPath = "./kiosk-data/image-cache"
+
+	// tmpSuffix is added to files while they are being written
+	tmpSuffix = ".tmp"
+)
+
+// safeAssetID matches asset IDs that can be used in a file name as they are
+var safeAssetID = regexp.MustCompile(`^[A-Za-z0-9-]{1,64}$`)
+
+// entry is a cached file.
+type entry struct {
+	key  string
+	size int64
+}
+
+var (
+	mu      sync.Mutex
+	dir     = DefaultPath
+	enabled = false
+	files   = map[string]*list.Element{}
+	recent  = list.New()
+	size    int64
+	maxSize int64
+
+	building singleflight.Group
+
+	// losslessEncoder encodes cached images. Speed is favoured over size, as images are
+	// cached on the request path.
+	losslessEncoder = png.Encoder{CompressionLevel: png.BestSpeed}
+)
+
+// Initialize enables the cache, keeping up to maxBytes of files in path. Files left by
+// a previous run are kept, oldest first when the cache is over its limit.
+// An empty path uses DefaultPath and a maxBytes of 0 or less leaves the cache disabled.
+func Initialize(path string, maxBytes int64) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if path != "" {
+		dir = path
+	}
+
+	files = map[string]*list.Element{}
+	recent.Init()
+	size = 0
+	maxSize = maxBytes
+	enabled = false
+
+	if maxBytes <= 0 {
+		return nil
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("creating image cache: %w", err)
+	}
+
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("reading image cache: %w", err)
+	}
+
+	type existing struct {
+		entry
+		modTime time.Time
+	}
+
+	found := make([]existing, 0, len(dirEntries))
+	for _, dirEntry := range dirEntries {
+		if dirEntry.IsDir() {
+			continue
+		}
+
+		// partially written files from a previous run
+		if strings.HasSuffix(dirEntry.Name(), tmpSuffix) {
+			_ = os.Remove(filepath.Join(dir, dirEntry.Name()))
+			continue
+		}
+
+		info, infoErr := dirEntry.Info()
+		if infoErr != nil {
+			continue
+		}
+
+		found = append(found, existing{entry: entry{key: dirEntry.Name(), size: info.Size()}, modTime: info.ModTime()})
+	}
+
+	// oldest first, so the most recently used end up at the front
+	slices.SortFunc(found, func(a, b existing) int {
+		return a.modTime.Compare(b.modTime)
+	})
+
+	for _, f := range found {
+		files[f.key] = recent.PushFront(f.entry)
+		size += f.size
+	}
+
+	enabled = true
+	removeFiles(dir, evict())
+
+	log.Info("Image cache", "path", dir, "files", len(files), "size", size, "maxSize", maxSize)
+
+	return nil
+}
+
+// Enabled reports whether the cache has been initialised.
+func Enabled() bool {
+	mu.Lock()
+	defer mu.Unlock()
+
+	return enabled
+}
+
+// Key returns the key an asset processed with params is cached under.
+// The same asset and params always give the same key.
+func Key(assetID string, params ...any) string {
+	hash := sha256.Sum256(fmt.Appendf(nil, "%s:%v", assetID, params))
+
+	if !safeAssetID.MatchString(assetID) {
+		return fmt.Sprintf("%x", hash)
+	}
+
+	return fmt.Sprintf("%s_%x", assetID, hash[:16])
+}
+
+// Get returns the data cached under key, marking it as recently used.
+// The file is read without holding the lock, so reads never wait on each other.
+func Get(key string) ([]byte, bool) {
+	mu.Lock()
+	_, found := files[key]
+	found = found && enabled
+	filePath := filepath.Join(dir, key)
+	mu.Unlock()
+
+	if !found {
+		return nil, false
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Error("Reading image cache", "key", key, "err", err)
+		}
+
+		mu.Lock()
+		if el, stillFound := files[key]; stillFound {
+			forget(el)
+		}
+		mu.Unlock()
+
+		return nil, false
+	}
+
+	mu.Lock()
+	if el, stillFound := files[key]; stillFound {
+		recent.MoveToFront(el)
+	}
+	mu.Unlock()
+
+	now := time.Now()
+	_ = os.Chtimes(filePath, now, now)
+
+	return data, true
+}
+
+// Set caches data under key, removing the least recently used files if the cache grows
+// past its size limit. The file is written without holding the lock, only the index is
+// updated under it.
+func Set(key string, data []byte) {
+	mu.Lock()
+	ok := enabled && int64(len(data)) <= maxSize
+	cacheDir := dir
+	mu.Unlock()
+
+	if !ok {
+		return
+	}
+
+	if err := writeFileAtomic(cacheDir, key, data); err != nil {
+		log.Error("Writing image cache", "key", key, "err", err)
+		return
+	}
+
+	mu.Lock()
+
+	if el, found := files[key]; found {
+		size -= recent.Remove(el).(entry).size
+		delete(files, key)
+	}
+
+	files[key] = recent.PushFront(entry{key: key, size: int64(len(data))})
+	size += int64(len(data))
+
+	evicted := evict()
+
+	mu.Unlock()
+
+	removeFiles(cacheDir, evicted)
+}
+
+// Bytes returns the data cached under key. If nothing is cached, build is called and
+// what it returns is cached. Concurrent calls for the same key only build once.
+func Bytes(key string, build func() ([]byte, error)) ([]byte, error) {
+	if data, found := Get(key); found {
+		return data, nil
+	}
+
+	v, err, _ := building.Do(key, func() (any, error) {
+		data, buildErr := build()
+		if buildErr != nil {
+			return nil, buildErr
+		}
+
+		Set(key, data)
+
+		return data, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return v.([]byte), nil
+}
+
+// Image returns the image cached under key. If nothing is cached, build is called and
+// the image it returns is cached as a PNG, so caching never loses detail or
+// transparency. Concurrent calls for the same key only build once, and share the image,
+// which must not be modified.
+func Image(key string, build func() (image.Image, error)) (image.Image, error) {
+	if img, found := cachedImage(key); found {
+		return img, nil
+	}
+
+	v, err, _ := building.Do(key, func() (any, error) {
+		if img, found := cachedImage(key); found {
+			return img, nil
+		}
+
+		img, buildErr := build()
+		if buildErr != nil {
+			return nil, buildErr
+		}
+
+		if Enabled() {
+			var buf bytes.Buffer
+			if encodeErr := losslessEncoder.Encode(&buf, img); encodeErr != nil {
+				log.Error("Encoding cached image", "key", key, "err", encodeErr)
+			} else {
+				Set(key, buf.Bytes())
+			}
+		}
+
+		return img, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return v.(image.Image), nil
+}
+
+// cachedImage decodes the image cached under key.
+func cachedImage(key string) (image.Image, bool) {
+	data, found := Get(key)
+	if !found {
+		return nil, false
+	}
+
+	img, _, err := utils.BytesToImage(data, false)
+	if err != nil {
+		log.Error("Decoding cached image", "key", key, "err", err)
+		return nil, false
+	}
+
+	return img, true
+}
+
+// Flush removes every cached file.
+func Flush() {
+	mu.Lock()
+
+	cacheDir := dir
+	removed := make([]string, 0, recent.Len())
+	for recent.Len() > 0 {
+		removed = append(removed, forget(recent.Back()))
+	}
+
+	mu.Unlock()
+
+	removeFiles(cacheDir, removed)
+}
+
+// Size returns the number of cached files and their total size in bytes.
+func Size() (int, int64) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	return len(files), size
+}
+
+// forget removes a cached file from the index and returns its key, so the file can be
+// deleted once mu has been released. Callers must hold mu.
+func forget(el *list.Element) string {
+	e := recent.Remove(el).(entry)
+	delete(files, e.key)
+	size -= e.size
+
+	return e.key
+}
+
+// evict removes the least recently used files from the index until the cache is within
+// maxSize and returns their keys, so the files can be deleted once mu has been released.
+// Callers must hold mu.
+func evict() []string {
+	var evicted []string
+
+	for size > maxSize && recent.Len() > 0 {
+		evicted = append(evicted, forget(recent.Back()))
+	}
+
+	return evicted
+}
+
+// removeFiles deletes the cached files of keys from cacheDir. It is called without
+// holding mu; should a key be cached again meanwhile, the next Get simply misses.
+func removeFiles(cacheDir string, keys []string) {
+	for _, key := range keys {
+		if err := os.Remove(filepath.Join(cacheDir, key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			log.Error("Removing from image cache", "key", key, "err", err)
+		}
+	}
+}
+
+// writeFileAtomic writes data to a temporary file then renames it into place as key,
+// so a crash never leaves a partially written file. Each write has its own temporary
+// file, so concurrent writes of the same key do not interfere.
+func writeFileAtomic(cacheDir, key string, data []byte) error {
+	tmp, err := os.CreateTemp(cacheDir, key+".*"+tmpSuffix)
+	if err != nil {
+		return err
+	}
+
+	tmpPath := tmp.Name()
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Chmod(tmpPath, 0o644)
+	}
+
+	if err == nil {
+		err = os.Rename(tmpPath, filepath.Join(cacheDir, key))
+	}
+
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+
+	return nil
+}
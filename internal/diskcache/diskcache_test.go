@@ -0,0 +1,225 @@
+package diskcache
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func initialize(t *testing.T, maxBytes int64) string {
+	t.Helper()
+
+	path := t.TempDir()
+	require.NoError(t, Initialize(path, maxBytes))
+	t.Cleanup(func() {
+		_ = Initialize(path, 0)
+	})
+
+	return path
+}
+
+func TestSetAndGet(t *testing.T) {
+	path := initialize(t, 1024)
+
+	key := Key("asset-1", "preview", false)
+	assert.True(t, strings.HasPrefix(key, "asset-1_"))
+	assert.Equal(t, key, Key("asset-1", "preview", false), "the same asset and params have the same key")
+	assert.NotEqual(t, key, Key("asset-1", "preview", true))
+
+	_, found := Get(key)
+	assert.False(t, found)
+
+	Set(key, []byte("image"))
+
+	data, found := Get(key)
+	require.True(t, found)
+	assert.Equal(t, []byte("image"), data)
+	assert.FileExists(t, filepath.Join(path, key))
+
+	count, size := Size()
+	assert.Equal(t, 1, count)
+	assert.Equal(t, int64(len("image")), size)
+}
+
+func TestEvictsLeastRecentlyUsed(t *testing.T) {
+	path := initialize(t, 10)
+
+	Set("first", []byte("aaaa"))
+	Set("second", []byte("bbbb"))
+
+	// using the first file makes the second the least recently used
+	_, found := Get("first")
+	require.True(t, found)
+
+	Set("third", []byte("cccc"))
+
+	_, found = Get("second")
+	assert.False(t, found)
+	assert.NoFileExists(t, filepath.Join(path, "second"))
+
+	for _, key := range []string{"first", "third"} {
+		_, found = Get(key)
+		assert.True(t, found)
+	}
+}
+
+func TestInitializeKeepsFilesFromPreviousRun(t *testing.T) {
+	path := t.TempDir()
+
+	old := time.Now().Add(-time.Hour)
+	for i, name := range []string{"old", "new"} {
+		filePath := filepath.Join(path, name)
+		require.NoError(t, os.WriteFile(filePath, []byte("data"), 0o644))
+		modTime := old.Add(time.Duration(i) * time.Minute)
+		require.NoError(t, os.Chtimes(filePath, modTime, modTime))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(path, "partial"+tmpSuffix), []byte("da"), 0o644))
+
+	require.NoError(t, Initialize(path, 6))
+	t.Cleanup(func() {
+		_ = Initialize(path, 0)
+	})
+
+	_, found := Get("new")
+	assert.True(t, found)
+
+	_, found = Get("old")
+	assert.False(t, found, "the least recently used file is removed when over the limit")
+	assert.NoFileExists(t, filepath.Join(path, "partial"+tmpSuffix))
+}
+
+func TestBytesBuildsOnce(t *testing.T) {
+	initialize(t, 1024)
+
+	builds := 0
+	build := func() ([]byte, error) {
+		builds++
+		return []byte("blurred"), nil
+	}
+
+	for range 2 {
+		data, err := Bytes("blur", build)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("blurred"), data)
+	}
+	assert.Equal(t, 1, builds)
+
+	_, err := Bytes("failing", func() ([]byte, error) {
+		return nil, errors.New("failed")
+	})
+	require.Error(t, err)
+
+	_, found := Get("failing")
+	assert.False(t, found)
+}
+
+func TestImage(t *testing.T) {
+	initialize(t, 1<<20)
+
+	build := func() (image.Image, error) {
+		return image.NewRGBA(image.Rect(0, 0, 16, 9)), nil
+	}
+
+	img, err := Image("optimized", build)
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 16, 9), img.Bounds())
+
+	cached, err := Image("optimized", func() (image.Image, error) {
+		return nil, errors.New("should be cached")
+	})
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 16, 9), cached.Bounds())
+}
+
+func TestImageIsLossless(t *testing.T) {
+	initialize(t, 1<<20)
+
+	src := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	src.SetNRGBA(1, 2, color.NRGBA{R: 201, G: 13, B: 77, A: 128})
+
+	_, err := Image("lossless", func() (image.Image, error) { return src, nil })
+	require.NoError(t, err)
+
+	cached, err := Image("lossless", func() (image.Image, error) {
+		return nil, errors.New("should be cached")
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, color.NRGBAModel.Convert(src.At(1, 2)), color.NRGBAModel.Convert(cached.At(1, 2)))
+	assert.Equal(t, color.NRGBAModel.Convert(src.At(0, 0)), color.NRGBAModel.Convert(cached.At(0, 0)))
+}
+
+func TestImageBuildsOnce(t *testing.T) {
+	initialize(t, 1<<20)
+
+	var builds atomic.Int32
+	release := make(chan struct{})
+
+	build := func() (image.Image, error) {
+		builds.Add(1)
+		<-release
+		return image.NewRGBA(image.Rect(0, 0, 2, 2)), nil
+	}
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Go(func() {
+			_, err := Image("concurrent", build)
+			assert.NoError(t, err)
+		})
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), builds.Load())
+}
+
+func TestConcurrentSetAndGet(t *testing.T) {
+	path := initialize(t, 64)
+
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Go(func() {
+			for j := range 20 {
+				key := fmt.Sprintf("key-%d", (i+j)%4)
+				Set(key, []byte(strings.Repeat("x", 10)))
+				if data, found := Get(key); found {
+					assert.Len(t, data, 10)
+				}
+			}
+		})
+	}
+	wg.Wait()
+
+	_, size := Size()
+	assert.LessOrEqual(t, size, int64(64))
+
+	entries, err := os.ReadDir(path)
+	require.NoError(t, err)
+	for _, entry := range entries {
+		assert.False(t, strings.HasSuffix(entry.Name(), tmpSuffix), "no temporary files should be left behind")
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	initialize(t, 0)
+
+	Set("key", []byte("data"))
+
+	_, found := Get("key")
+	assert.False(t, found)
+	assert.False(t, Enabled())
+}
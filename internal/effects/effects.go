@@ -0,0 +1,264 @@
+// Package effects applies server side image effects, such as grayscale, sepia, vignette
+// and film grain, so each frame can be given its own look without touching the
+// originals in Immich.
+//
+// Effects are written as "name" or "name:amount" and chained in order, e.g.
+// "grayscale,vignette:0.3,grain:0.1". Each effect is a small Filter registered under its
+// name, with a default amount used when none is given and a range amounts are clamped to.
+package effects
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+// Filter applies an effect to img at amount. Filters may modify img in place.
+type Filter func(img *image.NRGBA, amount float64) *image.NRGBA
+
+// Effect is a registered effect and the amount to apply it at.
+type Effect struct {
+	Name   string
+	Amount float64
+}
+
+// definition a registered filter and the amounts it accepts.
+type definition struct {
+	filter        Filter
+	defaultAmount float64
+	minAmount     float64
+	maxAmount     float64
+}
+
+// ErrUnknownEffect is returned when parsing an effect that has not been registered.
+var ErrUnknownEffect = errors.New("unknown effect")
+
+var (
+	registry = map[string]definition{}
+
+	// aliases alternative spellings of effect names
+	aliases = map[string]string{
+		"greyscale": "grayscale",
+	}
+)
+
+func init() {
+	Register("grayscale", 1, 0, 1, grayscale)
+	Register("sepia", 1, 0, 1, sepia)
+	Register("vignette", 0.3, 0, 1, vignette)
+	Register("grain", 0.1, 0, 1, grain)
+	Register("contrast", 0.2, -1, 1, contrast)
+	Register("brightness", 0.1, -1, 1, brightness)
+	Register("posterize", 4, 2, 64, posterize)
+}
+
+// Register adds filter as the effect name. Amounts outside minAmount to maxAmount are
+// clamped and defaultAmount is used when no amount is given. Registering a name again
+// replaces its filter.
+func Register(name string, defaultAmount, minAmount, maxAmount float64, filter Filter) {
+	registry[strings.ToLower(name)] = definition{
+		filter:        filter,
+		defaultAmount: defaultAmount,
+		minAmount:     minAmount,
+		maxAmount:     maxAmount,
+	}
+}
+
+// Names returns the names of every registered effect, sorted.
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Parse parses a single "name" or "name:amount" effect.
+func Parse(spec string) (Effect, error) {
+	name, amountValue, hasAmount := strings.Cut(strings.ToLower(strings.TrimSpace(spec)), ":")
+	name = strings.TrimSpace(name)
+
+	if alias, found := aliases[name]; found {
+		name = alias
+	}
+
+	def, found := registry[name]
+	if !found {
+		return Effect{}, fmt.Errorf("%w: %q", ErrUnknownEffect, name)
+	}
+
+	effect := Effect{Name: name, Amount: def.defaultAmount}
+
+	if hasAmount {
+		amount, err := strconv.ParseFloat(strings.TrimSpace(amountValue), 64)
+		if err != nil || math.IsNaN(amount) {
+			return Effect{}, fmt.Errorf("invalid amount for effect %q: %q", name, amountValue)
+		}
+		effect.Amount = min(def.maxAmount, max(def.minAmount, amount))
+	}
+
+	return effect, nil
+}
+
+// ParseAll parses effects, each of which may hold several comma separated effects.
+// Effects that cannot be parsed are skipped and reported in the returned error.
+func ParseAll(specs []string) ([]Effect, error) {
+	var parsed []Effect
+	var errs []error
+
+	for _, spec := range specs {
+		for part := range strings.SplitSeq(spec, ",") {
+			if strings.TrimSpace(part) == "" {
+				continue
+			}
+
+			effect, err := Parse(part)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+
+			parsed = append(parsed, effect)
+		}
+	}
+
+	return parsed, errors.Join(errs...)
+}
+
+// String returns the effect as "name:amount".
+func (e Effect) String() string {
+	return e.Name + ":" + strconv.FormatFloat(e.Amount, 'f', -1, 64)
+}
+
+// Signature returns a string identifying a chain of effects, used in cache keys.
+func Signature(chain []Effect) string {
+	parts := make([]string, len(chain))
+	for i, effect := range chain {
+		parts[i] = effect.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+// Apply applies chain to img in order, leaving img untouched. img is returned as is
+// when chain is empty.
+func Apply(img image.Image, chain []Effect) image.Image {
+	if len(chain) == 0 {
+		return img
+	}
+
+	dst := imaging.Clone(img)
+
+	for _, effect := range chain {
+		def, found := registry[effect.Name]
+		if !found {
+			continue
+		}
+
+		dst = def.filter(dst, effect.Amount)
+	}
+
+	return dst
+}
+
+// eachPixel calls fn with the position and colour channels of every pixel, storing
+// the channels fn returns.
+func eachPixel(img *image.NRGBA, fn func(x, y int, r, g, b float64) (float64, float64, float64)) *image.NRGBA {
+	bounds := img.Bounds()
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			i := img.PixOffset(x, y)
+			pix := img.Pix[i : i+3 : i+3]
+
+			r, g, b := fn(x, y, float64(pix[0]), float64(pix[1]), float64(pix[2]))
+			pix[0], pix[1], pix[2] = clamp(r), clamp(g), clamp(b)
+		}
+	}
+
+	return img
+}
+
+// mix blends from towards to by amount.
+func mix(from, to, amount float64) float64 {
+	return from + (to-from)*amount
+}
+
+// clamp rounds v to a colour channel.
+func clamp(v float64) uint8 {
+	return uint8(math.Round(min(255, max(0, v))))
+}
+
+// grayscale removes colour, amount sets how much.
+func grayscale(img *image.NRGBA, amount float64) *image.NRGBA {
+	return eachPixel(img, func(_, _ int, r, g, b float64) (float64, float64, float64) {
+		luma := 0.299*r + 0.587*g + 0.114*b
+		return mix(r, luma, amount), mix(g, luma, amount), mix(b, luma, amount)
+	})
+}
+
+// sepia tones the image brown like an old photograph, amount sets how much.
+func sepia(img *image.NRGBA, amount float64) *image.NRGBA {
+	return eachPixel(img, func(_, _ int, r, g, b float64) (float64, float64, float64) {
+		sr := 0.393*r + 0.769*g + 0.189*b
+		sg := 0.349*r + 0.686*g + 0.168*b
+		sb := 0.272*r + 0.534*g + 0.131*b
+		return mix(r, sr, amount), mix(g, sg, amount), mix(b, sb, amount)
+	})
+}
+
+// vignette darkens the image towards its corners, amount sets how dark the corners get.
+func vignette(img *image.NRGBA, amount float64) *image.NRGBA {
+	bounds := img.Bounds()
+	centreX := float64(bounds.Min.X+bounds.Max.X) / 2
+	centreY := float64(bounds.Min.Y+bounds.Max.Y) / 2
+	radius := math.Hypot(float64(bounds.Dx())/2, float64(bounds.Dy())/2)
+
+	return eachPixel(img, func(x, y int, r, g, b float64) (float64, float64, float64) {
+		distance := math.Hypot(float64(x)+0.5-centreX, float64(y)+0.5-centreY) / radius
+		factor := 1 - amount*distance*distance
+		return r * factor, g * factor, b * factor
+	})
+}
+
+// grain adds monochrome film grain, amount sets how strong. The grain is seeded by the
+// image's size, so the same image always gets the same grain and can be cached.
+func grain(img *image.NRGBA, amount float64) *image.NRGBA {
+	bounds := img.Bounds()
+	rng := rand.New(rand.NewPCG(uint64(bounds.Dx()), uint64(bounds.Dy())))
+
+	return eachPixel(img, func(_, _ int, r, g, b float64) (float64, float64, float64) {
+		noise := (rng.Float64()*2 - 1) * amount * 255
+		return r + noise, g + noise, b + noise
+	})
+}
+
+// contrast raises, or with a negative amount lowers, the image's contrast.
+func contrast(img *image.NRGBA, amount float64) *image.NRGBA {
+	return imaging.AdjustContrast(img, amount*100)
+}
+
+// brightness brightens, or with a negative amount darkens, the image.
+func brightness(img *image.NRGBA, amount float64) *image.NRGBA {
+	return imaging.AdjustBrightness(img, amount*100)
+}
+
+// posterize reduces each colour channel to amount levels, for flat e-paper like looks.
+func posterize(img *image.NRGBA, amount float64) *image.NRGBA {
+	steps := math.Round(amount) - 1
+
+	level := func(v float64) float64 {
+		return math.Round(v/255*steps) * 255 / steps
+	}
+
+	return eachPixel(img, func(_, _ int, r, g, b float64) (float64, float64, float64) {
+		return level(r), level(g), level(b)
+	})
+}
@@ -0,0 +1,87 @@
+package effects
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// solid returns a w x h image filled with c.
+func solid(w, h int, c color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		spec string
+		want Effect
+	}{
+		{"grayscale", Effect{Name: "grayscale", Amount: 1}},
+		{" Greyscale ", Effect{Name: "grayscale", Amount: 1}},
+		{"vignette", Effect{Name: "vignette", Amount: 0.3}},
+		{"vignette:0.5", Effect{Name: "vignette", Amount: 0.5}},
+		{"grain:5", Effect{Name: "grain", Amount: 1}},
+		{"contrast:-2", Effect{Name: "contrast", Amount: -1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := Parse(tt.spec)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err := Parse("sparkle")
+	require.ErrorIs(t, err, ErrUnknownEffect)
+
+	_, err = Parse("sepia:lots")
+	require.Error(t, err)
+}
+
+func TestParseAll(t *testing.T) {
+	chain, err := ParseAll([]string{"grayscale,vignette:0.3", "sparkle", "", "grain:0.1"})
+	require.ErrorIs(t, err, ErrUnknownEffect)
+
+	assert.Equal(t, "grayscale:1,vignette:0.3,grain:0.1", Signature(chain))
+}
+
+func TestApply(t *testing.T) {
+	src := solid(20, 10, color.NRGBA{R: 200, G: 100, B: 50, A: 255})
+
+	assert.Same(t, image.Image(src), Apply(src, nil))
+
+	gray := Apply(src, []Effect{{Name: "grayscale", Amount: 1}}).(*image.NRGBA)
+	c := gray.NRGBAAt(5, 5)
+	assert.Equal(t, c.R, c.G)
+	assert.Equal(t, c.G, c.B)
+
+	// the source is left untouched
+	assert.Equal(t, color.NRGBA{R: 200, G: 100, B: 50, A: 255}, src.NRGBAAt(5, 5))
+
+	vignetted := Apply(src, []Effect{{Name: "vignette", Amount: 0.5}}).(*image.NRGBA)
+	assert.Less(t, vignetted.NRGBAAt(0, 0).R, vignetted.NRGBAAt(10, 5).R)
+
+	posterized := Apply(src, []Effect{{Name: "posterize", Amount: 2}}).(*image.NRGBA)
+	assert.Equal(t, color.NRGBA{R: 255, G: 0, B: 0, A: 255}, posterized.NRGBAAt(5, 5))
+}
+
+func TestGrainIsDeterministic(t *testing.T) {
+	src := solid(16, 16, color.NRGBA{R: 128, G: 128, B: 128, A: 255})
+	chain := []Effect{{Name: "grain", Amount: 0.2}}
+
+	first := Apply(src, chain).(*image.NRGBA)
+	second := Apply(src, chain).(*image.NRGBA)
+
+	assert.Equal(t, first.Pix, second.Pix)
+	assert.NotEqual(t, src.Pix, first.Pix)
+}
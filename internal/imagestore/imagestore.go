@@ -0,0 +1,356 @@
+// Package imagestore keeps processed images so they can be served by URL rather than
+// embedded in views.
+//
+// Images are named after the SHA-256 of their bytes, so an image's URL only changes
+// when its content does. Browsers can cache them indefinitely and the same image
+// processed twice, for example when navigating history, is only downloaded once.
+//
+// An image can also be served in other formats. Each variant is encoded the first time
+// it is asked for and kept alongside the image.
+//
+// Images are kept in memory. Once the store grows past its size limit the least
+// recently used images are removed, apart from images pinned by PutFor, which are
+// kept while views that link to them may still be shown. When the disk cache is
+// enabled images are also written to it, so their URLs keep working once they have
+// been removed from memory or Kiosk has restarted.
+package imagestore
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	"github.com/damongolding/immich-kiosk/internal/diskcache"
+	"github.com/damongolding/immich-kiosk/internal/kiosk"
+)
+
+const (
+	// URLPrefix the path images are served under
+	URLPrefix = "/img/"
+
+	// DefaultMaxSize how many bytes of images are kept before the least recently used are removed
+	DefaultMaxSize int64 = 256 << 20
+)
+
+// entry is an image or variant in the store.
+type entry struct {
+	key string
+	img Image
+	// pinnedUntil the entry is not evicted before this time
+	pinnedUntil time.Time
+}
+
+// Image is a stored image.
+type Image struct {
+	// Name the image's hash and extension, e.g. "<sha>.jpg"
+	Name string
+	// MimeType of Data
+	MimeType string
+	// Data the encoded image
+	Data []byte
+	// Created when the image was first stored
+	Created time.Time
+	// ETag the strong entity tag of the image, which differs between variants
+	ETag string
+}
+
+// EncodeFunc re-encodes an image as mimeType at quality.
+type EncodeFunc func(img Image, mimeType string, quality int) ([]byte, error)
+
+var (
+	mu      sync.Mutex
+	images  = map[string]*list.Element{}
+	recent  = list.New()
+	size    int64
+	maxSize = DefaultMaxSize
+
+	encoding singleflight.Group
+)
+
+// extension returns the file extension images of mimeType are named with.
+func extension(mimeType string) string {
+	switch mimeType {
+	case kiosk.MimeTypePng:
+		return ".png"
+	case kiosk.MimeTypeGif:
+		return ".gif"
+	case kiosk.MimeTypeWebp:
+		return ".webp"
+	case kiosk.MimeTypeAvif:
+		return ".avif"
+	default:
+		return ".jpg"
+	}
+}
+
+// mimeType returns the MIME type of an image from the extension it is named with.
+func mimeType(name string) string {
+	for _, mimeType := range []string{kiosk.MimeTypePng, kiosk.MimeTypeGif, kiosk.MimeTypeWebp, kiosk.MimeTypeAvif} {
+		if strings.HasSuffix(name, extension(mimeType)) {
+			return mimeType
+		}
+	}
+
+	return kiosk.MimeTypeJpeg
+}
+
+// Name returns the name an image is stored under, the SHA-256 of data and an
+// extension for mimeType.
+func Name(data []byte, mimeType string) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:]) + extension(mimeType)
+}
+
+// URL returns the URL an image is served from.
+func URL(name string) string {
+	return URLPrefix + name
+}
+
+// NameFromURL returns the name of the image a URL points to, and false if the URL
+// is not an image store URL.
+func NameFromURL(u string) (string, bool) {
+	u, _, _ = strings.Cut(u, "?")
+
+	name, found := strings.CutPrefix(u, URLPrefix)
+	if !found || !ValidName(name) {
+		return "", false
+	}
+
+	return name, true
+}
+
+// ValidName reports whether name could be the name of a stored image.
+func ValidName(name string) bool {
+	hash, ext, found := strings.Cut(name, ".")
+	if !found || len(hash) != sha256.Size*2 || path.Base(name) != name {
+		return false
+	}
+
+	if _, err := hex.DecodeString(hash); err != nil {
+		return false
+	}
+
+	return ext != "" && !strings.Contains(ext, ".")
+}
+
+// ETag returns the strong entity tag of a stored image.
+func ETag(name string) string {
+	hash, _, _ := strings.Cut(name, ".")
+	return `"` + hash + `"`
+}
+
+// variantKey returns the key a variant of an image is stored under.
+func variantKey(name, mimeType string, quality int) string {
+	return fmt.Sprintf("%s|%s|%d", name, mimeType, quality)
+}
+
+// variantETag returns the strong entity tag of a variant of an image.
+func variantETag(name, mimeType string, quality int) string {
+	hash, _, _ := strings.Cut(name, ".")
+	return fmt.Sprintf(`"%s-%s-%d"`, hash, strings.TrimPrefix(extension(mimeType), "."), quality)
+}
+
+// Variant returns img encoded as mimeType at quality. Each variant is only encoded
+// once, however many requests ask for it at the same time, and is then kept until the
+// store removes it.
+func Variant(img Image, mimeType string, quality int, encode EncodeFunc) (Image, error) {
+	key := variantKey(img.Name, mimeType, quality)
+
+	if variant, found := get(key); found {
+		return variant, nil
+	}
+
+	v, err, _ := encoding.Do(key, func() (any, error) {
+		if variant, found := get(key); found {
+			return variant, nil
+		}
+
+		data, err := encode(img, mimeType, quality)
+		if err != nil {
+			return Image{}, err
+		}
+
+		variant := Image{
+			Name:     img.Name,
+			MimeType: mimeType,
+			Data:     data,
+			Created:  time.Now(),
+			ETag:     variantETag(img.Name, mimeType, quality),
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		add(key, variant)
+		evict()
+
+		return variant, nil
+	})
+	if err != nil {
+		return Image{}, err
+	}
+
+	return v.(Image), nil
+}
+
+// Put stores an image and returns the URL it is served from.
+// Storing an image that is already stored marks it as recently used.
+func Put(data []byte, mimeType string) string {
+	return PutFor(data, mimeType, 0)
+}
+
+// PutFor stores an image like Put and pins it for d, so it is not removed to make room
+// for other images until d has passed. Views that link to an image should pin it for as
+// long as they are cached.
+func PutFor(data []byte, mimeType string, d time.Duration) string {
+	name := Name(data, mimeType)
+
+	mu.Lock()
+	added := add(name, Image{
+		Name:     name,
+		MimeType: mimeType,
+		Data:     data,
+		Created:  time.Now(),
+		ETag:     ETag(name),
+	})
+	pin(name, d)
+	evict()
+	mu.Unlock()
+
+	if added {
+		diskcache.Set(diskKey(name), data)
+	}
+
+	return URL(name)
+}
+
+// Get returns a stored image by name. Images no longer in memory are read back from
+// the disk cache.
+func Get(name string) (Image, bool) {
+	if img, found := get(name); found {
+		return img, true
+	}
+
+	data, found := diskcache.Get(diskKey(name))
+	if !found {
+		return Image{}, false
+	}
+
+	img := Image{
+		Name:     name,
+		MimeType: mimeType(name),
+		Data:     data,
+		Created:  time.Now(),
+		ETag:     ETag(name),
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	add(name, img)
+	evict()
+
+	return img, true
+}
+
+// diskKey returns the key an image is kept in the disk cache under.
+func diskKey(name string) string {
+	return diskcache.Key("imagestore", name)
+}
+
+// get returns a stored image or variant by key, marking it as recently used.
+func get(key string) (Image, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	el, found := images[key]
+	if !found {
+		return Image{}, false
+	}
+
+	recent.MoveToFront(el)
+
+	return el.Value.(*entry).img, true
+}
+
+// add stores an image or variant under key, reporting whether it was not already
+// stored. Callers must hold mu and call evict once done.
+func add(key string, img Image) bool {
+	if el, found := images[key]; found {
+		recent.MoveToFront(el)
+		return false
+	}
+
+	images[key] = recent.PushFront(&entry{key: key, img: img})
+	size += int64(len(img.Data))
+
+	return true
+}
+
+// pin keeps the entry stored under key for at least d. Pins are only ever extended.
+// Callers must hold mu.
+func pin(key string, d time.Duration) {
+	el, found := images[key]
+	if !found || d <= 0 {
+		return
+	}
+
+	e := el.Value.(*entry)
+	if until := time.Now().Add(d); until.After(e.pinnedUntil) {
+		e.pinnedUntil = until
+	}
+}
+
+// SetMaxSize sets how many bytes of images are kept, removing the least recently
+// used images if the store is already larger.
+func SetMaxSize(bytes int64) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	maxSize = bytes
+	evict()
+}
+
+// Flush removes every stored image.
+func Flush() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	images = map[string]*list.Element{}
+	recent.Init()
+	size = 0
+}
+
+// Size returns the number of images and variants stored and their total size in bytes.
+func Size() (int, int64) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	return len(images), size
+}
+
+// evict removes the least recently used images until the store is within maxSize.
+// Pinned images and the most recently used image are always kept, so the store can
+// be over maxSize while they are. Callers must hold mu.
+func evict() {
+	now := time.Now()
+
+	for el := recent.Back(); el != nil && el != recent.Front() && size > maxSize; {
+		prev := el.Prev()
+
+		if e := el.Value.(*entry); !e.pinnedUntil.After(now) {
+			recent.Remove(el)
+			delete(images, e.key)
+			size -= int64(len(e.img.Data))
+		}
+
+		el = prev
+	}
+}
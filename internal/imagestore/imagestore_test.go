@@ -0,0 +1,157 @@
+package imagestore
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/damongolding/immich-kiosk/internal/diskcache"
+	"github.com/damongolding/immich-kiosk/internal/kiosk"
+)
+
+func TestPutAndGet(t *testing.T) {
+	t.Cleanup(Flush)
+
+	u := Put([]byte("image"), kiosk.MimeTypeJpeg)
+	assert.True(t, strings.HasPrefix(u, URLPrefix))
+	assert.True(t, strings.HasSuffix(u, ".jpg"))
+	assert.Equal(t, u, Put([]byte("image"), kiosk.MimeTypeJpeg), "the same content has the same URL")
+
+	name, ok := NameFromURL(u)
+	require.True(t, ok)
+
+	img, found := Get(name)
+	require.True(t, found)
+	assert.Equal(t, []byte("image"), img.Data)
+	assert.Equal(t, kiosk.MimeTypeJpeg, img.MimeType)
+	assert.Equal(t, `"`+strings.TrimSuffix(name, ".jpg")+`"`, ETag(name))
+
+	count, size := Size()
+	assert.Equal(t, 1, count)
+	assert.Equal(t, int64(len("image")), size)
+}
+
+func TestEvictsLeastRecentlyUsed(t *testing.T) {
+	t.Cleanup(func() {
+		SetMaxSize(DefaultMaxSize)
+		Flush()
+	})
+
+	SetMaxSize(10)
+
+	first, _ := NameFromURL(Put([]byte("aaaa"), kiosk.MimeTypePng))
+	second, _ := NameFromURL(Put([]byte("bbbb"), kiosk.MimeTypePng))
+
+	// using the first image makes the second the least recently used
+	_, found := Get(first)
+	require.True(t, found)
+
+	third, _ := NameFromURL(Put([]byte("cccc"), kiosk.MimeTypePng))
+
+	_, found = Get(second)
+	assert.False(t, found)
+
+	for _, name := range []string{first, third} {
+		_, found = Get(name)
+		assert.True(t, found)
+	}
+}
+
+func TestPinnedImagesAreKept(t *testing.T) {
+	t.Cleanup(func() {
+		SetMaxSize(DefaultMaxSize)
+		Flush()
+	})
+
+	SetMaxSize(10)
+
+	pinned, _ := NameFromURL(PutFor([]byte("aaaa"), kiosk.MimeTypePng, time.Minute))
+	second, _ := NameFromURL(Put([]byte("bbbb"), kiosk.MimeTypePng))
+	third, _ := NameFromURL(Put([]byte("cccc"), kiosk.MimeTypePng))
+
+	_, found := Get(pinned)
+	assert.True(t, found, "pinned images should not be evicted")
+
+	_, found = Get(second)
+	assert.False(t, found)
+
+	_, found = Get(third)
+	assert.True(t, found)
+}
+
+func TestEvictedImagesAreReadFromDisk(t *testing.T) {
+	require.NoError(t, diskcache.Initialize(t.TempDir(), 1<<20))
+	t.Cleanup(func() {
+		_ = diskcache.Initialize("", 0)
+		SetMaxSize(DefaultMaxSize)
+		Flush()
+	})
+
+	data := []byte("image")
+	name, _ := NameFromURL(Put(data, kiosk.MimeTypePng))
+
+	// a restart empties the store
+	Flush()
+
+	img, found := Get(name)
+	require.True(t, found)
+	assert.Equal(t, data, img.Data)
+	assert.Equal(t, kiosk.MimeTypePng, img.MimeType)
+	assert.Equal(t, ETag(name), img.ETag)
+}
+
+func TestNameFromURL(t *testing.T) {
+	hash := strings.Repeat("ab", 32)
+
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{url: URLPrefix + hash + ".jpg", want: true},
+		{url: "/image/" + hash + ".jpg"},
+		{url: URLPrefix + hash},
+		{url: URLPrefix + "zz" + hash[2:] + ".jpg"},
+		{url: URLPrefix + hash + ".jpg/../x"},
+		{url: "data:image/jpeg;base64,AAAA"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			_, ok := NameFromURL(tt.url)
+			assert.Equal(t, tt.want, ok)
+		})
+	}
+}
+
+func TestVariant(t *testing.T) {
+	t.Cleanup(Flush)
+
+	name, _ := NameFromURL(Put([]byte("image"), kiosk.MimeTypeJpeg))
+	img, found := Get(name)
+	require.True(t, found)
+
+	encodes := 0
+	encode := func(_ Image, mimeType string, quality int) ([]byte, error) {
+		encodes++
+		return []byte(mimeType), nil
+	}
+
+	variant, err := Variant(img, kiosk.MimeTypeWebp, 80, encode)
+	require.NoError(t, err)
+	assert.Equal(t, kiosk.MimeTypeWebp, variant.MimeType)
+	assert.Equal(t, []byte(kiosk.MimeTypeWebp), variant.Data)
+	assert.NotEqual(t, img.ETag, variant.ETag)
+
+	again, err := Variant(img, kiosk.MimeTypeWebp, 80, encode)
+	require.NoError(t, err)
+	assert.Equal(t, variant, again)
+	assert.Equal(t, 1, encodes, "variants are only encoded once")
+
+	other, err := Variant(img, kiosk.MimeTypeWebp, 50, encode)
+	require.NoError(t, err)
+	assert.NotEqual(t, variant.ETag, other.ETag, "quality is part of the entity tag")
+	assert.Equal(t, 2, encodes)
+}
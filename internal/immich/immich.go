@@ -171,11 +171,11 @@ type Asset struct {
 	DeviceID         string    `json:"-"` // `json:"deviceId"`
 	LibraryID        string    `json:"-"` // `json:"libraryId"`
 	Type             AssetType `json:"type"`
-	OriginalPath     string    `json:"-"` // `json:"originalPath"`
+	OriginalPath     string    `json:"originalPath" msgpack:"-"` // server side only, see Outbound
 	OriginalFileName string    `json:"originalFileName"`
 	OriginalMimeType string    `json:"originalMimeType"`
 	ServedMimeType   string    `json:"servedMimeType"` // mime type served from the Immich server
-	Thumbhash        string    `json:"-"`              // `json:"thumbhash"`
+	Thumbhash        string    `json:"thumbhash"`
 	Duration         string    `json:"duration"`
 	LivePhotoVideoID string    `json:"livePhotoVideoId"`
 	Checksum         string    `json:"checksum"`
@@ -193,22 +193,29 @@ type Asset struct {
 	ExifInfo        ExifInfo `json:"exifInfo"`
 
 	requestConfig config.Config `json:"-"`
-	IsEdited      bool          `json:"isEdited"`
-	IsFavorite    bool          `json:"isFavorite"`
-	IsArchived    bool          `json:"isArchived"`
-	IsTrashed     bool          `json:"isTrashed"`
-	IsOffline     bool          `json:"-"` // `json:"isOffline"`
-	HasMetadata   bool          `json:"-"` // `json:"hasMetadata"`
-	IsPortrait    bool          `json:"isPortrait"`
-	IsLandscape   bool          `json:"isLandscape"`
+	// deckID the deck the asset was dealt from, if any
+	deckID string
+	// cacheKey the cached API list the asset was taken from, if any, see PutBack
+	cacheKey string
+	// ordered the asset's bucket is played in order, so the no-repeat window does not apply
+	ordered     bool
+	IsEdited    bool `json:"isEdited"`
+	IsFavorite  bool `json:"isFavorite"`
+	IsArchived  bool `json:"isArchived"`
+	IsTrashed   bool `json:"isTrashed"`
+	IsOffline   bool `json:"-"` // `json:"isOffline"`
+	HasMetadata bool `json:"-"` // `json:"hasMetadata"`
+	IsPortrait  bool `json:"isPortrait"`
+	IsLandscape bool `json:"isLandscape"`
 }
 
 type Album struct {
-	ID            string  `json:"id"`
-	AlbumName     string  `json:"albumName"`
-	Assets        []Asset `json:"assets"`
-	AssetCount    int     `json:"assetCount"`
-	AssetsOrdered bool    `json:"assetsOrdered"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+	ID            string    `json:"id"`
+	AlbumName     string    `json:"albumName"`
+	Assets        []Asset   `json:"assets"`
+	AssetCount    int       `json:"assetCount"`
+	AssetsOrdered bool      `json:"assetsOrdered"`
 }
 
 type Albums []Album
@@ -225,6 +232,7 @@ type SearchRandomBody struct {
 	Make          string   `url:"make,omitempty" json:"make,omitempty"`
 	Model         string   `url:"model,omitempty" json:"model,omitempty"`
 	Ocr           string   `url:"ocr,omitempty" json:"ocr,omitempty"`
+	Order         string   `url:"order,omitempty" json:"order,omitempty"`
 	State         string   `url:"state,omitempty" json:"state,omitempty"`
 	TakenAfter    string   `url:"takenAfter,omitempty" json:"takenAfter,omitempty"`
 	TakenBefore   string   `url:"takenBefore,omitempty" json:"takenBefore,omitempty"`
@@ -14,6 +14,7 @@ import (
 
 	"github.com/damongolding/immich-kiosk/internal/cache"
 	"github.com/damongolding/immich-kiosk/internal/kiosk"
+	"github.com/damongolding/immich-kiosk/internal/playback"
 	"github.com/damongolding/immich-kiosk/internal/utils"
 )
 
@@ -273,6 +274,7 @@ func (a *Asset) AssetFromAlbum(albumID string, albumAssetsOrder AssetOrder, requ
 			rand.Shuffle(len(album.Assets), func(i, j int) {
 				album.Assets[i], album.Assets[j] = album.Assets[j], album.Assets[i]
 			})
+			album.Assets = a.applyWeighting(album.Assets, deviceID)
 		case Asc:
 			if !album.AssetsOrdered {
 				slices.Reverse(album.Assets)
@@ -281,6 +283,16 @@ func (a *Asset) AssetFromAlbum(albumID string, albumAssetsOrder AssetOrder, requ
 		case Desc:
 		}
 
+		bucketID := playback.BucketID(album.ID, a.requestConfig.SelectedUser)
+
+		if albumAssetsOrder != Rand {
+			var refreshed bool
+			album.Assets, refreshed = applyPlaybackPosition(album.Assets, func(asset Asset) string { return asset.ID }, apiCacheKey, requestID, deviceID, bucketID)
+			if refreshed {
+				continue
+			}
+		}
+
 		allowedTypes := ImageOnlyAssetTypes
 
 		if a.requestConfig.ShowVideos {
@@ -290,6 +302,7 @@ func (a *Asset) AssetFromAlbum(albumID string, albumAssetsOrder AssetOrder, requ
 		for assetIndex, asset := range album.Assets {
 
 			asset.Bucket = kiosk.SourceAlbum
+			asset.ordered = albumAssetsOrder != Rand
 			asset.requestConfig = a.requestConfig
 			asset.ctx = a.ctx
 
@@ -309,11 +322,13 @@ func (a *Asset) AssetFromAlbum(albumID string, albumAssetsOrder AssetOrder, requ
 
 				// replace with cache minus used asset
 				cache.Set(apiCacheKey, jsonBytes, a.requestConfig.Duration, a.requestConfig.CacheDuration)
+				asset.cacheKey = apiCacheKey
 			}
 
-			asset.BucketID = album.ID
-			if asset.requestConfig.SelectedUser != "" {
-				asset.BucketID = fmt.Sprintf("%s@%s", album.ID, asset.requestConfig.SelectedUser)
+			asset.BucketID = bucketID
+
+			if albumAssetsOrder != Rand {
+				playback.Set(deviceID, bucketID, asset.ID)
 			}
 
 			*a = asset
@@ -2,6 +2,8 @@
 package immich
 
 import (
+	"encoding/json"
+	"errors"
 	"net/url"
 	"path"
 
@@ -33,3 +35,53 @@ func (a *Asset) RemoveAssetCache(deviceID string) error {
 
 	return nil
 }
+
+// PutBack returns an asset that was picked but not shown to the end of the cached
+// API list it was taken from, so it can be picked again later.
+// Assets that were not taken from a cached list are left alone.
+//
+// Returns:
+//   - error - Returns an error if the cached list can not be read or written, nil otherwise
+func (a *Asset) PutBack() error {
+	if a.cacheKey == "" {
+		return nil
+	}
+
+	apiData, found := cache.Get(a.cacheKey)
+	if !found {
+		return nil
+	}
+
+	data, ok := apiData.([]byte)
+	if !ok {
+		return errors.New("PutBack: cache data type assertion failed")
+	}
+
+	var jsonBytes []byte
+	var err error
+
+	// albums are cached with their assets, every other list is a slice of assets
+	if len(data) > 0 && data[0] == '{' {
+		var album Album
+		if err = json.Unmarshal(data, &album); err != nil {
+			return err
+		}
+		album.Assets = append(album.Assets, *a)
+		jsonBytes, err = json.Marshal(album)
+	} else {
+		var assets []Asset
+		if err = json.Unmarshal(data, &assets); err != nil {
+			return err
+		}
+		assets = append(assets, *a)
+		jsonBytes, err = json.Marshal(assets)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	cache.Set(a.cacheKey, jsonBytes, a.requestConfig.Duration, a.requestConfig.CacheDuration)
+
+	return nil
+}
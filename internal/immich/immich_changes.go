@@ -0,0 +1,291 @@
+package immich
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"charm.land/log/v2"
+	"github.com/google/go-querystring/query"
+
+	"github.com/damongolding/immich-kiosk/internal/cache"
+	"github.com/damongolding/immich-kiosk/internal/config"
+	"github.com/damongolding/immich-kiosk/internal/utils"
+)
+
+// changedAssetsPageSize how many updated assets are fetched per page
+const changedAssetsPageSize = 1000
+
+// albumState the parts of an album that change when assets are added or removed.
+type albumState struct {
+	updatedAt  time.Time
+	assetCount int
+}
+
+// Changes what changed in Immich since the previous check, and the cache entries
+// removed because of it.
+type Changes struct {
+	Assets  []string     `json:"assets"`
+	Albums  []string     `json:"albums"`
+	People  []string     `json:"people"`
+	Flushed cache.Report `json:"flushed"`
+}
+
+// Empty reports whether nothing changed.
+func (c Changes) Empty() bool {
+	return len(c.Assets) == 0 && len(c.Albums) == 0 && len(c.People) == 0
+}
+
+// ChangeWatcher polls Immich for assets, albums and people that changed since its last
+// check and removes the cached API responses they affect, so devices see the changes
+// without waiting for the cache to expire.
+type ChangeWatcher struct {
+	asset Asset
+
+	checkedAt time.Time
+	albums    map[string]albumState
+	people    map[string]string
+}
+
+// NewChangeWatcher creates a watcher using the Immich URL and API key in base.
+func NewChangeWatcher(ctx context.Context, base config.Config) *ChangeWatcher {
+	return &ChangeWatcher{
+		asset: New(ctx, base),
+	}
+}
+
+// Watch checks Immich for changes every interval until ctx is done, calling onChange
+// after each check that found any.
+func (w *ChangeWatcher) Watch(ctx context.Context, interval time.Duration, onChange func(Changes)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		changes, err := w.Check(utils.ColorizeRequestID(utils.GenerateUUID()))
+		if err != nil {
+			log.Error("Failed to check Immich for changes", "err", err)
+		} else if !changes.Empty() {
+			log.Info("Immich changed",
+				"assets", len(changes.Assets),
+				"albums", len(changes.Albums),
+				"people", len(changes.People),
+				"cache_items_removed", changes.Flushed.Count)
+
+			if onChange != nil {
+				onChange(changes)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check compares Immich's albums and people with the previous check and searches for
+// assets updated since then, removing the cached API responses that mention any of them.
+// The first check only records what Immich holds and reports no changes.
+func (w *ChangeWatcher) Check(requestID string) (Changes, error) {
+	var changes Changes
+
+	checkedAt := time.Now()
+
+	albums, _, err := w.asset.albums(requestID, "", false, "", true)
+	if err != nil {
+		return changes, err
+	}
+
+	people, err := w.asset.people(requestID, "", false, true)
+	if err != nil {
+		return changes, err
+	}
+
+	albumStates := make(map[string]albumState, len(albums))
+	for _, album := range albums {
+		albumStates[album.ID] = albumState{updatedAt: album.UpdatedAt, assetCount: album.AssetCount}
+	}
+
+	peopleNames := make(map[string]string, len(people))
+	for _, person := range people {
+		peopleNames[person.ID] = person.Name
+	}
+
+	firstCheck := w.checkedAt.IsZero()
+
+	var assets []Asset
+	if !firstCheck {
+		assets, err = w.updatedAssets(requestID, w.checkedAt)
+		if err != nil {
+			return changes, err
+		}
+	}
+
+	previousAlbums, previousPeople := w.albums, w.people
+	w.checkedAt, w.albums, w.people = checkedAt, albumStates, peopleNames
+
+	if firstCheck {
+		return changes, nil
+	}
+
+	changes.Albums = changedKeys(previousAlbums, albumStates)
+	changes.People = changedKeys(previousPeople, peopleNames)
+
+	ids := map[string]struct{}{}
+	favouriteChanged := false
+
+	for _, asset := range assets {
+		changes.Assets = append(changes.Assets, asset.ID)
+		ids[asset.ID] = struct{}{}
+
+		for _, person := range asset.People {
+			ids[person.ID] = struct{}{}
+		}
+
+		favouriteChanged = favouriteChanged || asset.IsFavorite
+	}
+
+	for _, id := range changes.Albums {
+		ids[id] = struct{}{}
+	}
+
+	for _, id := range changes.People {
+		ids[id] = struct{}{}
+	}
+
+	if changes.Empty() {
+		return changes, nil
+	}
+
+	changes.Flushed = cache.InvalidateFunc(cache.Filter{}, func(entry cache.Entry) bool {
+		if entry.Category != cache.CategoryAPI && entry.Category != cache.CategoryMemories {
+			return false
+		}
+
+		return affectedByChanges(entry.URL, ids, len(changes.Albums) > 0, len(changes.People) > 0, favouriteChanged)
+	})
+
+	return changes, nil
+}
+
+// updatedAssets returns the assets, including trashed ones, updated since after.
+func (w *ChangeWatcher) updatedAssets(requestID string, after time.Time) ([]Asset, error) {
+	var assets []Asset
+
+	u, err := url.Parse(w.asset.requestConfig.ImmichURL)
+	if err != nil {
+		_, _, err = immichAPIFail(assets, err, nil, "")
+		return nil, err
+	}
+
+	requestBody := SearchRandomBody{
+		UpdatedAfter: after.UTC().Format(time.RFC3339),
+		WithPeople:   true,
+		WithDeleted:  true,
+		WithArchived: true,
+		Size:         changedAssetsPageSize,
+		Page:         1,
+	}
+
+	for requestBody.Page <= MaxPages {
+		var response SearchMetadataResponse
+
+		queries, _ := query.Values(requestBody)
+
+		apiURL := url.URL{
+			Scheme:   u.Scheme,
+			Host:     u.Host,
+			Path:     path.Join("api", "search", "metadata"),
+			RawQuery: queries.Encode(),
+		}
+
+		jsonBody, marshalErr := json.Marshal(requestBody)
+		if marshalErr != nil {
+			_, _, err = immichAPIFail(response, marshalErr, nil, apiURL.String())
+			return nil, err
+		}
+
+		apiBody, _, _, callErr := w.asset.immichAPICall(w.asset.ctx, http.MethodPost, apiURL.String(), jsonBody)
+		if callErr != nil {
+			_, _, err = immichAPIFail(response, callErr, apiBody, apiURL.String())
+			return nil, err
+		}
+
+		if unmarshalErr := json.Unmarshal(apiBody, &response); unmarshalErr != nil {
+			_, _, err = immichAPIFail(response, unmarshalErr, apiBody, apiURL.String())
+			return nil, err
+		}
+
+		assets = append(assets, response.Assets.Items...)
+
+		if response.Assets.NextPage == "" {
+			return assets, nil
+		}
+
+		requestBody.Page++
+	}
+
+	log.Warn(requestID + " Reached maximum page count when fetching updated assets")
+
+	return assets, nil
+}
+
+// changedKeys returns the keys that were added, removed or changed between previous and current.
+func changedKeys[V comparable](previous, current map[string]V) []string {
+	var changed []string
+
+	for key, value := range current {
+		if previousValue, found := previous[key]; !found || previousValue != value {
+			changed = append(changed, key)
+		}
+	}
+
+	for key := range previous {
+		if _, found := current[key]; !found {
+			changed = append(changed, key)
+		}
+	}
+
+	slices.Sort(changed)
+
+	return changed
+}
+
+// affectedByChanges reports whether the cached response for apiURL may be stale.
+// It is if the URL mentions a changed asset, album or person, lists albums or people
+// when any of those changed, or searches favourites when a favourite changed.
+func affectedByChanges(apiURL string, ids map[string]struct{}, albumsChanged, peopleChanged, favouriteChanged bool) bool {
+	u, err := url.Parse(apiURL)
+	if err != nil {
+		return false
+	}
+
+	for _, part := range strings.Split(u.Path, "/") {
+		if _, found := ids[part]; found {
+			return true
+		}
+	}
+
+	for _, values := range u.Query() {
+		for _, value := range values {
+			if _, found := ids[value]; found {
+				return true
+			}
+		}
+	}
+
+	switch strings.TrimSuffix(u.Path, "/") {
+	case "/api/albums":
+		return albumsChanged
+	case "/api/people":
+		return peopleChanged
+	}
+
+	return favouriteChanged && u.Query().Get("isFavorite") == "true"
+}
@@ -51,8 +51,6 @@ func (a *Asset) RandomAssetInDateRange(dateRange, requestID, deviceID string, is
 
 	for range MaxRetries {
 
-		var immichAssets []Asset
-
 		u, err := url.Parse(a.requestConfig.ImmichURL)
 		if err != nil {
 			return fmt.Errorf("parsing url: %w", err)
@@ -76,31 +74,8 @@ func (a *Asset) RandomAssetInDateRange(dateRange, requestID, deviceID string, is
 			requestBody.WithArchived = true
 		}
 
-		// convert body to queries so url is unique and can be cached
-		queries, _ := query.Values(requestBody)
-
-		apiURL := url.URL{
-			Scheme:   u.Scheme,
-			Host:     u.Host,
-			Path:     "api/search/random",
-			RawQuery: fmt.Sprintf("kiosk=%x", sha256.Sum256([]byte(queries.Encode()))),
-		}
-
-		jsonBody, marshalErr := json.Marshal(requestBody)
-		if marshalErr != nil {
-			return fmt.Errorf("marshaling request body: %w", marshalErr)
-		}
-
-		immichAPICall := withImmichAPICache(a.immichAPICall, requestID, deviceID, a.requestConfig, immichAssets)
-		apiBody, _, _, err := immichAPICall(a.ctx, http.MethodPost, apiURL.String(), jsonBody)
-		if err != nil {
-			_, _, err = immichAPIFail(immichAssets, err, apiBody, apiURL.String())
-			return err
-		}
-
-		err = json.Unmarshal(apiBody, &immichAssets)
+		immichAssets, apiURL, err := a.dateRangeAssets(u, requestBody, requestID, deviceID)
 		if err != nil {
-			_, _, err = immichAPIFail(immichAssets, err, apiBody, apiURL.String())
 			return err
 		}
 
@@ -117,6 +92,8 @@ func (a *Asset) RandomAssetInDateRange(dateRange, requestID, deviceID string, is
 			wantedAssetType = AllAssetTypes
 		}
 
+		immichAssets = a.applyWeighting(immichAssets, deviceID)
+
 		for immichAssetIndex, asset := range immichAssets {
 
 			asset.Bucket = kiosk.SourceDateRange
@@ -138,6 +115,7 @@ func (a *Asset) RandomAssetInDateRange(dateRange, requestID, deviceID string, is
 
 				// replace cache with used asset(s) removed
 				cache.Set(apiCacheKey, jsonBytes, a.requestConfig.Duration, a.requestConfig.CacheDuration)
+				asset.cacheKey = apiCacheKey
 			}
 
 			asset.BucketID = dateRange
@@ -272,3 +250,43 @@ func processLastDays(dateRange string) (time.Time, time.Time, error) {
 
 	return dateStart, dateEnd, nil
 }
+
+// dateRangeAssets fetches random assets matching requestBody, or the next hand from the
+// device's deck when deck mode is enabled.
+func (a *Asset) dateRangeAssets(u *url.URL, requestBody SearchRandomBody, requestID, deviceID string) ([]Asset, url.URL, error) {
+	var immichAssets []Asset
+
+	if a.requestConfig.Deck {
+		return a.fetchDeckAssets(requestID, deviceID, requestBody)
+	}
+
+	// convert body to queries so url is unique and can be cached
+	queries, _ := query.Values(requestBody)
+
+	apiURL := url.URL{
+		Scheme:   u.Scheme,
+		Host:     u.Host,
+		Path:     "api/search/random",
+		RawQuery: fmt.Sprintf("kiosk=%x", sha256.Sum256([]byte(queries.Encode()))),
+	}
+
+	jsonBody, err := json.Marshal(requestBody)
+	if err != nil {
+		return nil, apiURL, fmt.Errorf("marshaling request body: %w", err)
+	}
+
+	immichAPICall := withImmichAPICache(a.immichAPICall, requestID, deviceID, a.requestConfig, immichAssets)
+	apiBody, _, _, err := immichAPICall(a.ctx, http.MethodPost, apiURL.String(), jsonBody)
+	if err != nil {
+		_, _, err = immichAPIFail(immichAssets, err, apiBody, apiURL.String())
+		return nil, apiURL, err
+	}
+
+	err = json.Unmarshal(apiBody, &immichAssets)
+	if err != nil {
+		_, _, err = immichAPIFail(immichAssets, err, apiBody, apiURL.String())
+		return nil, apiURL, err
+	}
+
+	return immichAssets, apiURL, nil
+}
@@ -0,0 +1,182 @@
+package immich
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"charm.land/log/v2"
+	"github.com/google/go-querystring/query"
+
+	"github.com/damongolding/immich-kiosk/internal/deck"
+)
+
+// deckHandSize is how many assets are dealt from a deck into the cache at a time
+const deckHandSize = 10
+
+// deckAssetIDs returns the ID of every asset matching requestBody, read a page at a
+// time from the metadata search endpoint.
+func (a *Asset) deckAssetIDs(u *url.URL, requestBody SearchRandomBody, requestID, deviceID string) ([]string, error) {
+	var ids []string
+
+	requestBody.WithExif = false
+	requestBody.WithPeople = false
+	requestBody.Page = 1
+	requestBody.Size = a.requestConfig.Kiosk.FetchedAssetsSize
+
+	filterNewest := a.requestConfig.FilterNewest > 0
+	if filterNewest {
+		requestBody.Size = a.requestConfig.FilterNewest
+	}
+
+	for requestBody.Page <= MaxPages {
+
+		var response SearchMetadataResponse
+
+		// convert body to queries so url is unique and can be cached
+		queries, _ := query.Values(requestBody)
+
+		apiURL := url.URL{
+			Scheme:   u.Scheme,
+			Host:     u.Host,
+			Path:     "api/search/metadata",
+			RawQuery: queries.Encode(),
+		}
+
+		jsonBody, err := json.Marshal(requestBody)
+		if err != nil {
+			_, _, err = immichAPIFail(response, err, nil, apiURL.String())
+			return nil, err
+		}
+
+		immichAPICall := withImmichAPICache(a.immichAPICall, requestID, deviceID, a.requestConfig, response)
+		apiBody, _, _, err := immichAPICall(a.ctx, http.MethodPost, apiURL.String(), jsonBody)
+		if err != nil {
+			_, _, err = immichAPIFail(response, err, apiBody, apiURL.String())
+			return nil, err
+		}
+
+		err = json.Unmarshal(apiBody, &response)
+		if err != nil {
+			_, _, err = immichAPIFail(response, err, apiBody, apiURL.String())
+			return nil, err
+		}
+
+		for _, asset := range response.Assets.Items {
+			ids = append(ids, asset.ID)
+		}
+
+		// filter newest only wants the first page
+		if filterNewest || response.Assets.NextPage == "" {
+			return ids, nil
+		}
+
+		requestBody.Page++
+	}
+
+	log.Warn(requestID + " Reached maximum page count when building deck")
+
+	return ids, nil
+}
+
+// dealDeckHand returns an apiCall that deals the next hand of assets from the device's
+// deck. It is wrapped with withImmichAPICache so the hand is cached, and used assets
+// removed, in the same way as a random search.
+func (a *Asset) dealDeckHand(u *url.URL, deckID string, requestBody SearchRandomBody, requestID, deviceID string) apiCall {
+	return func(_ context.Context, _, _ string, _ []byte, _ ...map[string]string) ([]byte, string, bool, error) {
+		ids, err := a.deckAssetIDs(u, requestBody, requestID, deviceID)
+		if err != nil {
+			return nil, "", false, err
+		}
+
+		handSize := deckHandSize
+		if !a.requestConfig.Kiosk.Cache {
+			handSize = 1
+		}
+
+		hand := make([]Asset, 0, handSize)
+
+		for _, id := range deck.Deal(deviceID, deckID, ids, handSize) {
+			asset := New(a.ctx, a.requestConfig)
+			asset.ID = id
+
+			if infoErr := asset.AssetInfo(requestID, deviceID); infoErr != nil {
+				log.Error(requestID+" Failed to get deck asset", "assetID", id, "err", infoErr)
+				continue
+			}
+
+			hand = append(hand, asset)
+		}
+
+		jsonBytes, err := json.Marshal(hand)
+		return jsonBytes, "application/json", false, err
+	}
+}
+
+// fetchDeckAssets is the deck mode equivalent of fetchAssets. Rather than a random
+// sample, it returns the next hand dealt from a shuffled deck of every asset matching
+// requestBody, so each asset is shown once before any asset repeats.
+//
+// Parameters:
+//   - requestID: ID used for tracking API call chain
+//   - deviceID: ID of the device making the request
+//   - requestBody: The search the deck is built from
+//
+// Returns:
+//   - []Asset: The assets in the current hand
+//   - url.URL: The URL the hand is cached under
+//   - error: Any error encountered during the request
+func (a *Asset) fetchDeckAssets(requestID, deviceID string, requestBody SearchRandomBody) ([]Asset, url.URL, error) {
+	var hand []Asset
+
+	u, err := url.Parse(a.requestConfig.ImmichURL)
+	if err != nil {
+		_, _, err = immichAPIFail(hand, err, nil, "")
+		return nil, url.URL{}, err
+	}
+
+	requestBody.Size = 0
+	requestBody.Page = 0
+
+	queries, _ := query.Values(requestBody)
+	deckID := fmt.Sprintf("%x", sha256.Sum256([]byte(a.requestConfig.SelectedUser+queries.Encode())))
+
+	apiURL := url.URL{
+		Scheme:   u.Scheme,
+		Host:     u.Host,
+		Path:     "kiosk/deck",
+		RawQuery: "kiosk=" + deckID,
+	}
+
+	immichAPICall := withImmichAPICache(a.dealDeckHand(u, deckID, requestBody, requestID, deviceID), requestID, deviceID, a.requestConfig, hand)
+	apiBody, _, _, err := immichAPICall(a.ctx, http.MethodPost, apiURL.String(), nil)
+	if err != nil {
+		_, _, err = immichAPIFail(hand, err, apiBody, apiURL.String())
+		return nil, url.URL{}, err
+	}
+
+	if err = json.Unmarshal(apiBody, &hand); err != nil {
+		_, _, err = immichAPIFail(hand, err, apiBody, apiURL.String())
+		return nil, url.URL{}, err
+	}
+
+	for i := range hand {
+		hand[i].deckID = deckID
+	}
+
+	return hand, apiURL, nil
+}
+
+// Played marks the asset as shown in the deck it was dealt from. Assets dealt but never
+// played are returned to the deck, so assets rejected after dealing are still shown
+// later in the cycle. It does nothing for assets not dealt from a deck.
+func (a *Asset) Played(deviceID string) {
+	if a.deckID == "" {
+		return
+	}
+
+	deck.Played(deviceID, a.deckID, a.ID)
+}
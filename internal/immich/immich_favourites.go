@@ -110,6 +110,8 @@ func (a *Asset) RandomAssetFromFavourites(requestID, deviceID string, isPrefetch
 			wantedAssetType = AllAssetTypes
 		}
 
+		assets = a.applyWeighting(assets, deviceID)
+
 		for assetIndex, asset := range assets {
 
 			asset.Bucket = kiosk.SourceAlbum
@@ -131,6 +133,7 @@ func (a *Asset) RandomAssetFromFavourites(requestID, deviceID string, isPrefetch
 
 				// replace cache minus used image
 				cache.Set(apiCacheKey, jsonBytes, a.requestConfig.Duration, a.requestConfig.CacheDuration)
+				asset.cacheKey = apiCacheKey
 			}
 
 			asset.BucketID = kiosk.AlbumKeywordFavourites
@@ -7,7 +7,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"image"
 	"io"
+	"math"
 	"math/rand/v2"
 	"net/http"
 	"net/url"
@@ -21,7 +23,9 @@ import (
 	"github.com/damongolding/immich-kiosk/internal/cache"
 	"github.com/damongolding/immich-kiosk/internal/config"
 	"github.com/damongolding/immich-kiosk/internal/demo"
+	"github.com/damongolding/immich-kiosk/internal/diskcache"
 	"github.com/damongolding/immich-kiosk/internal/kiosk"
+	"github.com/damongolding/immich-kiosk/internal/seen"
 	"github.com/damongolding/immich-kiosk/internal/utils"
 	"github.com/google/go-querystring/query"
 )
@@ -241,6 +245,10 @@ func (a *Asset) fetchAssets(requestID, deviceID string, requestBody SearchRandom
 
 	FilterDate(&requestBody, a.requestConfig.FilterDate)
 
+	if a.requestConfig.Deck {
+		return a.fetchDeckAssets(requestID, deviceID, requestBody)
+	}
+
 	if filterNewest {
 		requestBody.Size = a.requestConfig.FilterNewest
 	}
@@ -349,6 +357,21 @@ func (a *Asset) AddRatio() {
 	}
 }
 
+// AspectRatio returns the asset's width divided by its height once EXIF orientation is
+// applied. Assets without dimensions are given a typical 3:2 or 2:3 ratio.
+func (a *Asset) AspectRatio() float64 {
+	width, height := a.displayDimensions()
+	if width > 0 && height > 0 {
+		return float64(width) / float64(height)
+	}
+
+	if a.IsPortrait {
+		return 2.0 / 3.0
+	}
+
+	return 3.0 / 2.0
+}
+
 // mergeAssetInfo merges additional asset information into the current Asset.
 // It uses reflection to examine each field of the current asset and updates
 // field values based on the following rules:
@@ -434,8 +457,56 @@ func (a *Asset) AssetInfo(requestID, deviceID string) error {
 	return a.mergeAssetInfo(immichAsset)
 }
 
-// ImagePreview fetches the raw image data from Immich
+// ImagePreview fetches the raw image data from Immich, the original if configured and
+// Kiosk can decode it.
 func (a *Asset) ImagePreview() ([]byte, string, error) {
+	useOriginal := a.requestConfig.UseOriginalImage && slices.Contains(kiosk.SupportedImageMimeTypes, a.OriginalMimeType)
+	return a.imagePreview(useOriginal)
+}
+
+// PreviewImage fetches Immich's preview of the asset, never the original.
+// Used when the original cannot be decoded.
+func (a *Asset) PreviewImage() ([]byte, string, error) {
+	return a.imagePreview(false)
+}
+
+// CacheKey returns the key the asset processed with params is kept in the disk cache
+// under. The asset's checksum and edited state are part of the key, so changes to the
+// asset in Immich are not served from the cache.
+func (a *Asset) CacheKey(params ...any) string {
+	return diskcache.Key(a.ID, append([]any{a.Checksum, a.IsEdited}, params...)...)
+}
+
+// Outbound returns a copy of the asset that is safe to send outside of Kiosk, such as
+// in webhook payloads. Details of the Immich server, like the asset's path on disk,
+// are removed.
+func (a *Asset) Outbound() Asset {
+	outbound := *a
+	outbound.OriginalPath = ""
+	return outbound
+}
+
+// imagePreview fetches the raw image data from Immich, either the original or the preview.
+// Fetched images are kept in the disk cache.
+func (a *Asset) imagePreview(useOriginal bool) ([]byte, string, error) {
+	key := a.CacheKey("image", useOriginal)
+
+	if data, found := diskcache.Get(key); found {
+		return data, utils.ImageMimeType(bytes.NewReader(data)), nil
+	}
+
+	b, contentType, err := a.fetchImagePreview(useOriginal)
+	if err != nil {
+		return b, contentType, err
+	}
+
+	diskcache.Set(key, b)
+
+	return b, contentType, nil
+}
+
+// fetchImagePreview fetches the raw image data from Immich, either the original or the preview.
+func (a *Asset) fetchImagePreview(useOriginal bool) ([]byte, string, error) {
 	var bytes []byte
 
 	u, err := url.Parse(a.requestConfig.ImmichURL)
@@ -445,7 +516,7 @@ func (a *Asset) ImagePreview() ([]byte, string, error) {
 	}
 
 	assetSize := AssetSizeThumbnail
-	if a.requestConfig.UseOriginalImage && slices.Contains(kiosk.SupportedImageMimeTypes, a.OriginalMimeType) {
+	if useOriginal {
 		assetSize = AssetSizeOriginal
 	}
 
@@ -456,7 +527,7 @@ func (a *Asset) ImagePreview() ([]byte, string, error) {
 		RawQuery: "size=preview",
 	}
 
-	if !a.requestConfig.UseOriginalImage {
+	if !useOriginal {
 		apiURL.RawQuery += "&edited=true"
 	}
 
@@ -610,6 +681,79 @@ func (a *Asset) FacesCenterPointPX() (float64, float64) {
 	return centerX, centerY
 }
 
+// FaceRects returns the bounding box of every detected face, both assigned (People)
+// and unassigned, scaled to an image of width by height pixels.
+// Faces without a bounding box or the dimensions they were detected at are skipped.
+func (a *Asset) FaceRects(width, height int) []image.Rectangle {
+	var rects []image.Rectangle
+
+	for _, person := range a.People {
+		for _, face := range person.Faces {
+			if rect, ok := face.rect(width, height); ok {
+				rects = append(rects, rect)
+			}
+		}
+	}
+
+	for _, face := range a.UnassignedFaces {
+		if rect, ok := face.rect(width, height); ok {
+			rects = append(rects, rect)
+		}
+	}
+
+	return rects
+}
+
+// PrivateFaceRects returns the bounding box of every face that should be hidden, scaled
+// to an image of width by height pixels. Unassigned faces and faces of unnamed people are
+// always returned. When allowedPeople is not empty, faces of people not in it are too.
+func (a *Asset) PrivateFaceRects(width, height int, allowedPeople []string) []image.Rectangle {
+	var rects []image.Rectangle
+
+	for _, person := range a.People {
+		if person.Name != "" && (len(allowedPeople) == 0 || slices.Contains(allowedPeople, person.ID)) {
+			continue
+		}
+
+		for _, face := range person.Faces {
+			if rect, ok := face.rect(width, height); ok {
+				rects = append(rects, rect)
+			}
+		}
+	}
+
+	for _, face := range a.UnassignedFaces {
+		if rect, ok := face.rect(width, height); ok {
+			rects = append(rects, rect)
+		}
+	}
+
+	return rects
+}
+
+// rect returns the face's bounding box scaled to an image of width by height pixels.
+// It returns false for faces without a bounding box or the dimensions they were
+// detected at.
+func (f Face) rect(width, height int) (image.Rectangle, bool) {
+	if f.ImageWidth == 0 || f.ImageHeight == 0 {
+		return image.Rectangle{}, false
+	}
+	if f.BoundingBoxX1 == 0 && f.BoundingBoxY1 == 0 &&
+		f.BoundingBoxX2 == 0 && f.BoundingBoxY2 == 0 {
+		return image.Rectangle{}, false
+	}
+
+	scaleX := float64(width) / float64(f.ImageWidth)
+	scaleY := float64(height) / float64(f.ImageHeight)
+
+	return image.Rect(
+		int(float64(f.BoundingBoxX1)*scaleX),
+		int(float64(f.BoundingBoxY1)*scaleY),
+		int(math.Ceil(float64(f.BoundingBoxX2)*scaleX)),
+		int(math.Ceil(float64(f.BoundingBoxY2)*scaleY)),
+	), true
+}
+
 // containsTag checks if an asset has a specific tag (case-insensitive).
 // It iterates through the asset's tags and compares the given tagValue
 // with each tag's value, ignoring case.
@@ -641,25 +785,52 @@ func (a *Asset) containsTag(tagValue string) bool {
 // Returns:
 //   - bool: true if asset meets all criteria, false otherwise
 func (a *Asset) isValidAsset(requestID, deviceID string, allowedTypes []AssetType, wantedRatio ImageOrientation) bool {
-	return a.hasValidBasicProperties(allowedTypes, wantedRatio) &&
+	return a.hasValidBasicProperties(requestID, allowedTypes, wantedRatio) &&
 		a.hasValidFilterDate() &&
 		a.hasValidPartners() &&
 		a.hasValidFilterExcludeFaces(requestID, deviceID) &&
 		a.hasValidAlbums(requestID, deviceID) &&
 		a.hasValidPeople(requestID, deviceID) &&
-		a.hasValidTags(requestID, deviceID)
+		a.hasValidTags(requestID, deviceID) &&
+		a.hasValidNoRepeat(requestID, deviceID)
+}
+
+// hasValidNoRepeat checks the asset has not been shown within the no-repeat window.
+// Assets from buckets played in order (the journey, and albums or memories in date
+// order) are not checked as their order already decides what is shown next.
+//
+// Parameters:
+//   - requestID: ID used for tracking API call chain
+//   - deviceID: ID of the device making the request
+//
+// Returns:
+//   - bool: true if the asset may be shown, false otherwise
+func (a *Asset) hasValidNoRepeat(requestID, deviceID string) bool {
+	if a.requestConfig.NoRepeatDays <= 0 || a.ordered {
+		return true
+	}
+
+	window := time.Duration(a.requestConfig.NoRepeatDays) * 24 * time.Hour
+
+	if seen.Within(seen.Key(deviceID, a.requestConfig.NoRepeatGroup), a.ID, window) {
+		log.Debug(requestID+" Asset shown within no-repeat window", "assetID", a.ID)
+		return false
+	}
+
+	return true
 }
 
 // hasValidBasicProperties checks basic asset properties including type,
-// trash status, archive status, aspect ratio and blacklist status.
+// trash status, archive status, aspect ratio, quality and path filters and blacklist status.
 //
 // Parameters:
+//   - requestID: ID used for tracking API call chain
 //   - allowedTypes: Slice of allowed asset types to check against
 //   - wantedRatio: Desired image orientation ratio
 //
 // Returns:
 //   - bool: true if basic properties are valid, false otherwise
-func (a *Asset) hasValidBasicProperties(allowedTypes []AssetType, wantedRatio ImageOrientation) bool {
+func (a *Asset) hasValidBasicProperties(requestID string, allowedTypes []AssetType, wantedRatio ImageOrientation) bool {
 	if !slices.Contains(allowedTypes, a.Type) {
 		return false
 	}
@@ -678,6 +849,12 @@ func (a *Asset) hasValidBasicProperties(allowedTypes []AssetType, wantedRatio Im
 	if !a.ratioCheck(wantedRatio) {
 		return false
 	}
+	if !a.hasValidQuality(requestID) {
+		return false
+	}
+	if !a.hasValidPath(requestID) {
+		return false
+	}
 	if slices.Contains(a.requestConfig.Blacklist, a.ID) {
 		return false
 	}
@@ -0,0 +1,281 @@
+package immich
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"charm.land/log/v2"
+	"github.com/google/go-querystring/query"
+
+	"github.com/damongolding/immich-kiosk/internal/config"
+	"github.com/damongolding/immich-kiosk/internal/kiosk"
+	"github.com/damongolding/immich-kiosk/internal/playback"
+)
+
+// journeyLocalTimeMargin is subtracted from anchors derived from an asset's local time,
+// which may be offset from the UTC capture time Immich filters on.
+const journeyLocalTimeMargin = 24 * time.Hour
+
+// JourneyBucketID returns the ID used for a journey bucket. The ID changes with the
+// journey start date and filters so each distinct journey keeps its own position.
+func JourneyBucketID(c config.Config) string {
+	parts := []string{string(kiosk.SourceJourney), c.JourneyStart}
+	parts = append(parts, c.JourneyPeople...)
+	parts = append(parts, c.JourneyAlbums...)
+	return strings.Join(parts, ":")
+}
+
+// assetTakenAt returns when an asset was captured, preferring the EXIF original date.
+// The bool reports whether the time came from the asset's local time.
+func assetTakenAt(asset Asset) (time.Time, bool) {
+	if !asset.ExifInfo.DateTimeOriginal.IsZero() {
+		return asset.ExifInfo.DateTimeOriginal, false
+	}
+	return asset.LocalDateTime, true
+}
+
+// journeyStart parses the configured journey start date. An empty start date
+// returns the zero time so the journey begins with the oldest asset.
+func journeyStart(start string) (time.Time, error) {
+	if start == "" {
+		return time.Time{}, nil
+	}
+
+	return time.ParseInLocation(time.DateOnly, start, time.Local)
+}
+
+// journeyPage fetches a page of assets taken on or after anchor, oldest first.
+//
+// Parameters:
+//   - anchor: Assets taken before this time are excluded. Zero starts from the oldest asset
+//   - requestID: ID used for tracking API call chain
+//   - deviceID: ID of the device making the request
+//
+// Returns:
+//   - []Asset: Assets in capture date order
+//   - int: The number of assets requested
+//   - error: Any error encountered during the request
+func (a *Asset) journeyPage(anchor time.Time, requestID, deviceID string) ([]Asset, int, error) {
+	var response SearchMetadataResponse
+
+	u, err := url.Parse(a.requestConfig.ImmichURL)
+	if err != nil {
+		_, _, err = immichAPIFail(response, err, nil, "")
+		return nil, 0, err
+	}
+
+	requestBody := SearchRandomBody{
+		Type:       string(ImageType),
+		Order:      "asc",
+		WithExif:   true,
+		WithPeople: true,
+		Size:       a.requestConfig.Kiosk.FetchedAssetsSize,
+		PersonIDs:  a.requestConfig.JourneyPeople,
+		AlbumIDs:   a.requestConfig.JourneyAlbums,
+	}
+
+	if !anchor.IsZero() {
+		requestBody.TakenAfter = anchor.UTC().Format(time.RFC3339)
+	}
+
+	// Include videos if show videos is enabled
+	if a.requestConfig.ShowVideos {
+		requestBody.Type = ""
+	}
+
+	if a.requestConfig.ShowArchived {
+		requestBody.WithArchived = true
+	}
+
+	// convert body to queries so url is unique and can be cached
+	queries, _ := query.Values(requestBody)
+
+	apiURL := url.URL{
+		Scheme:   u.Scheme,
+		Host:     u.Host,
+		Path:     "api/search/metadata",
+		RawQuery: queries.Encode(),
+	}
+
+	jsonBody, err := json.Marshal(requestBody)
+	if err != nil {
+		_, _, err = immichAPIFail(response, err, nil, apiURL.String())
+		return nil, 0, err
+	}
+
+	immichAPICall := withImmichAPICache(a.immichAPICall, requestID, deviceID, a.requestConfig, response)
+	apiBody, _, _, err := immichAPICall(a.ctx, http.MethodPost, apiURL.String(), jsonBody)
+	if err != nil {
+		_, _, err = immichAPIFail(response, err, apiBody, apiURL.String())
+		return nil, 0, err
+	}
+
+	err = json.Unmarshal(apiBody, &response)
+	if err != nil {
+		_, _, err = immichAPIFail(response, err, apiBody, apiURL.String())
+		return nil, 0, err
+	}
+
+	return response.Assets.Items, requestBody.Size, nil
+}
+
+// journeySeekAnchor looks up the asset a device has been asked to seek to and
+// returns the anchor to read the journey from so the asset is included.
+func (a *Asset) journeySeekAnchor(assetID, requestID, deviceID string) (time.Time, error) {
+	seekAsset := New(a.ctx, a.requestConfig)
+	seekAsset.ID = assetID
+
+	if err := seekAsset.AssetInfo(requestID, deviceID); err != nil {
+		return time.Time{}, err
+	}
+
+	taken, isLocal := assetTakenAt(seekAsset)
+	if isLocal {
+		return taken.Add(-journeyLocalTimeMargin), nil
+	}
+
+	return taken, nil
+}
+
+// JourneyAsset retrieves the next asset in a chronological walk through the library.
+//
+// Assets are read a page at a time from the metadata search endpoint, oldest first,
+// starting at the configured journey start date. The device's position (the page anchor
+// and last asset shown) is persisted so the journey resumes after restarts. Once the
+// end of the library is reached the journey starts again.
+//
+// Parameters:
+//   - journeyID: The journey bucket ID, see JourneyBucketID
+//   - requestID: ID used for tracking API call chain
+//   - deviceID: ID of the device making the request
+//
+// Returns:
+//   - error: If no viable asset could be found after max retries
+func (a *Asset) JourneyAsset(journeyID, requestID, deviceID string) error {
+	start, err := journeyStart(a.requestConfig.JourneyStart)
+	if err != nil {
+		return fmt.Errorf("parsing journey start: %w", err)
+	}
+
+	bucketID := playback.BucketID(journeyID, a.requestConfig.SelectedUser)
+	burstWindow := time.Duration(a.requestConfig.JourneySkipBursts) * time.Second
+
+	wantedAssetType := ImageOnlyAssetTypes
+	if a.requestConfig.ShowVideos {
+		wantedAssetType = AllAssetTypes
+	}
+
+	for range MaxRetries {
+
+		position, _ := playback.Get(deviceID, bucketID)
+
+		if position.Refresh {
+			playback.Refreshed(deviceID, bucketID)
+		}
+
+		anchor := position.Anchor
+
+		if position.SeekAssetID != "" {
+			seekAnchor, seekErr := a.journeySeekAnchor(position.SeekAssetID, requestID, deviceID)
+			if seekErr != nil {
+				log.Error(requestID+" Failed to find journey seek asset", "assetID", position.SeekAssetID, "err", seekErr)
+				position.SeekAssetID = ""
+			} else {
+				anchor = seekAnchor
+			}
+		}
+
+		if anchor.Before(start) {
+			anchor = start
+		}
+
+		assets, pageSize, pageErr := a.journeyPage(anchor, requestID, deviceID)
+		if pageErr != nil {
+			return pageErr
+		}
+
+		if len(assets) == 0 {
+			if anchor.Equal(start) {
+				return errors.New("no assets found for journey")
+			}
+
+			log.Info(requestID + " Journey reached the end, starting again")
+			playback.SetAt(deviceID, bucketID, "", start)
+			continue
+		}
+
+		// skip assets up to and including the last one shown, or the page end the journey
+		// is anchored on (or up to the seek asset)
+		startIndex := 0
+		for i, asset := range assets {
+			if position.SeekAssetID != "" {
+				if asset.ID == position.SeekAssetID {
+					startIndex = i
+					break
+				}
+				continue
+			}
+
+			if asset.ID == position.LastAssetID {
+				startIndex = i + 1
+				break
+			}
+		}
+
+		// bursts are measured from the last asset served, not the page end
+		lastTaken := position.LastTaken
+
+		for _, asset := range assets[startIndex:] {
+
+			taken, _ := assetTakenAt(asset)
+			if burstWindow > 0 && !lastTaken.IsZero() && taken.Sub(lastTaken) < burstWindow {
+				log.Debug(requestID+" Skipping journey burst asset", "assetID", asset.ID)
+				continue
+			}
+
+			asset.Bucket = kiosk.SourceJourney
+			asset.ordered = true
+			asset.requestConfig = a.requestConfig
+			asset.ctx = a.ctx
+
+			if !asset.isValidAsset(requestID, deviceID, wantedAssetType, a.RatioWanted) {
+				continue
+			}
+
+			asset.BucketID = journeyID
+
+			playback.SetTaken(deviceID, bucketID, asset.ID, anchor, taken)
+
+			*a = asset
+
+			return nil
+		}
+
+		// Page exhausted
+		if len(assets) < pageSize {
+			log.Info(requestID + " Journey reached the end, starting again")
+			playback.SetAt(deviceID, bucketID, "", start)
+			continue
+		}
+
+		// Move on to the next page, anchored on the last asset of this one
+		last := assets[len(assets)-1]
+		nextAnchor, isLocal := assetTakenAt(last)
+		if isLocal {
+			nextAnchor = nextAnchor.Add(-journeyLocalTimeMargin)
+		}
+
+		if !nextAnchor.After(anchor) {
+			nextAnchor = anchor.Add(time.Second)
+		}
+
+		playback.Page(deviceID, bucketID, last.ID, nextAnchor)
+	}
+
+	return fmt.Errorf("no assets found for journey after %d retries", MaxRetries)
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/damongolding/immich-kiosk/internal/cache"
 	"github.com/damongolding/immich-kiosk/internal/immich_open_api"
 	"github.com/damongolding/immich-kiosk/internal/kiosk"
+	"github.com/damongolding/immich-kiosk/internal/playback"
 	"github.com/dustin/go-humanize"
 )
 
@@ -342,6 +343,124 @@ func (a *Asset) RandomMemoryAsset(requestID, deviceID string) error {
 	return fmt.Errorf("no assets found for memories after %d retries", MaxRetries)
 }
 
+// memoryAssetRef references an asset within a MemoriesResponse.
+type memoryAssetRef struct {
+	memoryIndex int
+	assetIndex  int
+	id          string
+	taken       time.Time
+}
+
+// orderedMemoryAssets flattens memories into a single playback sequence sorted by
+// the date the assets were taken. Asc plays the oldest first, Desc the newest.
+func orderedMemoryAssets(memories MemoriesResponse, order AssetOrder) []memoryAssetRef {
+	refs := make([]memoryAssetRef, 0, memoriesCount(memories))
+
+	for memoryIndex, memory := range memories {
+		for assetIndex, asset := range memory.Assets {
+			refs = append(refs, memoryAssetRef{memoryIndex: memoryIndex, assetIndex: assetIndex, id: asset.ID, taken: asset.LocalDateTime})
+		}
+	}
+
+	slices.SortStableFunc(refs, func(x, y memoryAssetRef) int {
+		if order == Asc {
+			return x.taken.Compare(y.taken)
+		}
+		return y.taken.Compare(x.taken)
+	})
+
+	return refs
+}
+
+// OrderedMemoryAsset retrieves the next memory asset in sequence for the device.
+//
+// Unlike RandomMemoryAsset the cached memories are left intact, playback progresses
+// using the device's playback position so it survives restarts and the daily cache rollover.
+//
+// Parameters:
+//   - order: The order to play memory assets in (Asc or Desc)
+//   - requestID: Unique identifier for tracking the request
+//   - deviceID: ID of the requesting device
+//
+// Returns:
+//   - error: If unable to find valid image after max retries
+func (a *Asset) OrderedMemoryAsset(order AssetOrder, requestID, deviceID string) error {
+	for range MaxRetries {
+
+		var memories []Memory
+		var apiURL string
+		var err error
+
+		if a.requestConfig.PastMemoryDays > 0 {
+			memories, apiURL, err = a.MemoriesWithPastDays(requestID, deviceID, a.requestConfig.PastMemoryDays)
+		} else {
+			memories, apiURL, err = a.Memories(requestID, deviceID)
+		}
+		if err != nil {
+			return err
+		}
+
+		apiCacheKey := cache.APICacheKey(apiURL, deviceID, a.requestConfig.SelectedUser)
+
+		if memoriesCount(memories) == 0 {
+			log.Debug(requestID + " No assets left in cache. Refreshing and trying again for memories")
+			cache.Delete(apiCacheKey)
+			continue
+		}
+
+		bucketID := playback.BucketID(string(kiosk.SourceMemories), a.requestConfig.SelectedUser)
+
+		refs, refreshed := applyPlaybackPosition(orderedMemoryAssets(memories, order), func(ref memoryAssetRef) string { return ref.id }, apiCacheKey, requestID, deviceID, bucketID)
+		if refreshed {
+			continue
+		}
+
+		wantedAssetType := ImageOnlyAssetTypes
+		if a.requestConfig.ShowVideos {
+			wantedAssetType = AllAssetTypes
+		}
+
+		for _, ref := range refs {
+
+			memory := memories[ref.memoryIndex]
+			asset := memory.Assets[ref.assetIndex]
+
+			asset.Bucket = kiosk.SourceMemories
+			asset.ordered = true
+			asset.requestConfig = a.requestConfig
+			asset.ctx = a.ctx
+
+			// temp fix for memories not being supplied with EXIF
+			infoErr := asset.AssetInfo(requestID, deviceID)
+			if infoErr != nil {
+				log.Error("failed to get asset info", "error", infoErr)
+				continue
+			}
+
+			if !asset.isValidAsset(requestID, deviceID, wantedAssetType, a.RatioWanted) {
+				continue
+			}
+
+			if memory.Type == immich_open_api.OnThisDay {
+				asset.MemoryTitle = humanize.Time(memory.Assets[ref.assetIndex].LocalDateTime)
+			}
+
+			asset.BucketID = string(kiosk.SourceMemories)
+
+			playback.Set(deviceID, bucketID, asset.ID)
+
+			*a = asset
+
+			return nil
+		}
+
+		log.Debug(requestID + " No viable memory assets. Refreshing and trying again")
+		cache.Delete(apiCacheKey)
+	}
+
+	return fmt.Errorf("no assets found for memories after %d retries", MaxRetries)
+}
+
 // IsMemory checks if the asset is part of recent memories by querying the
 // memories API with a 5-minute cache window.
 //
@@ -0,0 +1,280 @@
+package immich
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"charm.land/log/v2"
+	"github.com/google/go-querystring/query"
+
+	"github.com/damongolding/immich-kiosk/internal/kiosk"
+)
+
+const (
+	// pairingEventWindow assets taken this close to the first asset are part of the same event
+	pairingEventWindow = 3 * time.Hour
+
+	// pairingThenAndNowSize how many of a person's oldest and newest assets are considered
+	pairingThenAndNowSize = 20
+)
+
+var errNoPairingCandidates = errors.New("no pairing candidates")
+
+// pairingSearch runs a search for pairing candidates. Results are cached in the same way
+// as other searches, but used assets are not removed as the same candidates are shared by
+// every asset they pair with.
+func (a *Asset) pairingSearch(requestBody SearchRandomBody, ordered bool, requestID, deviceID string) ([]Asset, error) {
+	var immichAssets []Asset
+
+	u, err := url.Parse(a.requestConfig.ImmichURL)
+	if err != nil {
+		_, _, err = immichAPIFail(immichAssets, err, nil, "")
+		return nil, err
+	}
+
+	requestBody.Type = string(ImageType)
+	requestBody.WithExif = true
+	requestBody.WithPeople = true
+	if requestBody.Size == 0 {
+		requestBody.Size = a.requestConfig.Kiosk.FetchedAssetsSize
+	}
+
+	if a.requestConfig.ShowArchived {
+		requestBody.WithArchived = true
+	}
+
+	apiPath := "api/search/random"
+	if ordered {
+		apiPath = "api/search/metadata"
+	}
+
+	// convert body to queries so url is unique and can be cached
+	queries, _ := query.Values(requestBody)
+
+	apiURL := url.URL{
+		Scheme:   u.Scheme,
+		Host:     u.Host,
+		Path:     apiPath,
+		RawQuery: queries.Encode(),
+	}
+
+	jsonBody, err := json.Marshal(requestBody)
+	if err != nil {
+		_, _, err = immichAPIFail(immichAssets, err, nil, apiURL.String())
+		return nil, err
+	}
+
+	if ordered {
+		var response SearchMetadataResponse
+
+		immichAPICall := withImmichAPICache(a.immichAPICall, requestID, deviceID, a.requestConfig, response)
+		apiBody, _, _, apiErr := immichAPICall(a.ctx, http.MethodPost, apiURL.String(), jsonBody)
+		if apiErr != nil {
+			_, _, err = immichAPIFail(response, apiErr, apiBody, apiURL.String())
+			return nil, err
+		}
+
+		if err = json.Unmarshal(apiBody, &response); err != nil {
+			_, _, err = immichAPIFail(response, err, apiBody, apiURL.String())
+			return nil, err
+		}
+
+		return response.Assets.Items, nil
+	}
+
+	immichAPICall := withImmichAPICache(a.immichAPICall, requestID, deviceID, a.requestConfig, immichAssets)
+	apiBody, _, _, err := immichAPICall(a.ctx, http.MethodPost, apiURL.String(), jsonBody)
+	if err != nil {
+		_, _, err = immichAPIFail(immichAssets, err, apiBody, apiURL.String())
+		return nil, err
+	}
+
+	if err = json.Unmarshal(apiBody, &immichAssets); err != nil {
+		_, _, err = immichAPIFail(immichAssets, err, apiBody, apiURL.String())
+		return nil, err
+	}
+
+	return immichAssets, nil
+}
+
+// pairingPersonID returns the person the first asset is paired by. The person the asset
+// was picked for is preferred, otherwise the first named person in the asset.
+func pairingPersonID(first Asset) string {
+	if first.Bucket == kiosk.SourcePerson && first.BucketID != kiosk.PersonKeywordAll {
+		personID, _, _ := strings.Cut(first.BucketID, "@")
+		return personID
+	}
+
+	for _, person := range first.People {
+		if person.Name != "" {
+			return person.ID
+		}
+	}
+
+	return ""
+}
+
+// pairingDay returns the start and end of the day the first asset was taken.
+func pairingDay(first Asset) (time.Time, time.Time) {
+	taken := first.LocalDateTime
+	if taken.IsZero() {
+		taken, _ = assetTakenAt(first)
+	}
+
+	dayStart := time.Date(taken.Year(), taken.Month(), taken.Day(), 0, 0, 0, 0, time.Local)
+	dayEnd := time.Date(taken.Year(), taken.Month(), taken.Day(), 23, 59, 59, 999999999, time.Local)
+
+	return dayStart, dayEnd
+}
+
+// thenAndNowCandidates returns assets of a person from the opposite end of their
+// timeline to the first asset: their newest assets if the first asset is closer to
+// their oldest, and their oldest assets otherwise.
+func (a *Asset) thenAndNowCandidates(personID string, first Asset, requestID, deviceID string) ([]Asset, error) {
+	oldest, err := a.pairingSearch(SearchRandomBody{
+		PersonIDs: []string{personID},
+		Order:     "asc",
+		Size:      pairingThenAndNowSize,
+	}, true, requestID, deviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	newest, err := a.pairingSearch(SearchRandomBody{
+		PersonIDs: []string{personID},
+		Order:     "desc",
+		Size:      pairingThenAndNowSize,
+	}, true, requestID, deviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(oldest) == 0 || len(newest) == 0 {
+		return nil, nil
+	}
+
+	taken, _ := assetTakenAt(first)
+	oldestTaken, _ := assetTakenAt(oldest[0])
+	newestTaken, _ := assetTakenAt(newest[0])
+
+	if taken.Sub(oldestTaken) < newestTaken.Sub(taken) {
+		return newest, nil
+	}
+
+	return oldest, nil
+}
+
+// PairedAsset picks an asset to show alongside first in a split view.
+//
+// Strategies:
+//   - same-day: taken on the same day as first
+//   - same-event: taken within a few hours of first
+//   - same-person: of the same person as first
+//   - then-and-now: of the same person as first, from the other end of their timeline
+//   - same-location: taken in the same city as first
+//
+// Parameters:
+//   - strategy: The pairing strategy to use
+//   - first: The asset being paired with
+//   - requestID: ID used for tracking API call chain
+//   - deviceID: ID of the device making the request
+//
+// Returns:
+//   - error: Any error encountered, including when no asset pairs with first
+func (a *Asset) PairedAsset(strategy string, first Asset, requestID, deviceID string) error {
+	var candidates []Asset
+	var err error
+
+	bucket := kiosk.SourceRandom
+	bucketID := ""
+
+	switch strategy {
+	case kiosk.PairingSameDay:
+		dayStart, dayEnd := pairingDay(first)
+		candidates, err = a.pairingSearch(SearchRandomBody{
+			TakenAfter:  dayStart.Format(time.RFC3339),
+			TakenBefore: dayEnd.Format(time.RFC3339),
+		}, false, requestID, deviceID)
+
+		bucket = kiosk.SourceDateRange
+		bucketID = dayStart.Format(time.DateOnly) + "_to_" + dayEnd.Format(time.DateOnly)
+
+	case kiosk.PairingSameEvent:
+		taken, _ := assetTakenAt(first)
+		if taken.IsZero() {
+			return errNoPairingCandidates
+		}
+		candidates, err = a.pairingSearch(SearchRandomBody{
+			TakenAfter:  taken.Add(-pairingEventWindow).UTC().Format(time.RFC3339),
+			TakenBefore: taken.Add(pairingEventWindow).UTC().Format(time.RFC3339),
+		}, false, requestID, deviceID)
+
+	case kiosk.PairingSamePerson, kiosk.PairingThenAndNow:
+		personID := pairingPersonID(first)
+		if personID == "" {
+			return errNoPairingCandidates
+		}
+
+		if strategy == kiosk.PairingSamePerson {
+			candidates, err = a.pairingSearch(SearchRandomBody{PersonIDs: []string{personID}}, false, requestID, deviceID)
+		} else {
+			candidates, err = a.thenAndNowCandidates(personID, first, requestID, deviceID)
+		}
+
+		bucket = kiosk.SourcePerson
+		bucketID = personID
+
+	case kiosk.PairingSameLocation:
+		if first.ExifInfo.City == "" {
+			return errNoPairingCandidates
+		}
+		candidates, err = a.pairingSearch(SearchRandomBody{
+			City:    first.ExifInfo.City,
+			Country: first.ExifInfo.Country,
+		}, false, requestID, deviceID)
+
+	default:
+		return fmt.Errorf("unknown pairing strategy '%s'", strategy)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	rand.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+
+	for _, asset := range candidates {
+		if asset.ID == first.ID {
+			continue
+		}
+
+		asset.Bucket = bucket
+		asset.requestConfig = a.requestConfig
+		asset.ctx = a.ctx
+
+		if !asset.isValidAsset(requestID, deviceID, ImageOnlyAssetTypes, a.RatioWanted) {
+			continue
+		}
+
+		asset.BucketID = bucketID
+		if bucketID != "" && asset.requestConfig.SelectedUser != "" {
+			asset.BucketID = fmt.Sprintf("%s@%s", bucketID, asset.requestConfig.SelectedUser)
+		}
+
+		*a = asset
+
+		return nil
+	}
+
+	log.Debug(requestID+" No assets to pair with", "strategy", strategy, "assetID", first.ID)
+
+	return errNoPairingCandidates
+}
@@ -235,6 +235,8 @@ func (a *Asset) RandomAssetOfPerson(personID, requestID, deviceID string, isPref
 			wantedAssetType = AllAssetTypes
 		}
 
+		immichAssets = a.applyWeighting(immichAssets, deviceID)
+
 		for immichAssetIndex, asset := range immichAssets {
 
 			asset.Bucket = kiosk.SourcePerson
@@ -256,6 +258,7 @@ func (a *Asset) RandomAssetOfPerson(personID, requestID, deviceID string, isPref
 
 				// Replace cache with remaining assets after removing used asset(s)
 				cache.Set(apiCacheKey, jsonBytes, a.requestConfig.Duration, a.requestConfig.CacheDuration)
+				asset.cacheKey = apiCacheKey
 			}
 
 			asset.BucketID = personID
@@ -0,0 +1,44 @@
+package immich
+
+import (
+	"slices"
+
+	"charm.land/log/v2"
+
+	"github.com/damongolding/immich-kiosk/internal/cache"
+	"github.com/damongolding/immich-kiosk/internal/playback"
+)
+
+// applyPlaybackPosition rotates an ordered list so playback resumes from the device's
+// persisted position for bucketID, wrapping back round to the start of the list.
+//
+// If a refresh of the bucket has been requested (via a reset or seek) the cached API
+// response is removed and refreshed is returned as true so the caller can refetch
+// the bucket before applying the position.
+func applyPlaybackPosition[T any](items []T, id func(T) string, apiCacheKey, requestID, deviceID, bucketID string) ([]T, bool) {
+	position, found := playback.Get(deviceID, bucketID)
+	if !found {
+		return items, false
+	}
+
+	if position.Refresh {
+		log.Debug(requestID+" Playback position changed, refreshing", "bucket", bucketID)
+		cache.Delete(apiCacheKey)
+		playback.Refreshed(deviceID, bucketID)
+		return items, true
+	}
+
+	ids := make([]string, len(items))
+	for i, item := range items {
+		ids[i] = id(item)
+	}
+
+	start := playback.StartIndex(position, ids)
+	if start == 0 {
+		return items, false
+	}
+
+	log.Debug(requestID+" Resuming playback", "bucket", bucketID, "index", start)
+
+	return slices.Concat(items[start:], items[:start]), false
+}
@@ -0,0 +1,127 @@
+package immich
+
+import (
+	"slices"
+	"strings"
+
+	"charm.land/log/v2"
+
+	"github.com/damongolding/immich-kiosk/internal/kiosk"
+	"github.com/damongolding/immich-kiosk/internal/pathmatch"
+)
+
+// screenResolutions are common phone, tablet and monitor resolutions (in portrait and
+// landscape) used to recognise screenshots.
+var screenResolutions = [][2]int{
+	// monitors and laptops
+	{1280, 720}, {1280, 800}, {1366, 768}, {1440, 900}, {1536, 864}, {1600, 900},
+	{1680, 1050}, {1920, 1080}, {1920, 1200}, {2560, 1440}, {2560, 1600}, {2880, 1800},
+	{3024, 1964}, {3440, 1440}, {3456, 2234}, {3840, 2160}, {5120, 2880},
+	// tablets
+	{2048, 1536}, {2160, 1620}, {2360, 1640}, {2388, 1668}, {2732, 2048},
+	// phones
+	{750, 1334}, {828, 1792}, {1080, 2340}, {1080, 2400}, {1125, 2436},
+	{1170, 2532}, {1179, 2556}, {1206, 2622}, {1242, 2208}, {1242, 2688}, {1284, 2778},
+	{1290, 2796}, {1320, 2868}, {1440, 3120}, {1440, 3200},
+}
+
+// displayDimensions returns the asset's width and height once EXIF orientation is applied.
+func (a *Asset) displayDimensions() (int, int) {
+	width, height := a.ExifInfo.ExifImageWidth, a.ExifInfo.ExifImageHeight
+
+	switch a.ExifInfo.Orientation {
+	case "5", "6", "7", "8":
+		return height, width
+	}
+
+	return width, height
+}
+
+// isScreenResolution reports whether the dimensions match a common screen resolution
+// in either orientation.
+func isScreenResolution(width, height int) bool {
+	return slices.ContainsFunc(screenResolutions, func(r [2]int) bool {
+		return (width == r[0] && height == r[1]) || (width == r[1] && height == r[0])
+	})
+}
+
+// isScreenshot guesses whether the asset is a screenshot. Screenshots have no camera
+// make, are PNGs and are either named as a screenshot or sized to a device's screen.
+func (a *Asset) isScreenshot() bool {
+	if a.ExifInfo.Make != "" || a.OriginalMimeType != kiosk.MimeTypePng {
+		return false
+	}
+
+	if strings.Contains(strings.ToLower(a.OriginalFileName), "screenshot") {
+		return true
+	}
+
+	return isScreenResolution(a.ExifInfo.ExifImageWidth, a.ExifInfo.ExifImageHeight)
+}
+
+// hasValidQuality checks the asset against the quality filters: minimum dimensions,
+// minimum file size, excluded mime types and screenshots. Dimensions and file size are
+// only checked when Immich knows them.
+//
+// Parameters:
+//   - requestID: ID used for tracking API call chain
+//
+// Returns:
+//   - bool: true if the asset passes every quality filter, false otherwise
+func (a *Asset) hasValidQuality(requestID string) bool {
+	c := a.requestConfig
+
+	width, height := a.displayDimensions()
+
+	if c.FilterMinWidth > 0 && width > 0 && width < c.FilterMinWidth {
+		log.Debug(requestID+" Asset below minimum width", "assetID", a.ID, "width", width, "min", c.FilterMinWidth)
+		return false
+	}
+
+	if c.FilterMinHeight > 0 && height > 0 && height < c.FilterMinHeight {
+		log.Debug(requestID+" Asset below minimum height", "assetID", a.ID, "height", height, "min", c.FilterMinHeight)
+		return false
+	}
+
+	fileSize := int64(a.ExifInfo.FileSizeInByte)
+	if c.FilterMinFileSizeBytes > 0 && fileSize > 0 && fileSize < c.FilterMinFileSizeBytes {
+		log.Debug(requestID+" Asset below minimum file size", "assetID", a.ID, "size", fileSize, "min", c.FilterMinFileSize)
+		return false
+	}
+
+	if slices.Contains(c.FilterExcludeMimeTypes, strings.ToLower(a.OriginalMimeType)) {
+		log.Debug(requestID+" Asset mime type excluded", "assetID", a.ID, "mimeType", a.OriginalMimeType)
+		return false
+	}
+
+	if c.FilterExcludeScreenshots && a.Type == ImageType && a.isScreenshot() {
+		log.Debug(requestID+" Asset looks like a screenshot", "assetID", a.ID, "file", a.OriginalFileName)
+		return false
+	}
+
+	return true
+}
+
+// hasValidPath checks the asset's filename and original path against the include
+// and exclude path patterns.
+//
+// Parameters:
+//   - requestID: ID used for tracking API call chain
+//
+// Returns:
+//   - bool: true if the asset is included and not excluded, false otherwise
+func (a *Asset) hasValidPath(requestID string) bool {
+	c := a.requestConfig
+
+	if len(c.FilterIncludePaths) > 0 && !pathmatch.Match(c.FilterIncludePaths, a.OriginalFileName, a.OriginalPath) {
+		log.Debug(requestID+" Asset not in included paths", "assetID", a.ID, "file", a.OriginalFileName)
+		return false
+	}
+
+	if len(c.FilterExcludePaths) > 0 && pathmatch.Match(c.FilterExcludePaths, a.OriginalFileName, a.OriginalPath) {
+		log.Debug(requestID+" Asset in excluded paths", "assetID", a.ID, "file", a.OriginalFileName)
+		return false
+	}
+
+	return true
+}
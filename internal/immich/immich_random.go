@@ -69,6 +69,8 @@ func (a *Asset) RandomAsset(requestID, deviceID string, isPrefetch bool) error {
 			wantedAssetType = AllAssetTypes
 		}
 
+		immichAssets = a.applyWeighting(immichAssets, deviceID)
+
 		for immichAssetIndex, asset := range immichAssets {
 
 			asset.Bucket = kiosk.SourceRandom
@@ -90,6 +92,7 @@ func (a *Asset) RandomAsset(requestID, deviceID string, isPrefetch bool) error {
 
 				// replace with cache minus used asset
 				cache.Set(apiCacheKey, jsonBytes, a.requestConfig.Duration, a.requestConfig.CacheDuration)
+				asset.cacheKey = apiCacheKey
 			}
 
 			asset.BucketID = string(kiosk.SourceRandom)
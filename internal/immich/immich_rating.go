@@ -122,6 +122,8 @@ func (a *Asset) RandomAssetWithRating(ratingID string, requestID, deviceID strin
 			wantedAssetType = AllAssetTypes
 		}
 
+		immichAssets = a.applyWeighting(immichAssets, deviceID)
+
 		for immichAssetIndex, asset := range immichAssets {
 
 			asset.Bucket = kiosk.SourceRating
@@ -143,6 +145,7 @@ func (a *Asset) RandomAssetWithRating(ratingID string, requestID, deviceID strin
 
 				// replace cache with used asset(s) removed
 				cache.Set(apiCacheKey, jsonBytes, a.requestConfig.Duration, a.requestConfig.CacheDuration)
+				asset.cacheKey = apiCacheKey
 			}
 
 			asset.BucketID = fmt.Sprintf("rating-%.2f", rating)
@@ -0,0 +1,130 @@
+package immich
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+
+	"github.com/dustin/go-humanize"
+
+	"github.com/damongolding/immich-kiosk/internal/story"
+)
+
+// newStory builds a story from assets, keeping only the images that pass the
+// request's filters. Assets are kept in the order given.
+func (a *Asset) newStory(id, title string, assets []Asset, requestID string) story.Story {
+	s := story.Story{
+		ID:    id,
+		Title: title,
+	}
+
+	for _, asset := range assets {
+		asset.requestConfig = a.requestConfig
+
+		if !asset.hasValidBasicProperties(requestID, ImageOnlyAssetTypes, "") {
+			continue
+		}
+
+		taken, _ := assetTakenAt(asset)
+		if !taken.IsZero() {
+			if s.Start.IsZero() || taken.Before(s.Start) {
+				s.Start = taken
+			}
+			if taken.After(s.End) {
+				s.End = taken
+			}
+		}
+
+		for _, person := range asset.People {
+			if person.Name != "" && !slices.Contains(s.People, person.Name) {
+				s.People = append(s.People, person.Name)
+			}
+		}
+
+		s.AssetIDs = append(s.AssetIDs, asset.ID)
+	}
+
+	if maxAssets := a.requestConfig.StoryMaxAssets; maxAssets > 0 && len(s.AssetIDs) > maxAssets {
+		s.AssetIDs = s.AssetIDs[:maxAssets]
+	}
+
+	return s
+}
+
+// AlbumStory returns an album as a story, its assets ordered by when they were taken.
+//
+// The album is requested separately from the album source so used assets, which the
+// album source removes from its cache, are still part of the story.
+//
+// Parameters:
+//   - albumID: The ID of the album
+//   - requestID: ID used for tracking API call chain
+//   - deviceID: ID of the device making the request
+//
+// Returns:
+//   - story.Story: The album as a story
+//   - error: Any error encountered during the request
+func (a *Asset) AlbumStory(albumID, requestID, deviceID string) (story.Story, error) {
+	var album Album
+
+	u, err := url.Parse(a.requestConfig.ImmichURL)
+	if err != nil {
+		_, _, err = immichAPIFail(album, err, nil, "")
+		return story.Story{}, err
+	}
+
+	apiURL := url.URL{
+		Scheme:   u.Scheme,
+		Host:     u.Host,
+		Path:     path.Join("api", "albums", albumID),
+		RawQuery: "withoutAssets=false",
+	}
+
+	immichAPICall := withImmichAPICache(a.immichAPICall, requestID, deviceID, a.requestConfig, album)
+	body, _, _, err := immichAPICall(a.ctx, http.MethodGet, apiURL.String(), nil)
+	if err != nil {
+		_, _, err = immichAPIFail(album, err, body, apiURL.String())
+		return story.Story{}, err
+	}
+
+	if err = json.Unmarshal(body, &album); err != nil {
+		_, _, err = immichAPIFail(album, err, body, apiURL.String())
+		return story.Story{}, err
+	}
+
+	if len(album.Assets) == 0 {
+		return story.Story{}, fmt.Errorf("album '%s' has no assets", albumID)
+	}
+
+	slices.SortStableFunc(album.Assets, func(x, y Asset) int {
+		xTaken, _ := assetTakenAt(x)
+		yTaken, _ := assetTakenAt(y)
+		return xTaken.Compare(yTaken)
+	})
+
+	return a.newStory(album.ID, album.AlbumName, album.Assets, requestID), nil
+}
+
+// MemoryStory returns the memory the asset belongs to as a story, its assets in the
+// order Immich gives them.
+//
+// Returns:
+//   - story.Story: The memory as a story
+//   - bool: false if the asset is not part of a memory
+func (a *Asset) MemoryStory(requestID string) (story.Story, bool) {
+	isMemory, memory, _ := a.IsMemory()
+	if !isMemory || len(memory.Assets) == 0 {
+		return story.Story{}, false
+	}
+
+	title := a.MemoryTitle
+	if title == "" {
+		taken, _ := assetTakenAt(memory.Assets[0])
+		title = humanize.Time(taken)
+	}
+
+	return a.newStory(memory.ID, title, memory.Assets, requestID), true
+}
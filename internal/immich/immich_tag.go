@@ -175,6 +175,8 @@ func (a *Asset) RandomAssetWithTag(tagID string, requestID, deviceID string, isP
 			wantedAssetType = AllAssetTypes
 		}
 
+		immichAssets = a.applyWeighting(immichAssets, deviceID)
+
 		for immichAssetIndex, asset := range immichAssets {
 
 			asset.Bucket = kiosk.SourceTag
@@ -196,6 +198,7 @@ func (a *Asset) RandomAssetWithTag(tagID string, requestID, deviceID string, isP
 
 				// replace cache with used asset(s) removed
 				cache.Set(apiCacheKey, jsonBytes, a.requestConfig.Duration, a.requestConfig.CacheDuration)
+				asset.cacheKey = apiCacheKey
 			}
 
 			asset.BucketID = tagID
@@ -1,11 +1,23 @@
 package immich
 
 import (
+	"context"
+	"encoding/json"
+	"image"
+	"net/http"
+	"net/http/httptest"
 	"slices"
 	"testing"
+	"time"
 
+	"github.com/damongolding/immich-kiosk/internal/cache"
+	"github.com/damongolding/immich-kiosk/internal/config"
 	"github.com/damongolding/immich-kiosk/internal/kiosk"
+	"github.com/damongolding/immich-kiosk/internal/playback"
+	"github.com/damongolding/immich-kiosk/internal/seen"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
 )
 
 // TestArchiveLogic tests the handling of archived and trashed assets
@@ -651,3 +663,404 @@ func TestIsAnimatedGif(t *testing.T) {
 		})
 	}
 }
+
+func TestHasValidQuality(t *testing.T) {
+	photo := ExifInfo{Make: "Canon", ExifImageWidth: 4000, ExifImageHeight: 3000, FileSizeInByte: 4_000_000}
+
+	tests := []struct {
+		name     string
+		config   config.Config
+		asset    Asset
+		expected bool
+	}{
+		{
+			name:     "no filters",
+			asset:    Asset{Type: ImageType, ExifInfo: ExifInfo{ExifImageWidth: 10, ExifImageHeight: 10}},
+			expected: true,
+		},
+		{
+			name:     "meets minimum dimensions",
+			config:   config.Config{FilterMinWidth: 1920, FilterMinHeight: 1080},
+			asset:    Asset{Type: ImageType, ExifInfo: photo},
+			expected: true,
+		},
+		{
+			name:     "below minimum width",
+			config:   config.Config{FilterMinWidth: 1920},
+			asset:    Asset{Type: ImageType, ExifInfo: ExifInfo{ExifImageWidth: 1280, ExifImageHeight: 960}},
+			expected: false,
+		},
+		{
+			name:     "rotated asset uses display dimensions",
+			config:   config.Config{FilterMinHeight: 2000},
+			asset:    Asset{Type: ImageType, ExifInfo: ExifInfo{ExifImageWidth: 3000, ExifImageHeight: 1500, Orientation: "6"}},
+			expected: true,
+		},
+		{
+			name:     "unknown dimensions are not filtered",
+			config:   config.Config{FilterMinWidth: 1920},
+			asset:    Asset{Type: ImageType},
+			expected: true,
+		},
+		{
+			name:     "below minimum file size",
+			config:   config.Config{FilterMinFileSizeBytes: 500 * 1024},
+			asset:    Asset{Type: ImageType, ExifInfo: ExifInfo{FileSizeInByte: 80_000}},
+			expected: false,
+		},
+		{
+			name:     "excluded mime type",
+			config:   config.Config{FilterExcludeMimeTypes: []string{kiosk.MimeTypePng}},
+			asset:    Asset{Type: ImageType, OriginalMimeType: kiosk.MimeTypePng},
+			expected: false,
+		},
+		{
+			name:     "screenshot by resolution",
+			config:   config.Config{FilterExcludeScreenshots: true},
+			asset:    Asset{Type: ImageType, OriginalMimeType: kiosk.MimeTypePng, ExifInfo: ExifInfo{ExifImageWidth: 1179, ExifImageHeight: 2556}},
+			expected: false,
+		},
+		{
+			name:     "screenshot by name",
+			config:   config.Config{FilterExcludeScreenshots: true},
+			asset:    Asset{Type: ImageType, OriginalMimeType: kiosk.MimeTypePng, OriginalFileName: "Screenshot 2024-01-01.png"},
+			expected: false,
+		},
+		{
+			name:     "png from a camera is not a screenshot",
+			config:   config.Config{FilterExcludeScreenshots: true},
+			asset:    Asset{Type: ImageType, OriginalMimeType: kiosk.MimeTypePng, ExifInfo: ExifInfo{Make: "Apple", ExifImageWidth: 1179, ExifImageHeight: 2556}},
+			expected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.asset.requestConfig = tt.config
+			assert.Equal(t, tt.expected, tt.asset.hasValidQuality("test"))
+		})
+	}
+}
+
+// TestPairingPersonID tests which person a split view asset is paired by
+func TestPairingPersonID(t *testing.T) {
+	tests := []struct {
+		name  string
+		asset Asset
+		want  string
+	}{
+		{
+			name:  "picked for person",
+			asset: Asset{Bucket: kiosk.SourcePerson, BucketID: "PERSON_1@user", People: []Person{{ID: "PERSON_2", Name: "Two"}}},
+			want:  "PERSON_1",
+		},
+		{
+			name:  "first named person",
+			asset: Asset{Bucket: kiosk.SourceAlbum, People: []Person{{ID: "PERSON_1"}, {ID: "PERSON_2", Name: "Two"}}},
+			want:  "PERSON_2",
+		},
+		{
+			name:  "all people keyword",
+			asset: Asset{Bucket: kiosk.SourcePerson, BucketID: kiosk.PersonKeywordAll},
+			want:  "",
+		},
+		{
+			name:  "no people",
+			asset: Asset{Bucket: kiosk.SourceRandom},
+			want:  "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, pairingPersonID(tt.asset))
+		})
+	}
+}
+
+// TestNewStory tests which assets make up a story and what it records about them
+func TestNewStory(t *testing.T) {
+	a := Asset{requestConfig: config.Config{StoryMaxAssets: 2}}
+
+	day := func(d int) ExifInfo {
+		return ExifInfo{DateTimeOriginal: time.Date(2024, 7, d, 12, 0, 0, 0, time.UTC)}
+	}
+
+	assets := []Asset{
+		{ID: "1", Type: ImageType, ExifInfo: day(1), People: []Person{{Name: "Ann"}}},
+		{ID: "2", Type: VideoType, ExifInfo: day(2)},
+		{ID: "3", Type: ImageType, ExifInfo: day(3), IsTrashed: true},
+		{ID: "4", Type: ImageType, ExifInfo: day(4), People: []Person{{Name: "Ann"}, {Name: "Ben"}, {Name: ""}}},
+		{ID: "5", Type: ImageType, ExifInfo: day(5)},
+	}
+
+	s := a.newStory("album-1", "Summer", assets, "")
+
+	assert.Equal(t, "album-1", s.ID)
+	assert.Equal(t, "Summer", s.Title)
+	assert.Equal(t, []string{"1", "4"}, s.AssetIDs, "videos and trashed assets are skipped and the story capped")
+	assert.Equal(t, []string{"Ann", "Ben"}, s.People)
+	assert.Equal(t, 1, s.Start.Day())
+	assert.Equal(t, 5, s.End.Day(), "dates cover every valid asset, not just those shown")
+}
+
+// TestFaceRects tests face bounding boxes are scaled to the image they are drawn on
+func TestFaceRects(t *testing.T) {
+	a := Asset{
+		People: []Person{
+			{Faces: []Face{{ImageWidth: 1000, ImageHeight: 500, BoundingBoxX1: 100, BoundingBoxY1: 50, BoundingBoxX2: 200, BoundingBoxY2: 150}}},
+		},
+		UnassignedFaces: []Face{
+			{ImageWidth: 1000, ImageHeight: 500, BoundingBoxX1: 500, BoundingBoxY1: 100, BoundingBoxX2: 600, BoundingBoxY2: 200},
+			{ImageWidth: 1000, ImageHeight: 500},
+			{BoundingBoxX1: 1, BoundingBoxY1: 1, BoundingBoxX2: 2, BoundingBoxY2: 2},
+		},
+	}
+
+	assert.Equal(t, []image.Rectangle{
+		image.Rect(200, 100, 400, 300),
+		image.Rect(1000, 200, 1200, 400),
+	}, a.FaceRects(2000, 1000))
+}
+
+// TestPrivateFaceRects tests only unnamed, unassigned and not allowed faces are hidden
+func TestPrivateFaceRects(t *testing.T) {
+	face := func(x int) Face {
+		return Face{ImageWidth: 1000, ImageHeight: 500, BoundingBoxX1: x, BoundingBoxY1: 0, BoundingBoxX2: x + 10, BoundingBoxY2: 10}
+	}
+
+	a := Asset{
+		People: []Person{
+			{ID: "alex", Name: "Alex", Faces: []Face{face(0)}},
+			{ID: "sam", Name: "Sam", Faces: []Face{face(100)}},
+			{ID: "unnamed", Faces: []Face{face(200)}},
+		},
+		UnassignedFaces: []Face{face(300)},
+	}
+
+	assert.Equal(t, []image.Rectangle{
+		image.Rect(200, 0, 210, 10),
+		image.Rect(300, 0, 310, 10),
+	}, a.PrivateFaceRects(1000, 500, nil), "named people are shown")
+
+	assert.Equal(t, []image.Rectangle{
+		image.Rect(100, 0, 110, 10),
+		image.Rect(200, 0, 210, 10),
+		image.Rect(300, 0, 310, 10),
+	}, a.PrivateFaceRects(1000, 500, []string{"alex", "unnamed"}), "only allowed, named people are shown")
+}
+
+// TestNoRepeatOrderedBuckets tests only assets from buckets played in order skip the
+// no-repeat window, so favourites are not exempt because they share the album source
+func TestNoRepeatOrderedBuckets(t *testing.T) {
+	cfg := config.Config{NoRepeatDays: 1, AlbumOrder: config.AlbumOrderAsc}
+
+	seen.Mark(seen.Key("no-repeat-device", ""), "shown-asset")
+
+	favourite := Asset{ID: "shown-asset", Bucket: kiosk.SourceAlbum, BucketID: kiosk.AlbumKeywordFavourites, requestConfig: cfg}
+	assert.False(t, favourite.hasValidNoRepeat("", "no-repeat-device"))
+
+	ordered := Asset{ID: "shown-asset", Bucket: kiosk.SourceAlbum, ordered: true, requestConfig: cfg}
+	assert.True(t, ordered.hasValidNoRepeat("", "no-repeat-device"))
+
+	unseen := Asset{ID: "unseen-asset", Bucket: kiosk.SourceAlbum, requestConfig: cfg}
+	assert.True(t, unseen.hasValidNoRepeat("", "no-repeat-device"))
+}
+
+// TestJourneySkipsBurstsOfTheLastServedAsset tests bursts are measured from the last
+// asset shown, not the unseen page end the journey moved on from
+func TestJourneySkipsBurstsOfTheLastServedAsset(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	asset := func(id string, offset time.Duration) Asset {
+		a := Asset{ID: id, Type: ImageType}
+		a.ExifInfo.DateTimeOriginal = start.Add(offset)
+		return a
+	}
+
+	library := []Asset{
+		asset("served", 0),
+		asset("burst", 5*time.Second),
+		asset("after-burst", 12*time.Second),
+		asset("later", 5*time.Minute),
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body SearchRandomBody
+		_ = json.NewDecoder(r.Body).Decode(&body)
+
+		var response SearchMetadataResponse
+		for _, a := range library {
+			if body.TakenAfter != "" {
+				after, _ := time.Parse(time.RFC3339, body.TakenAfter)
+				if a.ExifInfo.DateTimeOriginal.Before(after) {
+					continue
+				}
+			}
+			if len(response.Assets.Items) < body.Size {
+				response.Assets.Items = append(response.Assets.Items, a)
+			}
+		}
+
+		_ = json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := config.Config{ImmichURL: server.URL, ImmichAPIKey: "key", JourneySkipBursts: 10}
+	cfg.Kiosk.FetchedAssetsSize = 2
+
+	deviceID := "journey-burst-device"
+	journeyID := JourneyBucketID(cfg)
+	bucketID := playback.BucketID(journeyID, "")
+	t.Cleanup(func() { playback.Reset(deviceID, bucketID) })
+
+	playback.SetTaken(deviceID, bucketID, "served", time.Time{}, start)
+
+	journey := New(context.Background(), cfg)
+	require.NoError(t, journey.JourneyAsset(journeyID, "request", deviceID))
+	assert.Equal(t, "after-burst", journey.ID)
+
+	position, _ := playback.Get(deviceID, bucketID)
+	assert.Equal(t, "after-burst", position.LastAssetID)
+	assert.True(t, position.LastTaken.Equal(start.Add(12*time.Second)))
+}
+
+// TestOriginalPathStaysServerSide tests the asset's path on disk is kept for internal
+// caching but not sent outside of Kiosk
+func TestOriginalPathStaysServerSide(t *testing.T) {
+	asset := Asset{ID: "1", OriginalPath: "/mnt/photos/2024/IMG_0001.jpg", OriginalFileName: "IMG_0001.jpg"}
+
+	cached, err := json.Marshal(asset)
+	require.NoError(t, err)
+
+	var fromCache Asset
+	require.NoError(t, json.Unmarshal(cached, &fromCache))
+	assert.Equal(t, asset.OriginalPath, fromCache.OriginalPath)
+
+	outbound := asset.Outbound()
+	assert.Empty(t, outbound.OriginalPath)
+	assert.Equal(t, "IMG_0001.jpg", outbound.OriginalFileName)
+	assert.Equal(t, "/mnt/photos/2024/IMG_0001.jpg", asset.OriginalPath, "the asset itself is left untouched")
+
+	payload, err := json.Marshal(outbound)
+	require.NoError(t, err)
+	assert.NotContains(t, string(payload), "/mnt/photos")
+
+	offline, err := msgpack.Marshal(asset)
+	require.NoError(t, err)
+	assert.NotContains(t, string(offline), "/mnt/photos")
+}
+
+func TestChangeWatcher(t *testing.T) {
+	cache.Initialize()
+	t.Cleanup(cache.Flush)
+
+	albums := Albums{
+		{ID: "album-1", AssetCount: 1, UpdatedAt: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{ID: "album-2", AssetCount: 1, UpdatedAt: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	var updated []Asset
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body any
+
+		switch r.URL.Path {
+		case "/api/albums":
+			body = albums
+		case "/api/people":
+			body = AllPeopleResponse{People: []Person{{ID: "person-1", Name: "Alex"}}}
+		case "/api/search/metadata":
+			var response SearchMetadataResponse
+			response.Assets.Items = updated
+			body = response
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(body)
+	}))
+	t.Cleanup(server.Close)
+
+	base := config.Config{ImmichURL: server.URL, ImmichAPIKey: "key"}
+	watcher := NewChangeWatcher(context.Background(), base)
+
+	changes, err := watcher.Check("request")
+	require.NoError(t, err)
+	assert.True(t, changes.Empty(), "the first check records a baseline")
+
+	apiURLs := map[string]bool{
+		server.URL + "/api/albums/album-1":                   true,
+		server.URL + "/api/albums/album-2":                   false,
+		server.URL + "/api/albums?shared=true":               true,
+		server.URL + "/api/assets/asset-1":                   true,
+		server.URL + "/api/search/random?personIds=person-1": true,
+		server.URL + "/api/search/random?personIds=person-2": false,
+		server.URL + "/api/people?page=1":                    false,
+	}
+	for apiURL := range apiURLs {
+		cache.Set(cache.APICacheKey(apiURL, "device-1", ""), []byte("response"), 60, 0)
+	}
+
+	albums[0].AssetCount = 2
+	updated = []Asset{{ID: "asset-1", People: []Person{{ID: "person-1"}}}}
+
+	changes, err = watcher.Check("request")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"asset-1"}, changes.Assets)
+	assert.Equal(t, []string{"album-1"}, changes.Albums)
+	assert.Empty(t, changes.People)
+
+	for apiURL, affected := range apiURLs {
+		_, found := cache.Get(cache.APICacheKey(apiURL, "device-1", ""))
+		assert.Equal(t, affected, !found, apiURL)
+	}
+	assert.Equal(t, 4, changes.Flushed.Count)
+}
+
+// TestPutBack tests an asset picked but not shown goes back into the cached list it was
+// taken from
+func TestPutBack(t *testing.T) {
+	cache.Initialize()
+	t.Cleanup(cache.Flush)
+
+	requestConfig := config.Config{Duration: 60}
+
+	assetsKey := cache.APICacheKey("http://immich/api/search/random", "device-1", "")
+	assetsJSON, err := json.Marshal([]Asset{{ID: "asset-2"}})
+	require.NoError(t, err)
+	cache.Set(assetsKey, assetsJSON, 60, 0)
+
+	albumKey := cache.APICacheKey("http://immich/api/albums/album-1", "device-1", "")
+	albumJSON, err := json.Marshal(Album{ID: "album-1", Assets: []Asset{{ID: "asset-4"}}})
+	require.NoError(t, err)
+	cache.Set(albumKey, albumJSON, 60, 0)
+
+	fromAssets := Asset{ID: "asset-1", requestConfig: requestConfig, cacheKey: assetsKey}
+	require.NoError(t, fromAssets.PutBack())
+
+	fromAlbum := Asset{ID: "asset-3", requestConfig: requestConfig, cacheKey: albumKey}
+	require.NoError(t, fromAlbum.PutBack())
+
+	notCached := Asset{ID: "asset-5", requestConfig: requestConfig}
+	require.NoError(t, notCached.PutBack())
+
+	cached, found := cache.Get(assetsKey)
+	require.True(t, found)
+	var assets []Asset
+	require.NoError(t, json.Unmarshal(cached.([]byte), &assets))
+	assert.Equal(t, []string{"asset-2", "asset-1"}, []string{assets[0].ID, assets[1].ID})
+
+	cached, found = cache.Get(albumKey)
+	require.True(t, found)
+	var album Album
+	require.NoError(t, json.Unmarshal(cached.([]byte), &album))
+	assert.Equal(t, "album-1", album.ID)
+	require.Len(t, album.Assets, 2)
+	assert.Equal(t, "asset-3", album.Assets[1].ID)
+
+	cache.Delete(assetsKey)
+	require.NoError(t, fromAssets.PutBack(), "an expired list is not recreated")
+	_, found = cache.Get(assetsKey)
+	assert.False(t, found)
+}
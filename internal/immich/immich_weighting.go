@@ -0,0 +1,55 @@
+package immich
+
+import (
+	"time"
+
+	"charm.land/log/v2"
+
+	"github.com/damongolding/immich-kiosk/internal/seen"
+	"github.com/damongolding/immich-kiosk/internal/weighting"
+)
+
+// weightingStrategy returns the weighting strategy for the current request,
+// or nil if no strategy is configured.
+func (a *Asset) weightingStrategy() weighting.Strategy {
+	strategy, err := weighting.New(a.requestConfig.WeightingStrategy, weighting.Options{
+		HalfLife:       time.Duration(a.requestConfig.WeightingHalfLife) * 24 * time.Hour,
+		FavouriteBoost: a.requestConfig.WeightingFavouriteBoost,
+	})
+	if err != nil {
+		log.Error("weighting strategy", "err", err)
+		return nil
+	}
+
+	return strategy
+}
+
+// applyWeighting reorders assets so those favoured by the request's weighting
+// strategy tend to come first. Assets are returned unchanged if no strategy is set.
+func (a *Asset) applyWeighting(assets []Asset, deviceID string) []Asset {
+	strategy := a.weightingStrategy()
+	if strategy == nil || len(assets) < 2 {
+		return assets
+	}
+
+	seenKey := seen.Key(deviceID, a.requestConfig.NoRepeatGroup)
+
+	candidates := make([]weighting.Candidate, len(assets))
+	for i, asset := range assets {
+		taken, _ := assetTakenAt(asset)
+		candidates[i] = weighting.Candidate{
+			ID:        asset.ID,
+			TakenAt:   taken,
+			Rating:    int(asset.ExifInfo.Rating),
+			Favourite: asset.IsFavorite,
+			LastShown: seen.LastShown(seenKey, asset.ID),
+		}
+	}
+
+	weighted := make([]Asset, len(assets))
+	for i, index := range weighting.Order(strategy, candidates, time.Now(), nil) {
+		weighted[i] = assets[index]
+	}
+
+	return weighted
+}
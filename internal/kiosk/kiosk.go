@@ -27,11 +27,41 @@ const (
 	SourceTag       Source = "TAG"
 	SourceRating    Source = "RATING"
 	SourceMemories  Source = "MEMORIES"
+	SourceJourney   Source = "JOURNEY"
 
 	LayoutLandscape          string = "landscape"
 	LayoutPortrait           string = "portrait"
 	LayoutSplitview          string = "splitview"
 	LayoutSplitviewLandscape string = "splitview-landscape"
+	LayoutGrid2x2            string = "grid-2x2"
+	LayoutTriptych           string = "triptych"
+	LayoutMosaic             string = "mosaic"
+
+	PairingSameDay       string = "same-day"
+	PairingSameEvent     string = "same-event"
+	PairingSamePerson    string = "same-person"
+	PairingThenAndNow    string = "then-and-now"
+	PairingSimilarColour string = "similar-colour"
+	PairingSameLocation  string = "same-location"
+
+	BackgroundStyleBlur      string = "blur"
+	BackgroundStyleThumbhash string = "thumbhash"
+	BackgroundStyleColour    string = "colour"
+	BackgroundStyleGradient  string = "gradient"
+	BackgroundStyleMirror    string = "mirror"
+
+	FacePrivacyOff      string = "off"
+	FacePrivacyBlur     string = "blur"
+	FacePrivacyPixelate string = "pixelate"
+
+	ImageFormatAuto string = "auto"
+	ImageFormatJpeg string = "jpeg"
+	ImageFormatWebp string = "webp"
+	ImageFormatAvif string = "avif"
+
+	CacheBackendMemory string = "memory"
+	CacheBackendLRU    string = "lru"
+	CacheBackendDisk   string = "disk"
 
 	PortraitOrientation  string = LayoutPortrait
 	LandscapeOrientation string = LayoutLandscape
@@ -65,19 +95,73 @@ const (
 	MimeTypePng  string = "image/png"
 	MimeTypeGif  string = "image/gif"
 	MimeTypeWebp string = "image/webp"
-	MimeTypeAvif string = "image/avif" // currently unsupported
-	MimeTypeBmp  string = "image/bmp"  // currently unsupported
+	MimeTypeAvif string = "image/avif"
+	MimeTypeBmp  string = "image/bmp"
+	MimeTypeHeic string = "image/heic"
+	MimeTypeHeif string = "image/heif"
 
 	StatusStopHTMXPolling = 286
 )
 
 var (
+	// SupportedImageMimeTypes originals Kiosk can decode
 	SupportedImageMimeTypes = []string{
 		MimeTypeJpeg,
 		MimeTypeJpg,
 		MimeTypePng,
 		MimeTypeGif,
 		MimeTypeWebp,
+		MimeTypeAvif,
+		MimeTypeBmp,
+		MimeTypeHeic,
+		MimeTypeHeif,
+	}
+
+	// BrowserImageMimeTypes originals browsers can display without being converted
+	BrowserImageMimeTypes = []string{
+		MimeTypeJpeg,
+		MimeTypeJpg,
+		MimeTypePng,
+		MimeTypeGif,
+		MimeTypeWebp,
+		MimeTypeAvif,
+		MimeTypeBmp,
+	}
+
+	ImageFormats = []string{
+		ImageFormatAuto,
+		ImageFormatJpeg,
+		ImageFormatWebp,
+		ImageFormatAvif,
+	}
+
+	CacheBackends = []string{
+		CacheBackendMemory,
+		CacheBackendLRU,
+		CacheBackendDisk,
+	}
+
+	BackgroundStyles = []string{
+		BackgroundStyleBlur,
+		BackgroundStyleThumbhash,
+		BackgroundStyleColour,
+		BackgroundStyleGradient,
+		BackgroundStyleMirror,
+	}
+
+	FacePrivacyModes = []string{
+		FacePrivacyOff,
+		FacePrivacyBlur,
+		FacePrivacyPixelate,
+	}
+
+	SplitViewPairings = []string{
+		PairingSameDay,
+		PairingSameEvent,
+		PairingSamePerson,
+		PairingThenAndNow,
+		PairingSimilarColour,
+		PairingSameLocation,
 	}
 
 	DebugID = lipgloss.NewStyle().Bold(true).Padding(0, 1).Foreground(lipgloss.Color("#000000")).Background(lipgloss.Color("#1ed2bb")).Render("KIOSK")
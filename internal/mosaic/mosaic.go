@@ -0,0 +1,129 @@
+// Package mosaic packs assets of mixed aspect ratios into a viewport.
+//
+// Assets are laid out in justified rows: every row spans the full width and each
+// asset's width within a row is proportional to its aspect ratio. The number of rows
+// is chosen so the packed rows are as close as possible to the viewport's height,
+// keeping the cropping needed to fill each tile to a minimum.
+package mosaic
+
+import (
+	"math"
+)
+
+// Tile is the position and size of an asset within the viewport, as percentages.
+type Tile struct {
+	X      float64
+	Y      float64
+	Width  float64
+	Height float64
+}
+
+// rows splits ratios, in order, into count rows with similar total aspect ratios.
+// It returns the index each row starts at.
+func rows(ratios []float64, count int) []int {
+	total := 0.0
+	for _, r := range ratios {
+		total += r
+	}
+
+	target := total / float64(count)
+	starts := []int{0}
+	sum := 0.0
+
+	for i, r := range ratios {
+		remainingRows := count - len(starts)
+		remainingAssets := len(ratios) - i
+
+		// start a new row once this one is full, or when every remaining asset is needed for its own row
+		if i > starts[len(starts)-1] && remainingRows > 0 && (sum+r/2 > target || remainingAssets <= remainingRows) {
+			starts = append(starts, i)
+			sum = 0
+		}
+
+		sum += r
+	}
+
+	return starts
+}
+
+// Pack returns a tile for each aspect ratio (width / height) that together fill a
+// viewport of width by height. Assets keep their order, reading left to right and top
+// to bottom.
+func Pack(ratios []float64, width, height int) []Tile {
+	if len(ratios) == 0 || width <= 0 || height <= 0 {
+		return nil
+	}
+
+	clean := make([]float64, len(ratios))
+	for i, r := range ratios {
+		if r <= 0 || math.IsNaN(r) || math.IsInf(r, 0) {
+			r = 1
+		}
+		clean[i] = r
+	}
+
+	viewportRatio := float64(width) / float64(height)
+
+	var best []int
+	bestScore := math.Inf(1)
+
+	for count := 1; count <= len(clean); count++ {
+		starts := rows(clean, count)
+
+		score := math.Abs(math.Log(packedHeight(clean, starts) * viewportRatio))
+		if score < bestScore {
+			best, bestScore = starts, score
+		}
+	}
+
+	packed := packedHeight(clean, best)
+
+	tiles := make([]Tile, 0, len(clean))
+	y := 0.0
+
+	for i, start := range best {
+		end := len(clean)
+		if i+1 < len(best) {
+			end = best[i+1]
+		}
+
+		sum := rowRatio(clean, best, i, start)
+		rowHeight := (1 / sum) / packed * 100
+		x := 0.0
+
+		for _, r := range clean[start:end] {
+			tileWidth := r / sum * 100
+			tiles = append(tiles, Tile{X: x, Y: y, Width: tileWidth, Height: rowHeight})
+			x += tileWidth
+		}
+
+		y += rowHeight
+	}
+
+	return tiles
+}
+
+// rowRatio returns the total aspect ratio of row i, which starts at start.
+func rowRatio(ratios []float64, starts []int, i, start int) float64 {
+	end := len(ratios)
+	if i+1 < len(starts) {
+		end = starts[i+1]
+	}
+
+	sum := 0.0
+	for _, r := range ratios[start:end] {
+		sum += r
+	}
+
+	return sum
+}
+
+// packedHeight returns the height of the packed rows relative to their width.
+func packedHeight(ratios []float64, starts []int) float64 {
+	height := 0.0
+	for i, start := range starts {
+		height += 1 / rowRatio(ratios, starts, i, start)
+	}
+
+	return height
+}
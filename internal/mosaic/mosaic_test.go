@@ -0,0 +1,60 @@
+package mosaic
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPackFillsViewport(t *testing.T) {
+	tests := []struct {
+		name   string
+		ratios []float64
+		width  int
+		height int
+	}{
+		{name: "mixed landscape screen", ratios: []float64{1.5, 0.67, 1.5, 1.33, 0.75}, width: 1920, height: 1080},
+		{name: "mixed portrait screen", ratios: []float64{1.5, 0.67, 1.5, 1.33}, width: 1080, height: 1920},
+		{name: "single asset", ratios: []float64{0.67}, width: 1920, height: 1080},
+		{name: "invalid ratios", ratios: []float64{0, -1, 1.5}, width: 800, height: 600},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tiles := Pack(tt.ratios, tt.width, tt.height)
+			assert.Len(t, tiles, len(tt.ratios))
+
+			area := 0.0
+			for _, tile := range tiles {
+				assert.GreaterOrEqual(t, tile.X, 0.0)
+				assert.GreaterOrEqual(t, tile.Y, 0.0)
+				assert.LessOrEqual(t, tile.X+tile.Width, 100.0001)
+				assert.LessOrEqual(t, tile.Y+tile.Height, 100.0001)
+				area += tile.Width * tile.Height
+			}
+
+			assert.InDelta(t, 100*100, area, 0.01, "tiles should cover the viewport")
+		})
+	}
+}
+
+func TestPackRows(t *testing.T) {
+	// four landscape assets on a landscape screen pack into two rows of two
+	tiles := Pack([]float64{1.5, 1.5, 1.5, 1.5}, 1600, 900)
+
+	assert.InDelta(t, 0, tiles[0].Y, 0.0001)
+	assert.InDelta(t, 0, tiles[1].Y, 0.0001)
+	assert.InDelta(t, 50, tiles[2].Y, 0.0001)
+	assert.InDelta(t, 50, tiles[3].X, 0.0001)
+
+	// portrait assets on a landscape screen share a single row
+	tiles = Pack([]float64{0.67, 0.67, 0.67}, 1920, 1080)
+	for _, tile := range tiles {
+		assert.InDelta(t, 100, tile.Height, 0.0001)
+	}
+}
+
+func TestPackEmpty(t *testing.T) {
+	assert.Nil(t, Pack(nil, 1920, 1080))
+	assert.Nil(t, Pack([]float64{1}, 0, 0))
+}
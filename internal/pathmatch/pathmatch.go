@@ -0,0 +1,164 @@
+// Package pathmatch matches asset filenames and paths against glob or regex patterns.
+//
+// Patterns prefixed with "re:" are regular expressions matched against the asset's
+// original path. Any other pattern is a case-insensitive glob:
+//   - "*" matches anything except "/", "**" matches anything and "?" matches one character
+//   - patterns without a "/" must match the whole filename, e.g. "IMG-*-WA*"
+//   - patterns with a "/" may match any part of the path, e.g. "/Scans/"
+//
+// Compiled patterns are kept in a small least recently used cache, so the patterns in
+// use are only compiled once however many requests use them.
+package pathmatch
+
+import (
+	"container/list"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// regexPrefix marks a pattern as a regular expression
+const regexPrefix = "re:"
+
+// maxCompiled the most compiled patterns kept. Patterns can come from requests, so the
+// cache is bounded and the least recently used patterns are dropped first.
+const maxCompiled = 256
+
+// compiledPattern a compiled pattern held in the cache.
+type compiledPattern struct {
+	pattern string
+	re      *regexp.Regexp
+}
+
+var (
+	compiledMu sync.Mutex
+	compiled   = map[string]*list.Element{}
+	recent     = list.New()
+)
+
+// Compile returns the compiled form of pattern, compiling it on first use.
+func Compile(pattern string) (*regexp.Regexp, error) {
+	if re, ok := cached(pattern); ok {
+		return re, nil
+	}
+
+	var expr string
+
+	if after, ok := strings.CutPrefix(pattern, regexPrefix); ok {
+		expr = after
+	} else {
+		expr = globToRegex(pattern)
+	}
+
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+
+	store(pattern, re)
+
+	return re, nil
+}
+
+// cached returns the cached compiled form of pattern, marking it as recently used.
+func cached(pattern string) (*regexp.Regexp, bool) {
+	compiledMu.Lock()
+	defer compiledMu.Unlock()
+
+	el, found := compiled[pattern]
+	if !found {
+		return nil, false
+	}
+
+	recent.MoveToFront(el)
+
+	return el.Value.(*compiledPattern).re, true
+}
+
+// store caches the compiled form of pattern, dropping the least recently used
+// patterns once maxCompiled is reached.
+func store(pattern string, re *regexp.Regexp) {
+	compiledMu.Lock()
+	defer compiledMu.Unlock()
+
+	if el, found := compiled[pattern]; found {
+		recent.MoveToFront(el)
+		return
+	}
+
+	compiled[pattern] = recent.PushFront(&compiledPattern{pattern: pattern, re: re})
+
+	for recent.Len() > maxCompiled {
+		oldest := recent.Back()
+		recent.Remove(oldest)
+		delete(compiled, oldest.Value.(*compiledPattern).pattern)
+	}
+}
+
+// globToRegex converts a glob pattern into an equivalent regular expression.
+func globToRegex(glob string) string {
+	var b strings.Builder
+
+	b.WriteString("(?i)")
+
+	// filename globs must match the whole name, path globs may match part of the path
+	isPath := strings.Contains(glob, "/")
+	if !isPath {
+		b.WriteString("^")
+	}
+
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				b.WriteString(".*")
+				i++
+				continue
+			}
+			b.WriteString("[^/]*")
+		case '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	if !isPath {
+		b.WriteString("$")
+	}
+
+	return b.String()
+}
+
+// Match reports whether the asset matches any of patterns. Filename patterns are
+// matched against filename and path patterns against originalPath. If originalPath
+// is unknown the filename is used in its place.
+func Match(patterns []string, filename, originalPath string) bool {
+	if originalPath == "" {
+		originalPath = filename
+	}
+
+	if filename == "" && originalPath != "" {
+		filename = path.Base(originalPath)
+	}
+
+	for _, pattern := range patterns {
+		re, err := Compile(pattern)
+		if err != nil {
+			continue
+		}
+
+		subject := filename
+		if strings.HasPrefix(pattern, regexPrefix) || strings.Contains(pattern, "/") {
+			subject = originalPath
+		}
+
+		if re.MatchString(subject) {
+			return true
+		}
+	}
+
+	return false
+}
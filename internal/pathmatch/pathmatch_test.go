@@ -0,0 +1,68 @@
+package pathmatch
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		pattern  string
+		filename string
+		path     string
+		want     bool
+	}{
+		{name: "Filename glob", pattern: "*_edited*", filename: "IMG_0001_edited.jpg", path: "/photos/IMG_0001_edited.jpg", want: true},
+		{name: "Filename glob no match", pattern: "*_edited*", filename: "IMG_0001.jpg", path: "/photos/IMG_0001.jpg", want: false},
+		{name: "WhatsApp glob", pattern: "IMG-*-WA*", filename: "IMG-20240101-WA0003.jpg", want: true},
+		{name: "Filename glob is anchored", pattern: "IMG-*-WA*", filename: "xIMG-20240101-WA0003.jpg", want: false},
+		{name: "Filename glob is case insensitive", pattern: "*.png", filename: "Screenshot.PNG", want: true},
+		{name: "Filename glob ignores directories", pattern: "Scans*", filename: "photo.jpg", path: "/Scans/photo.jpg", want: false},
+		{name: "Path glob", pattern: "/Scans/", filename: "photo.jpg", path: "/library/Scans/photo.jpg", want: true},
+		{name: "Path glob wildcard", pattern: "/20*/tmp/", filename: "a.jpg", path: "/library/2021/tmp/a.jpg", want: true},
+		{name: "Single star does not cross directories", pattern: "/library/*/a.jpg", filename: "a.jpg", path: "/library/2021/tmp/a.jpg", want: false},
+		{name: "Double star crosses directories", pattern: "/library/**/a.jpg", filename: "a.jpg", path: "/library/2021/tmp/a.jpg", want: true},
+		{name: "Regex", pattern: `re:/\d{4}/tmp/`, filename: "a.jpg", path: "/library/2021/tmp/a.jpg", want: true},
+		{name: "Unknown path uses filename", pattern: "/Scans/", filename: "photo.jpg", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Match([]string{tt.pattern}, tt.filename, tt.path))
+		})
+	}
+}
+
+func TestCompile(t *testing.T) {
+	first, err := Compile("*.jpg")
+	assert.NoError(t, err)
+
+	second, err := Compile("*.jpg")
+	assert.NoError(t, err)
+	assert.Same(t, first, second, "compiled patterns should be cached")
+
+	_, err = Compile("re:[")
+	assert.Error(t, err)
+}
+
+func TestCompileCacheIsBounded(t *testing.T) {
+	first, err := Compile("*.heic")
+	assert.NoError(t, err)
+
+	for i := range maxCompiled * 2 {
+		_, err := Compile(fmt.Sprintf("IMG_%d_*", i))
+		assert.NoError(t, err)
+	}
+
+	compiledMu.Lock()
+	assert.Len(t, compiled, maxCompiled)
+	assert.Equal(t, maxCompiled, recent.Len())
+	compiledMu.Unlock()
+
+	second, err := Compile("*.heic")
+	assert.NoError(t, err)
+	assert.NotSame(t, first, second, "least recently used patterns should be dropped")
+}
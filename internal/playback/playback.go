@@ -0,0 +1,344 @@
+// Package playback persists the sequential playback position of ordered buckets
+// (albums played in ascending/descending order and memories) per device.
+//
+// Positions are always tracked in memory. Once Initialize has been called they are
+// also written to disk, shortly after changing, so unlike the API cache which is keyed per day, a restart or
+// the midnight cache rollover does not restart an album from the beginning.
+package playback
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"charm.land/log/v2"
+)
+
+const (
+	// DefaultPath is the file positions are persisted to
+	DefaultPath = "./kiosk-data/playback-positions.json"
+
+	// maxEntryAge positions not updated within this window are pruned
+	maxEntryAge = 90 * 24 * time.Hour
+
+	// saveDelay batches changes into a single write
+	saveDelay = 30 * time.Second
+)
+
+// Position holds where a device is within an ordered bucket.
+type Position struct {
+	// LastAssetID the ID of the last asset served from the bucket
+	LastAssetID string `json:"lastAssetId,omitempty"`
+	// SeekAssetID if set, playback resumes from (and including) this asset
+	SeekAssetID string `json:"seekAssetId,omitempty"`
+	// SeekIndex if set, playback resumes from this index within the ordered bucket
+	SeekIndex *int `json:"seekIndex,omitempty"`
+	// Anchor a point in time the bucket is being read from, used by buckets that page by date
+	Anchor time.Time `json:"anchor,omitzero"`
+	// LastTaken when the last asset served from the bucket was taken, used by buckets that skip bursts
+	LastTaken time.Time `json:"lastTaken,omitzero"`
+	// Refresh signals the cached bucket should be discarded before applying the position
+	Refresh bool `json:"refresh,omitempty"`
+	// UpdatedAt when the position was last changed
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+var (
+	mu        sync.Mutex
+	positions = map[string]Position{}
+
+	path      = DefaultPath
+	persist   = false
+	saveTimer *time.Timer
+)
+
+// Initialize enables persisting positions to disk and loads any previously persisted
+// positions from filePath. An empty filePath uses DefaultPath.
+func Initialize(filePath string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if filePath != "" {
+		path = filePath
+	}
+
+	persist = true
+	positions = map[string]Position{}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("reading playback positions: %w", err)
+	}
+
+	if err = json.Unmarshal(data, &positions); err != nil {
+		positions = map[string]Position{}
+		return fmt.Errorf("parsing playback positions: %w", err)
+	}
+
+	return nil
+}
+
+// BucketID returns the ID positions are stored under for a bucket,
+// scoped to the selected user if one is set.
+func BucketID(bucketID, user string) string {
+	if user != "" {
+		return bucketID + "@" + user
+	}
+	return bucketID
+}
+
+// key builds the storage key for a device and bucket.
+func key(deviceID, bucketID string) string {
+	return deviceID + "|" + bucketID
+}
+
+// HasDevice reports whether any positions are stored for a device.
+func HasDevice(deviceID string) bool {
+	mu.Lock()
+	defer mu.Unlock()
+
+	prefix := key(deviceID, "")
+	for k := range positions {
+		if strings.HasPrefix(k, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Get returns the stored position for a device and bucket.
+func Get(deviceID, bucketID string) (Position, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	p, ok := positions[key(deviceID, bucketID)]
+	return p, ok
+}
+
+// Set records assetID as the last asset served to a device from a bucket.
+// Any pending seek or refresh is cleared.
+func Set(deviceID, bucketID, assetID string) {
+	SetAt(deviceID, bucketID, assetID, time.Time{})
+}
+
+// SetAt records assetID as the last asset served to a device from a bucket
+// along with the anchor the bucket is being read from.
+// Any pending seek or refresh is cleared.
+func SetAt(deviceID, bucketID, assetID string, anchor time.Time) {
+	SetTaken(deviceID, bucketID, assetID, anchor, time.Time{})
+}
+
+// SetTaken records assetID, taken at taken, as the last asset served to a device from a
+// bucket along with the anchor the bucket is being read from.
+// Any pending seek or refresh is cleared.
+func SetTaken(deviceID, bucketID, assetID string, anchor, taken time.Time) {
+	update(deviceID, bucketID, func(p *Position) bool {
+		if p.LastAssetID == assetID && p.Anchor.Equal(anchor) && p.LastTaken.Equal(taken) && p.SeekAssetID == "" && p.SeekIndex == nil && !p.Refresh {
+			return false
+		}
+		*p = Position{LastAssetID: assetID, Anchor: anchor, LastTaken: taken}
+		return true
+	})
+}
+
+// Page moves a device on to the next page of a bucket read by date, anchored on the
+// page's last asset, pageEndID, which is skipped. When the last asset served was taken
+// is kept. Any pending seek or refresh is cleared.
+func Page(deviceID, bucketID, pageEndID string, anchor time.Time) {
+	update(deviceID, bucketID, func(p *Position) bool {
+		if p.LastAssetID == pageEndID && p.Anchor.Equal(anchor) && p.SeekAssetID == "" && p.SeekIndex == nil && !p.Refresh {
+			return false
+		}
+		*p = Position{LastAssetID: pageEndID, Anchor: anchor, LastTaken: p.LastTaken}
+		return true
+	})
+}
+
+// Refreshed clears the refresh flag once the cached bucket has been discarded.
+func Refreshed(deviceID, bucketID string) {
+	update(deviceID, bucketID, func(p *Position) bool {
+		if !p.Refresh {
+			return false
+		}
+		p.Refresh = false
+		return true
+	})
+}
+
+// Seek moves a device's position within a bucket. If assetID is set playback resumes
+// from that asset, otherwise from index. The cached bucket is refreshed on next use.
+func Seek(deviceID, bucketID, assetID string, index int) {
+	update(deviceID, bucketID, func(p *Position) bool {
+		*p = Position{Refresh: true}
+		if assetID != "" {
+			p.SeekAssetID = assetID
+		} else {
+			p.SeekIndex = &index
+		}
+		return true
+	})
+}
+
+// Reset returns a device to the start of a bucket. If bucketID is empty all of
+// the device's positions are reset.
+func Reset(deviceID, bucketID string) int {
+	mu.Lock()
+	defer mu.Unlock()
+
+	count := 0
+	now := time.Now()
+
+	if bucketID != "" {
+		positions[key(deviceID, bucketID)] = Position{Refresh: true, UpdatedAt: now}
+		count++
+	} else {
+		prefix := key(deviceID, "")
+		for k := range positions {
+			if strings.HasPrefix(k, prefix) {
+				positions[k] = Position{Refresh: true, UpdatedAt: now}
+				count++
+			}
+		}
+	}
+
+	if count > 0 {
+		scheduleSave()
+	}
+
+	return count
+}
+
+// Flush writes any pending changes to disk immediately.
+func Flush() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if saveTimer != nil {
+		saveTimer.Stop()
+		saveTimer = nil
+	}
+
+	save()
+}
+
+// update applies fn to the stored position and schedules it to be persisted when fn
+// reports a change.
+func update(deviceID, bucketID string, fn func(p *Position) bool) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	k := key(deviceID, bucketID)
+	p := positions[k]
+
+	if !fn(&p) {
+		return
+	}
+
+	p.UpdatedAt = time.Now()
+	positions[k] = p
+
+	scheduleSave()
+}
+
+// scheduleSave queues a write to disk if one is not already pending. mu must be held.
+func scheduleSave() {
+	if !persist || saveTimer != nil {
+		return
+	}
+
+	saveTimer = time.AfterFunc(saveDelay, func() {
+		mu.Lock()
+		defer mu.Unlock()
+
+		saveTimer = nil
+		save()
+	})
+}
+
+// save prunes stale entries and, if persisting, writes positions to disk.
+// Callers must hold mu.
+func save() {
+	cutoff := time.Now().Add(-maxEntryAge)
+	for k, p := range positions {
+		if p.UpdatedAt.Before(cutoff) {
+			delete(positions, k)
+		}
+	}
+
+	if !persist {
+		return
+	}
+
+	data, err := json.Marshal(positions)
+	if err != nil {
+		log.Error("Failed to marshal playback positions", "err", err)
+		return
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		log.Error("Failed to create playback positions directory", "err", err)
+		return
+	}
+
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0o600); err != nil {
+		log.Error("Failed to write playback positions", "err", err)
+		return
+	}
+
+	if err = os.Rename(tmp, path); err != nil {
+		log.Error("Failed to save playback positions", "err", err)
+	}
+}
+
+// StartIndex returns the index playback should resume from within an ordered list
+// of asset IDs.
+//
+// A pending seek takes priority. Otherwise playback continues after the last served
+// asset, wrapping to the start once the end of the list has been reached.
+// If the position does not reference an asset in the list, 0 is returned.
+func StartIndex(p Position, ids []string) int {
+	if len(ids) == 0 {
+		return 0
+	}
+
+	if p.SeekAssetID != "" {
+		for i, id := range ids {
+			if id == p.SeekAssetID {
+				return i
+			}
+		}
+		return 0
+	}
+
+	if p.SeekIndex != nil {
+		if *p.SeekIndex < 0 || *p.SeekIndex >= len(ids) {
+			return 0
+		}
+		return *p.SeekIndex
+	}
+
+	if p.LastAssetID == "" {
+		return 0
+	}
+
+	for i, id := range ids {
+		if id == p.LastAssetID {
+			if i+1 >= len(ids) {
+				return 0
+			}
+			return i + 1
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,76 @@
+package playback
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStartIndex(t *testing.T) {
+	ids := []string{"a", "b", "c", "d"}
+	two := 2
+	outOfRange := 10
+
+	tests := []struct {
+		name     string
+		position Position
+		want     int
+	}{
+		{name: "No position", position: Position{}, want: 0},
+		{name: "Continue after last asset", position: Position{LastAssetID: "b"}, want: 2},
+		{name: "Wrap after final asset", position: Position{LastAssetID: "d"}, want: 0},
+		{name: "Unknown last asset", position: Position{LastAssetID: "z"}, want: 0},
+		{name: "Seek to asset", position: Position{LastAssetID: "a", SeekAssetID: "c"}, want: 2},
+		{name: "Seek to index", position: Position{SeekIndex: &two}, want: 2},
+		{name: "Seek index out of range", position: Position{SeekIndex: &outOfRange}, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, StartIndex(tt.position, ids))
+		})
+	}
+}
+
+func TestPersistence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "positions.json")
+
+	assert.NoError(t, Initialize(file))
+
+	Set("device", "album", "asset-1")
+	Seek("device", "other-album", "", 3)
+	Flush()
+
+	// reload from disk
+	assert.NoError(t, Initialize(file))
+
+	p, found := Get("device", "album")
+	assert.True(t, found)
+	assert.Equal(t, "asset-1", p.LastAssetID)
+
+	p, found = Get("device", "other-album")
+	assert.True(t, found)
+	assert.True(t, p.Refresh)
+	assert.Equal(t, 3, *p.SeekIndex)
+
+	assert.Equal(t, 2, Reset("device", ""))
+
+	p, _ = Get("device", "album")
+	assert.Empty(t, p.LastAssetID)
+	assert.True(t, p.Refresh)
+}
+
+func TestSavesAreBatched(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "positions.json")
+
+	assert.NoError(t, Initialize(file))
+
+	Set("batched-device", "album", "asset-1")
+	assert.NoFileExists(t, file, "positions should not be written on every change")
+	assert.True(t, HasDevice("batched-device"))
+	assert.False(t, HasDevice("batched"))
+
+	Flush()
+	assert.FileExists(t, file)
+}
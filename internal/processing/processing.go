@@ -0,0 +1,289 @@
+// Package processing runs CPU heavy image work, such as decoding, resizing, blurring and
+// encoding, on a bounded pool of workers.
+//
+// Work is queued by priority, so a device waiting on an image is served before prefetches
+// and background work. Once the queue is full, prefetch and background work is rejected
+// with ErrQueueFull rather than queued. How long each stage takes, and how long work
+// waits in the queue, is recorded and reported by GetStats.
+package processing
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Priority decides the order queued work is run in. Lower values run first.
+type Priority int
+
+const (
+	// Interactive work a device is waiting on
+	Interactive Priority = iota
+	// Prefetch work for assets that will be shown later
+	Prefetch
+	// Background work no device is waiting on, such as video previews
+	Background
+)
+
+// priorities every priority, in the order work is run
+var priorities = []Priority{Interactive, Prefetch, Background}
+
+// String returns the name a priority is reported under.
+func (p Priority) String() string {
+	switch p {
+	case Interactive:
+		return "interactive"
+	case Prefetch:
+		return "prefetch"
+	case Background:
+		return "background"
+	default:
+		return fmt.Sprintf("priority(%d)", int(p))
+	}
+}
+
+// PriorityFor returns Prefetch for prefetches and Interactive for everything else.
+func PriorityFor(isPrefetch bool) Priority {
+	if isPrefetch {
+		return Prefetch
+	}
+	return Interactive
+}
+
+// Stage names a kind of work, timings are recorded per stage.
+type Stage string
+
+const (
+	// StageDecode decoding an image and rotating it to its EXIF orientation
+	StageDecode Stage = "decode"
+	// StageOptimize resizing an image to the client
+	StageOptimize Stage = "optimize"
+	// StageBlur blurring and encoding an image's background
+	StageBlur Stage = "blur"
+	// StageEncode encoding an image to be sent to the client
+	StageEncode Stage = "encode"
+	// StageBackground making and encoding a background other than a blur, such as a mirror
+	StageBackground Stage = "background"
+	// StageEffects applying an image's effects, such as grayscale or vignette
+	StageEffects Stage = "effects"
+	// StageFacePrivacy hiding faces that are unnamed or not allowed
+	StageFacePrivacy Stage = "face-privacy"
+)
+
+// DefaultQueueSize how many jobs may wait for a worker before prefetch and background
+// work is rejected
+const DefaultQueueSize = 64
+
+// ErrQueueFull is returned for prefetch and background work when the queue is full.
+var ErrQueueFull = errors.New("image processing queue is full")
+
+// errPoolClosed is returned when work is submitted to a pool replaced by Initialize.
+var errPoolClosed = errors.New("image processing pool is closed")
+
+// job is queued work.
+type job struct {
+	run      func() error
+	priority Priority
+	stage    Stage
+	queuedAt time.Time
+	done     chan struct{}
+
+	// set once done is closed
+	err      error
+	panicked any
+}
+
+// pool runs queued jobs on a fixed number of workers.
+type pool struct {
+	mu        sync.Mutex
+	cond      *sync.Cond
+	queues    [3][]*job
+	queued    int
+	running   int
+	workers   int
+	queueSize int
+	closed    bool
+}
+
+var (
+	currentMu sync.RWMutex
+	current   = newPool(0, DefaultQueueSize)
+)
+
+// Initialize replaces the pool with one of workers workers and room for queueSize queued
+// jobs. A workers value of 0 or less uses one worker per CPU and a queueSize of 0 or less
+// uses DefaultQueueSize. Work already queued on the previous pool is still run.
+func Initialize(workers, queueSize int) {
+	p := newPool(workers, queueSize)
+
+	currentMu.Lock()
+	previous := current
+	current = p
+	currentMu.Unlock()
+
+	previous.close()
+}
+
+// Run runs fn on the pool and returns its result once it has run. fn is timed under
+// stage. Prefetch and background work returns ErrQueueFull, without running fn, if the
+// queue is full. A panic in fn is re-raised in the caller.
+func Run[T any](priority Priority, stage Stage, fn func() (T, error)) (T, error) {
+	var result T
+
+	run := func() error {
+		var runErr error
+		result, runErr = fn()
+		return runErr
+	}
+
+	j, err := currentPool().submit(priority, stage, run)
+	if errors.Is(err, errPoolClosed) {
+		// Initialize replaced the pool between looking it up and submitting
+		j, err = currentPool().submit(priority, stage, run)
+	}
+	if err != nil {
+		return result, err
+	}
+
+	<-j.done
+
+	if j.panicked != nil {
+		panic(j.panicked)
+	}
+
+	return result, j.err
+}
+
+// currentPool returns the pool work is submitted to.
+func currentPool() *pool {
+	currentMu.RLock()
+	defer currentMu.RUnlock()
+
+	return current
+}
+
+// newPool creates a pool and starts its workers.
+func newPool(workers, queueSize int) *pool {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	if queueSize <= 0 {
+		queueSize = DefaultQueueSize
+	}
+
+	p := &pool{
+		workers:   workers,
+		queueSize: queueSize,
+	}
+	p.cond = sync.NewCond(&p.mu)
+
+	for range workers {
+		go p.work()
+	}
+
+	return p
+}
+
+// submit queues fn, rejecting prefetch and background work if the queue is full.
+func (p *pool) submit(priority Priority, stage Stage, fn func() error) (*job, error) {
+	if priority < Interactive || priority > Background {
+		priority = Background
+	}
+
+	j := &job{
+		run:      fn,
+		priority: priority,
+		stage:    stage,
+		queuedAt: time.Now(),
+		done:     make(chan struct{}),
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return nil, errPoolClosed
+	}
+
+	if priority != Interactive && p.queued >= p.queueSize {
+		stats.reject(priority)
+		return nil, ErrQueueFull
+	}
+
+	p.queues[priority] = append(p.queues[priority], j)
+	p.queued++
+	p.cond.Signal()
+
+	return j, nil
+}
+
+// work runs queued jobs, highest priority first, until the pool is closed and its queue
+// is empty.
+func (p *pool) work() {
+	for {
+		p.mu.Lock()
+		for p.queued == 0 && !p.closed {
+			p.cond.Wait()
+		}
+
+		if p.queued == 0 {
+			p.mu.Unlock()
+			return
+		}
+
+		j := p.next()
+		p.running++
+		p.mu.Unlock()
+
+		p.runJob(j)
+
+		p.mu.Lock()
+		p.running--
+		p.mu.Unlock()
+	}
+}
+
+// next removes the highest priority job from the queue. Callers must hold mu and
+// there must be a queued job.
+func (p *pool) next() *job {
+	for _, priority := range priorities {
+		if queue := p.queues[priority]; len(queue) > 0 {
+			j := queue[0]
+			queue[0] = nil
+			p.queues[priority] = queue[1:]
+			p.queued--
+			return j
+		}
+	}
+
+	return nil
+}
+
+// runJob runs a job, recording how long it waited and ran.
+func (p *pool) runJob(j *job) {
+	startTime := time.Now()
+
+	defer func() {
+		if r := recover(); r != nil {
+			j.panicked = r
+			j.err = fmt.Errorf("panic: %v", r)
+		}
+
+		stats.record(j.priority, j.stage, startTime.Sub(j.queuedAt), time.Since(startTime), j.err)
+		close(j.done)
+	}()
+
+	j.err = j.run()
+}
+
+// close stops the workers once the queue is empty.
+func (p *pool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.closed = true
+	p.cond.Broadcast()
+}
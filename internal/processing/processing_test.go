@@ -0,0 +1,165 @@
+package processing
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// block occupies every worker of the current pool until the returned func is called.
+func block(t *testing.T, workers int) func() {
+	t.Helper()
+
+	release := make(chan struct{})
+	started := make(chan struct{}, workers)
+
+	for range workers {
+		go func() {
+			_, _ = Run(Interactive, StageDecode, func() (struct{}, error) {
+				started <- struct{}{}
+				<-release
+				return struct{}{}, nil
+			})
+		}()
+	}
+
+	for range workers {
+		<-started
+	}
+
+	return func() { close(release) }
+}
+
+// waitForQueued waits until n jobs are queued on the current pool.
+func waitForQueued(t *testing.T, n int) {
+	t.Helper()
+
+	require.Eventually(t, func() bool {
+		return GetStats().Queued == n
+	}, time.Second, time.Millisecond)
+}
+
+func TestRunPriorityOrder(t *testing.T) {
+	Initialize(1, 10)
+
+	release := block(t, 1)
+
+	var mu sync.Mutex
+	var order []Priority
+
+	var wg sync.WaitGroup
+	for i, priority := range []Priority{Background, Prefetch, Interactive} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := Run(priority, StageOptimize, func() (struct{}, error) {
+				mu.Lock()
+				order = append(order, priority)
+				mu.Unlock()
+				return struct{}{}, nil
+			})
+			assert.NoError(t, err)
+		}()
+
+		waitForQueued(t, i+1)
+	}
+
+	release()
+	wg.Wait()
+
+	assert.Equal(t, []Priority{Interactive, Prefetch, Background}, order)
+}
+
+func TestRunQueueFull(t *testing.T) {
+	Initialize(1, 1)
+	ResetStats()
+
+	release := block(t, 1)
+
+	queued := make(chan error, 1)
+	go func() {
+		_, err := Run(Prefetch, StageBlur, func() (int, error) { return 1, nil })
+		queued <- err
+	}()
+	waitForQueued(t, 1)
+
+	_, err := Run(Prefetch, StageBlur, func() (int, error) { return 2, nil })
+	require.ErrorIs(t, err, ErrQueueFull)
+
+	_, err = Run(Background, StageBlur, func() (int, error) { return 3, nil })
+	require.ErrorIs(t, err, ErrQueueFull)
+
+	// interactive work is never rejected
+	interactive := make(chan int, 1)
+	go func() {
+		result, _ := Run(Interactive, StageBlur, func() (int, error) { return 4, nil })
+		interactive <- result
+	}()
+	waitForQueued(t, 2)
+
+	release()
+
+	require.NoError(t, <-queued)
+	assert.Equal(t, 4, <-interactive)
+
+	stats := GetStats()
+	assert.Equal(t, uint64(1), stats.Priorities["prefetch"].Rejected)
+	assert.Equal(t, uint64(1), stats.Priorities["background"].Rejected)
+	assert.Equal(t, uint64(1), stats.Priorities["prefetch"].Completed)
+}
+
+func TestRunResultsAndPanics(t *testing.T) {
+	Initialize(2, 4)
+
+	result, err := Run(Interactive, StageEncode, func() (string, error) { return "encoded", nil })
+	require.NoError(t, err)
+	assert.Equal(t, "encoded", result)
+
+	failed := errors.New("failed")
+	_, err = Run(Interactive, StageEncode, func() (string, error) { return "", failed })
+	require.ErrorIs(t, err, failed)
+
+	assert.PanicsWithValue(t, "boom", func() {
+		_, _ = Run(Interactive, StageEncode, func() (string, error) { panic("boom") })
+	})
+
+	// the pool keeps working after a panic
+	result, err = Run(Interactive, StageEncode, func() (string, error) { return "again", nil })
+	require.NoError(t, err)
+	assert.Equal(t, "again", result)
+}
+
+func TestStats(t *testing.T) {
+	Initialize(3, 16)
+	ResetStats()
+
+	for range 4 {
+		_, _ = Run(Interactive, StageDecode, func() (int, error) {
+			time.Sleep(time.Millisecond)
+			return 0, nil
+		})
+	}
+
+	_, _ = Run(Prefetch, StageOptimize, func() (int, error) { return 0, errors.New("failed") })
+
+	stats := GetStats()
+	assert.Equal(t, 3, stats.Workers)
+	assert.Equal(t, 16, stats.QueueSize)
+
+	decode := stats.Stages[StageDecode]
+	assert.Equal(t, uint64(4), decode.Count)
+	assert.Zero(t, decode.Errors)
+	assert.GreaterOrEqual(t, decode.AverageMs, 1.0)
+	assert.GreaterOrEqual(t, decode.MaxMs, decode.AverageMs)
+
+	assert.Equal(t, uint64(1), stats.Stages[StageOptimize].Errors)
+	assert.Equal(t, uint64(4), stats.Priorities["interactive"].Completed)
+	assert.Equal(t, uint64(1), stats.Priorities["prefetch"].Completed)
+
+	ResetStats()
+	assert.Empty(t, GetStats().Stages)
+}
@@ -0,0 +1,181 @@
+package processing
+
+import (
+	"sync"
+	"time"
+)
+
+// StageStats how long a stage's work took since Stats.Since.
+type StageStats struct {
+	Count         uint64  `json:"count"`
+	Errors        uint64  `json:"errors"`
+	AverageMs     float64 `json:"averageMs"`
+	MaxMs         float64 `json:"maxMs"`
+	AverageWaitMs float64 `json:"averageWaitMs"`
+	MaxWaitMs     float64 `json:"maxWaitMs"`
+}
+
+// PriorityStats how a priority's work was queued since Stats.Since.
+type PriorityStats struct {
+	Queued        int     `json:"queued"`
+	Completed     uint64  `json:"completed"`
+	Rejected      uint64  `json:"rejected"`
+	AverageWaitMs float64 `json:"averageWaitMs"`
+}
+
+// Stats the pool's size and load, and how work has been run since Since.
+type Stats struct {
+	Workers    int                      `json:"workers"`
+	QueueSize  int                      `json:"queueSize"`
+	Running    int                      `json:"running"`
+	Queued     int                      `json:"queued"`
+	Since      time.Time                `json:"since"`
+	Stages     map[Stage]StageStats     `json:"stages"`
+	Priorities map[string]PriorityStats `json:"priorities"`
+}
+
+// timings running totals for a stage or priority.
+type timings struct {
+	count   uint64
+	errors  uint64
+	run     time.Duration
+	maxRun  time.Duration
+	wait    time.Duration
+	maxWait time.Duration
+}
+
+// recorder collects timings for completed work.
+type recorder struct {
+	mu         sync.Mutex
+	since      time.Time
+	stages     map[Stage]*timings
+	priorities map[Priority]*timings
+	rejected   map[Priority]uint64
+}
+
+var stats = newRecorder()
+
+// newRecorder creates an empty recorder.
+func newRecorder() *recorder {
+	return &recorder{
+		since:      time.Now(),
+		stages:     map[Stage]*timings{},
+		priorities: map[Priority]*timings{},
+		rejected:   map[Priority]uint64{},
+	}
+}
+
+// record adds a completed job's timings.
+func (r *recorder) record(priority Priority, stage Stage, wait, run time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, t := range []*timings{timingsFor(r.stages, stage), timingsFor(r.priorities, priority)} {
+		t.count++
+		t.run += run
+		t.wait += wait
+		t.maxRun = max(t.maxRun, run)
+		t.maxWait = max(t.maxWait, wait)
+
+		if err != nil {
+			t.errors++
+		}
+	}
+}
+
+// reject counts work turned away because the queue was full.
+func (r *recorder) reject(priority Priority) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.rejected[priority]++
+}
+
+// GetStats returns the pool's current load and the timings recorded since the last reset.
+func GetStats() Stats {
+	p := currentPool()
+
+	p.mu.Lock()
+	result := Stats{
+		Workers:    p.workers,
+		QueueSize:  p.queueSize,
+		Running:    p.running,
+		Queued:     p.queued,
+		Stages:     map[Stage]StageStats{},
+		Priorities: make(map[string]PriorityStats, len(priorities)),
+	}
+
+	queued := make(map[Priority]int, len(priorities))
+	for _, priority := range priorities {
+		queued[priority] = len(p.queues[priority])
+	}
+	p.mu.Unlock()
+
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+
+	result.Since = stats.since
+
+	for stage, t := range stats.stages {
+		result.Stages[stage] = StageStats{
+			Count:         t.count,
+			Errors:        t.errors,
+			AverageMs:     averageMs(t.run, t.count),
+			MaxMs:         milliseconds(t.maxRun),
+			AverageWaitMs: averageMs(t.wait, t.count),
+			MaxWaitMs:     milliseconds(t.maxWait),
+		}
+	}
+
+	for _, priority := range priorities {
+		priorityStats := PriorityStats{
+			Queued:   queued[priority],
+			Rejected: stats.rejected[priority],
+		}
+
+		if t, found := stats.priorities[priority]; found {
+			priorityStats.Completed = t.count
+			priorityStats.AverageWaitMs = averageMs(t.wait, t.count)
+		}
+
+		result.Priorities[priority.String()] = priorityStats
+	}
+
+	return result
+}
+
+// ResetStats clears the recorded timings.
+func ResetStats() {
+	fresh := newRecorder()
+
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+
+	stats.since = fresh.since
+	stats.stages = fresh.stages
+	stats.priorities = fresh.priorities
+	stats.rejected = fresh.rejected
+}
+
+// timingsFor returns the timings for key, adding them if missing.
+func timingsFor[K comparable](all map[K]*timings, key K) *timings {
+	t, found := all[key]
+	if !found {
+		t = &timings{}
+		all[key] = t
+	}
+	return t
+}
+
+// averageMs total divided by count, in milliseconds.
+func averageMs(total time.Duration, count uint64) float64 {
+	if count == 0 {
+		return 0
+	}
+	return milliseconds(total) / float64(count)
+}
+
+// milliseconds d in fractional milliseconds.
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/damongolding/immich-kiosk/internal/i18n"
 	"github.com/damongolding/immich-kiosk/internal/immich"
 	"github.com/damongolding/immich-kiosk/internal/kiosk"
+	"github.com/damongolding/immich-kiosk/internal/processing"
 	imageComponent "github.com/damongolding/immich-kiosk/internal/templates/components/image"
 	videoComponent "github.com/damongolding/immich-kiosk/internal/templates/components/video"
 	"github.com/damongolding/immich-kiosk/internal/templates/partials"
@@ -41,6 +42,8 @@ func NewAsset(baseConfig *config.Config, com *common.Common) echo.HandlerFunc {
 		requestID := requestData.RequestID
 		deviceID := requestData.DeviceID
 
+		rememberDevice(c, baseConfig, deviceID)
+
 		log.Debug(
 			requestID,
 			"method", c.Request().Method,
@@ -63,6 +66,7 @@ func NewAsset(baseConfig *config.Config, com *common.Common) echo.HandlerFunc {
 		// get and use prefetch data (if found)
 		if requestConfig.Kiosk.PreFetch {
 			if cachedViewData := fromCache(requestCtx.URL.String(), deviceID); cachedViewData != nil {
+				serveStory(cachedViewData[0], deviceID)
 				go assetPreFetch(com, requestData, requestCtx)
 				go webhooks.Trigger(com.Context(), requestData, KioskVersion, webhooks.NewAsset, cachedViewData[0])
 
@@ -71,7 +75,7 @@ func NewAsset(baseConfig *config.Config, com *common.Common) echo.HandlerFunc {
 			log.Debug(requestID, "deviceID", deviceID, "cache miss for new image")
 		}
 
-		viewData, err := generateViewData(requestConfig, requestCtx, requestID, deviceID, false)
+		viewData, err := generateViewData(com.Context(), requestConfig, requestCtx, requestID, deviceID, false)
 		if err != nil {
 			t := i18n.T()
 			return RenderError(c, err, t("retrieving_asset"), requestConfig.Duration)
@@ -137,6 +141,11 @@ func Image(baseConfig *config.Config, com *common.Common) echo.HandlerFunc {
 			return err
 		}
 
+		img, err = hideFaces(img, &immichAsset, requestConfig, requestID, fakeDeviceID, processing.Interactive)
+		if err != nil {
+			return err
+		}
+
 		// Optimize image if wanted
 		if requestConfig.OptimizeImages && requestConfig.ClientData.Width > 0 && requestConfig.ClientData.Height > 0 {
 			img, err = utils.OptimizeImage(img, requestConfig.ClientData.Width, requestConfig.ClientData.Height)
@@ -183,6 +192,7 @@ func ImageWithReload(baseConfig *config.Config) echo.HandlerFunc {
 }
 
 // ImageWithID handles HTTP requests to retrieve an image preview by its image ID and returns the image as a blob with the correct MIME type.
+// Images browsers cannot display are converted to JPEG.
 // Returns HTTP 400 if the image ID is missing or if the image cannot be retrieved.
 func ImageWithID(baseConfig *config.Config, com *common.Common) echo.HandlerFunc {
 	return func(c *echo.Context) error {
@@ -223,6 +233,27 @@ func ImageWithID(baseConfig *config.Config, com *common.Common) echo.HandlerFunc
 
 		imageMime := utils.ImageMimeType(bytes.NewReader(imgBytes))
 
+		// originals browsers cannot display, such as HEIC, are converted to JPEG, as are
+		// images with faces to hide
+		if !slices.Contains(kiosk.BrowserImageMimeTypes, imageMime) || requestConfig.FacePrivacyKey() != "" {
+			img, _, decodeErr := decodeImage(&immichAsset, imgBytes, requestConfig.UseOriginalImage, requestID, processing.Interactive)
+			if decodeErr != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, "unable to decode image")
+			}
+
+			img, decodeErr = hideFaces(img, &immichAsset, requestConfig, requestID, "", processing.Interactive)
+			if decodeErr != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "unable to hide faces")
+			}
+
+			imgBytes, err = utils.ImageToBytes(img)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "unable to convert image")
+			}
+
+			imageMime = kiosk.MimeTypeJpeg
+		}
+
 		return c.Blob(http.StatusOK, imageMime, imgBytes)
 	}
 }
@@ -15,10 +15,16 @@ import (
 	"github.com/damongolding/immich-kiosk/internal/cache"
 	"github.com/damongolding/immich-kiosk/internal/common"
 	"github.com/damongolding/immich-kiosk/internal/config"
+	"github.com/damongolding/immich-kiosk/internal/diskcache"
+	"github.com/damongolding/immich-kiosk/internal/effects"
 	"github.com/damongolding/immich-kiosk/internal/immich"
 	"github.com/damongolding/immich-kiosk/internal/kiosk"
+	"github.com/damongolding/immich-kiosk/internal/processing"
+	"github.com/damongolding/immich-kiosk/internal/seen"
+	"github.com/damongolding/immich-kiosk/internal/story"
 	imageComponent "github.com/damongolding/immich-kiosk/internal/templates/components/image"
 	videoComponent "github.com/damongolding/immich-kiosk/internal/templates/components/video"
+	"github.com/damongolding/immich-kiosk/internal/thumbhash"
 	"github.com/damongolding/immich-kiosk/internal/utils"
 	"github.com/damongolding/immich-kiosk/internal/webhooks"
 	"github.com/fogleman/gg"
@@ -105,6 +111,11 @@ func gatherAssetBuckets(immichAsset *immich.Asset, requestConfig config.Config,
 		}
 	}
 
+	// Journey bucket
+	if requestConfig.Journey {
+		gatherJourney(&d)
+	}
+
 	// Memories bucket
 	if requestConfig.Memories {
 		getMemoriesAssetsCount(immichAsset, requestConfig, requestID, deviceID, &assets)
@@ -138,14 +149,31 @@ func gatherPeopleAlbums(d *gatherData, cfg gatherPeopleAlbumsConfig) error {
 			continue
 		}
 
-		*d.assets = append(*d.assets, utils.AssetWithWeighting{
+		*d.assets = append(*d.assets, withBucketWeight(d.requestConfig, item, utils.AssetWithWeighting{
 			Asset:  utils.WeightedAsset{Type: cfg.sourceType, ID: item},
 			Weight: assetCount,
-		})
+		}))
 	}
 	return nil
 }
 
+// withBucketWeight applies any custom multiplier or share configured for the bucket
+// identified by configID (the ID as it appears in the config, or the tag's name).
+func withBucketWeight(requestConfig config.Config, configID string, bucket utils.AssetWithWeighting) utils.AssetWithWeighting {
+	weight, ok := requestConfig.BucketWeight(bucket.Asset.Type, configID)
+	if !ok {
+		return bucket
+	}
+
+	if weight.Multiplier > 0 {
+		bucket.Penalty = weight.Multiplier
+	}
+
+	bucket.Share = weight.Share
+
+	return bucket
+}
+
 func gatherPeople(d *gatherData) error {
 	return gatherPeopleAlbums(d, gatherPeopleAlbumsConfig{
 		sourceType:    kiosk.SourcePerson,
@@ -211,10 +239,10 @@ func gatherTags(d *gatherData) error {
 			continue
 		}
 
-		*d.assets = append(*d.assets, utils.AssetWithWeighting{
+		*d.assets = append(*d.assets, withBucketWeight(d.requestConfig, tagData.Value, utils.AssetWithWeighting{
 			Asset:  utils.WeightedAsset{Type: kiosk.SourceTag, ID: tagData.ID},
 			Weight: taggedAssetsCount,
-		})
+		}))
 	}
 
 	return nil
@@ -226,6 +254,8 @@ func gatherDates(d *gatherData) {
 			continue
 		}
 
+		configDate := date
+
 		if strings.Contains(date, "@") {
 			log.Warn("Dates with multi user information are not currently supported")
 			date, _, _ = strings.Cut(date, "@")
@@ -237,13 +267,24 @@ func gatherDates(d *gatherData) {
 		}
 
 		// use FetchedAssetsSize as a weighting for date ranges
-		*d.assets = append(*d.assets, utils.AssetWithWeighting{
+		*d.assets = append(*d.assets, withBucketWeight(d.requestConfig, configDate, utils.AssetWithWeighting{
 			Asset:  utils.WeightedAsset{Type: kiosk.SourceDateRange, ID: date},
 			Weight: dateWeight,
-		})
+		}))
 	}
 }
 
+// gatherJourney adds the journey bucket. The journey walks the library so it is weighted
+// by the library's asset count, scaled by JourneyWeight, or given a fixed JourneyShare.
+func gatherJourney(d *gatherData) {
+	*d.assets = append(*d.assets, utils.AssetWithWeighting{
+		Asset:   utils.WeightedAsset{Type: kiosk.SourceJourney, ID: immich.JourneyBucketID(d.requestConfig)},
+		Weight:  max(d.immichAsset.TotalAssetCount(), 1),
+		Penalty: d.requestConfig.JourneyWeight,
+		Share:   d.requestConfig.JourneyShare,
+	})
+}
+
 func gatherRatedAssets(d *gatherData) error {
 	wantedRating := d.requestConfig.Rating
 
@@ -289,6 +330,7 @@ func getMemoriesAssetsCount(immichAsset *immich.Asset, requestConfig config.Conf
 			Asset:   utils.WeightedAsset{Type: kiosk.SourceMemories, ID: string(kiosk.SourceMemories)},
 			Weight:  memories,
 			Penalty: requestConfig.MemoryWeight,
+			Share:   requestConfig.MemoryShare,
 		})
 	}
 }
@@ -358,7 +400,17 @@ func retrieveImage(immichAsset *immich.Asset, pickedAsset utils.WeightedAsset, a
 		return immichAsset.RandomAssetOfPerson(pickedAsset.ID, requestID, deviceID, isPrefetch)
 
 	case kiosk.SourceMemories:
-		return immichAsset.RandomMemoryAsset(requestID, deviceID)
+		switch strings.ToLower(albumOrder) {
+		case config.AlbumOrderDescending, config.AlbumOrderDesc, config.AlbumOrderNewest:
+			return immichAsset.OrderedMemoryAsset(immich.Desc, requestID, deviceID)
+		case config.AlbumOrderAscending, config.AlbumOrderAsc, config.AlbumOrderOldest:
+			return immichAsset.OrderedMemoryAsset(immich.Asc, requestID, deviceID)
+		default:
+			return immichAsset.RandomMemoryAsset(requestID, deviceID)
+		}
+
+	case kiosk.SourceJourney:
+		return immichAsset.JourneyAsset(pickedAsset.ID, requestID, deviceID)
 
 	case kiosk.SourceTag:
 		return immichAsset.RandomAssetWithTag(pickedAsset.ID, requestID, deviceID, isPrefetch)
@@ -384,7 +436,7 @@ func fetchImagePreview(immichAsset *immich.Asset, isOriginal bool, requestID, de
 		return nil, fmt.Errorf("getting image preview: %w", err)
 	}
 
-	img, _, err := utils.BytesToImage(imgBytes, isOriginal)
+	img, _, err := decodeImage(immichAsset, imgBytes, isOriginal, requestID, processing.PriorityFor(isPrefetch))
 	if err != nil {
 		return nil, err
 	}
@@ -398,6 +450,52 @@ func fetchImagePreview(immichAsset *immich.Asset, isOriginal bool, requestID, de
 	return img, nil
 }
 
+// decodeImage decodes an asset's image bytes on the processing pool. When an original
+// cannot be decoded, Immich's preview of the asset is fetched and decoded instead.
+// It returns the image, its MIME type and an error if neither could be decoded.
+func decodeImage(immichAsset *immich.Asset, imgBytes []byte, isOriginal bool, requestID string, priority processing.Priority) (image.Image, string, error) {
+	img, mimeType, err := bytesToImage(imgBytes, isOriginal, priority)
+	if err == nil || !isOriginal || errors.Is(err, processing.ErrQueueFull) {
+		return img, mimeType, err
+	}
+
+	log.Warn(requestID+" Could not decode original image, falling back to preview", "assetID", immichAsset.ID, "mimeType", immichAsset.OriginalMimeType, "err", err)
+
+	previewBytes, _, previewErr := immichAsset.PreviewImage()
+	if previewErr != nil {
+		return nil, "", fmt.Errorf("getting image preview: %w", previewErr)
+	}
+
+	return bytesToImage(previewBytes, false, priority)
+}
+
+// bytesToImage decodes image bytes, rotating them to their EXIF orientation, on the
+// processing pool.
+func bytesToImage(imgBytes []byte, isOriginal bool, priority processing.Priority) (image.Image, string, error) {
+	var mimeType string
+
+	img, err := processing.Run(priority, processing.StageDecode, func() (image.Image, error) {
+		img, decodedMimeType, err := utils.BytesToImage(imgBytes, isOriginal)
+		mimeType = decodedMimeType
+		return img, err
+	})
+
+	return img, mimeType, err
+}
+
+// encodeImage encodes an image as mimeType on the processing pool.
+func encodeImage(img image.Image, mimeType string, quality int, priority processing.Priority) ([]byte, string, error) {
+	var encodedMimeType string
+
+	imgBytes, err := processing.Run(priority, processing.StageEncode, func() ([]byte, error) {
+		imgBytes, encodedAs, err := utils.EncodeImage(img, mimeType, quality)
+		encodedMimeType = encodedAs
+		return imgBytes, err
+	})
+
+	return imgBytes, encodedMimeType, err
+}
+
 // processAsset handles the entire process of selecting and retrieving an image.
 // It returns the image bytes and an error if any step fails.
 func processAsset(asset *immich.Asset, requestConfig config.Config, requestID string, deviceID string, requestURL string, isPrefetch bool) (image.Image, error) {
@@ -410,15 +508,13 @@ func processAsset(asset *immich.Asset, requestConfig config.Config, requestID st
 
 	for range maxProcessAssetRetries {
 
-		pickedAsset := utils.PickRandomImageType(requestConfig.Kiosk.AssetWeighting, assets)
-
-		pickedAsset.ID, _ = asset.ApplyUserFromAssetID(pickedAsset.ID)
-
-		err = retrieveImage(asset, pickedAsset, requestConfig.AlbumOrder, requestConfig.ExcludedAlbums, requestID, deviceID, isPrefetch)
+		err = pickAsset(asset, assets, requestConfig, requestID, deviceID, isPrefetch)
 		if err != nil {
 			continue
 		}
 
+		markShown(asset, requestConfig, deviceID)
+
 		//  At this point immichAsset could be a video or an image
 		if requestConfig.ShowVideos && asset.Type == immich.VideoType {
 			var img image.Image
@@ -437,9 +533,32 @@ func processAsset(asset *immich.Asset, requestConfig config.Config, requestID st
 		return processImage(asset, requestConfig, requestID, deviceID, isPrefetch)
 	}
 
+	// Every asset may have been shown within the no-repeat window, allow repeats rather than failing
+	if requestConfig.NoRepeatDays > 0 {
+		log.Info(requestID+" No unseen assets left, allowing repeats", "noRepeatDays", requestConfig.NoRepeatDays)
+		requestConfig.NoRepeatDays = 0
+		return processAsset(asset, requestConfig, requestID, deviceID, requestURL, isPrefetch)
+	}
+
 	return nil, fmt.Errorf("%w: max retries exceeded", err)
 }
 
+// pickAsset picks an asset from one of the request's buckets into asset. The asset is
+// not marked as shown, see markShown.
+func pickAsset(asset *immich.Asset, assets []utils.AssetWithWeighting, requestConfig config.Config, requestID, deviceID string, isPrefetch bool) error {
+	pickedAsset := utils.PickRandomImageType(requestConfig.Kiosk.AssetWeighting, assets)
+
+	pickedAsset.ID, _ = asset.ApplyUserFromAssetID(pickedAsset.ID)
+
+	return retrieveImage(asset, pickedAsset, requestConfig.AlbumOrder, requestConfig.ExcludedAlbums, requestID, deviceID, isPrefetch)
+}
+
+// markShown records an asset as shown on a device, for no-repeat and deck mode.
+func markShown(asset *immich.Asset, requestConfig config.Config, deviceID string) {
+	seen.Mark(seen.Key(deviceID, requestConfig.NoRepeatGroup), asset.ID)
+	asset.Played(deviceID)
+}
+
 // processVideo handles retrieving and processing video assets.
 // It downloads videos if needed and returns a preview image.
 func processVideo(immichAsset *immich.Asset, requestConfig config.Config, requestID string, deviceID string, requestURL string, isPrefetch bool) (image.Image, error) {
@@ -484,18 +603,23 @@ func processImage(immichAsset *immich.Asset, requestConfig config.Config, reques
 	return fetchImagePreview(immichAsset, requestConfig.UseOriginalImage, requestID, deviceID, isPrefetch)
 }
 
-// imageToBase64 converts image bytes to a base64 string and logs the processing time.
-// It returns the base64 string and an error if conversion fails.
-func imageToBase64(img image.Image, mimeType string, verboseLogging bool, requestID, deviceID string, action string, isPrefetch bool) (string, error) {
+// imageToURL encodes an image, adds it to the image store and logs the processing time.
+// The image is stored at the format's default, high, quality. The device's image format
+// and quality are added to the URL, so the image is only encoded at the device's quality
+// once, in the format that suits the device, when it is requested.
+// It returns the URL the image is served from and an error if encoding fails.
+func imageToURL(img image.Image, mimeType string, requestConfig config.Config, requestID, deviceID string, action string, isPrefetch bool) (string, error) {
 	startTime := time.Now()
 
-	imgBytes, err := utils.ImageToBase64(img, mimeType)
+	imgBytes, mimeType, err := encodeImage(img, mimeType, 0, processing.PriorityFor(isPrefetch))
 	if err != nil {
-		return "", fmt.Errorf("converting image to base64: %w", err)
+		return "", fmt.Errorf("encoding image: %w", err)
 	}
 
-	logImageProcessing(verboseLogging, requestID, deviceID, isPrefetch, action, startTime)
-	return imgBytes, nil
+	imgURL := storeImage(imgBytes, mimeType, requestConfig)
+
+	logImageProcessing(requestConfig.Kiosk.DebugVerbose, requestID, deviceID, isPrefetch, action, startTime)
+	return imgURL, nil
 }
 
 // shouldSkipBlur determines whether background blur should be skipped.
@@ -516,25 +640,204 @@ func shouldSkipBlur(config config.Config) bool {
 	return false
 }
 
+// processBackground makes the background shown around contained images, as set by
+// background_style. Blurred and mirrored backgrounds are returned as an image URL,
+// colour and gradient backgrounds as CSS. The colour style uses dominantColor.
+// It returns the background's URL, its CSS and an error if any occurs.
+func processBackground(img image.Image, asset *immich.Asset, config config.Config, dominantColor color.RGBA, requestID, deviceID string, isPrefetch bool) (string, string, error) {
+	if asset.Type == immich.ImageType && shouldSkipBlur(config) {
+		return "", "", nil
+	}
+
+	switch config.BackgroundStyle {
+	case kiosk.BackgroundStyleThumbhash:
+		// the thumbhash placeholder is used as the background instead
+		return "", "", nil
+
+	case kiosk.BackgroundStyleColour:
+		return "", utils.CSSColor(dominantColor), nil
+
+	case kiosk.BackgroundStyleGradient:
+		return "", utils.GradientBackground(img, config.ClientData.Width, config.ClientData.Height), nil
+
+	case kiosk.BackgroundStyleMirror:
+		imgURL, err := processMirroredImage(img, asset, config, requestID, deviceID, isPrefetch)
+		return imgURL, "", err
+
+	default:
+		imgURL, err := processBlurredImage(img, asset, config, requestID, deviceID, isPrefetch)
+		return imgURL, "", err
+	}
+}
+
+// processMirroredImage extends the image to the client by mirroring its edges.
+// Mirrored images are kept in the disk cache.
+// It returns the URL of the mirrored image and an error if any occurs.
+func processMirroredImage(img image.Image, asset *immich.Asset, config config.Config, requestID, deviceID string, isPrefetch bool) (string, error) {
+	width, height := config.ClientData.Width, config.ClientData.Height
+
+	mirror := func() ([]byte, error) {
+		return processing.Run(processing.PriorityFor(isPrefetch), processing.StageBackground, func() ([]byte, error) {
+			startTime := time.Now()
+			imgMirror := utils.MirrorImage(img, width, height)
+			logImageProcessing(config.Kiosk.DebugVerbose, requestID, deviceID, isPrefetch, "Mirrored", startTime)
+
+			imgMirrorBytes, _, err := utils.EncodeImage(imgMirror, kiosk.MimeTypeJpeg, 0)
+			if err != nil {
+				return nil, fmt.Errorf("encoding mirrored image: %w", err)
+			}
+
+			return imgMirrorBytes, nil
+		})
+	}
+
+	var imgMirrorBytes []byte
+	var err error
+
+	if ShouldDrawFacesOnImages() {
+		imgMirrorBytes, err = mirror()
+	} else {
+		bounds := img.Bounds()
+		imgMirrorBytes, err = diskcache.Bytes(asset.CacheKey("mirror", bounds.Dx(), bounds.Dy(), width, height, config.Effects, config.FacePrivacyKey()), mirror)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return storeImage(imgMirrorBytes, kiosk.MimeTypeJpeg, config), nil
+}
+
 // processBlurredImage applies a blur effect to the image if required by the configuration.
-// It returns the blurred image as a base64 string and an error if any occurs.
-func processBlurredImage(img image.Image, assetType immich.AssetType, config config.Config, requestID, deviceID string, isPrefetch bool) (string, error) {
-	isImage := assetType == immich.ImageType
+// Blurred images are kept in the disk cache.
+// It returns the URL of the blurred image and an error if any occurs.
+func processBlurredImage(img image.Image, asset *immich.Asset, config config.Config, requestID, deviceID string, isPrefetch bool) (string, error) {
+	isImage := asset.Type == immich.ImageType
 	skipBlur := shouldSkipBlur(config)
 
 	if isImage && skipBlur {
 		return "", nil
 	}
 
-	startTime := time.Now()
-	imgBlur, err := utils.BlurImage(img, config.BackgroundBlurAmount, config.OptimizeImages, config.ClientData.Width, config.ClientData.Height)
+	blur := func() ([]byte, error) {
+		startTime := time.Now()
+		imgBlur, err := utils.BlurImage(img, config.BackgroundBlurAmount, config.OptimizeImages, config.ClientData.Width, config.ClientData.Height)
+		if err != nil {
+			return nil, fmt.Errorf("blurring image: %w", err)
+		}
+
+		logImageProcessing(config.Kiosk.DebugVerbose, requestID, deviceID, isPrefetch, "Blurred", startTime)
+
+		imgBlurBytes, _, err := utils.EncodeImage(imgBlur, kiosk.MimeTypeJpeg, 0)
+		if err != nil {
+			return nil, fmt.Errorf("encoding blurred image: %w", err)
+		}
+
+		return imgBlurBytes, nil
+	}
+
+	// the blur and its encoding are one job, so a device is not left waiting between them
+	blurOnPool := func() ([]byte, error) {
+		return processing.Run(processing.PriorityFor(isPrefetch), processing.StageBlur, blur)
+	}
+
+	var imgBlurBytes []byte
+	var err error
+
+	if ShouldDrawFacesOnImages() {
+		imgBlurBytes, err = blurOnPool()
+	} else {
+		bounds := img.Bounds()
+		cacheKey := asset.CacheKey("blur", bounds.Dx(), bounds.Dy(), config.BackgroundBlurAmount, config.OptimizeImages, config.ClientData.Width, config.ClientData.Height, config.Effects, config.FacePrivacyKey())
+		imgBlurBytes, err = diskcache.Bytes(cacheKey, blurOnPool)
+	}
 	if err != nil {
-		return "", fmt.Errorf("blurring image: %w", err)
+		return "", err
+	}
+
+	return storeImage(imgBlurBytes, kiosk.MimeTypeJpeg, config), nil
+}
+
+// assetPlaceholder returns the asset's thumbhash as a PNG data URL, shown while the
+// image loads. It returns an empty string if the asset has no valid thumbhash.
+func assetPlaceholder(asset *immich.Asset, requestID string) string {
+	if asset.Thumbhash == "" {
+		return ""
+	}
+
+	placeholder, err := thumbhash.DataURL(asset.Thumbhash)
+	if err != nil {
+		log.Debug(requestID+" Invalid thumbhash", "assetID", asset.ID, "err", err)
+		return ""
+	}
+
+	return placeholder
+}
+
+// optimizeImage resizes an image to the client on the processing pool. Resized images
+// are kept in the disk cache.
+func optimizeImage(img image.Image, asset *immich.Asset, requestConfig config.Config, isPrefetch bool) (image.Image, error) {
+	width, height := requestConfig.ClientData.Width, requestConfig.ClientData.Height
+
+	optimize := func() (image.Image, error) {
+		return processing.Run(processing.PriorityFor(isPrefetch), processing.StageOptimize, func() (image.Image, error) {
+			return utils.OptimizeImage(img, width, height)
+		})
+	}
+
+	if ShouldDrawFacesOnImages() {
+		return optimize()
+	}
+
+	bounds := img.Bounds()
+	return diskcache.Image(asset.CacheKey("optimized", bounds.Dx(), bounds.Dy(), width, height, requestConfig.FacePrivacyKey()), optimize)
+}
+
+// hideFaces pixelates, or blurs, the faces in an image that are unnamed or not allowed,
+// as set by FacePrivacy, on the processing pool. Faces are looked up when the asset has
+// none. The image is returned as is when face privacy is off.
+func hideFaces(img image.Image, asset *immich.Asset, requestConfig config.Config, requestID, deviceID string, priority processing.Priority) (image.Image, error) {
+	if requestConfig.FacePrivacyKey() == "" {
+		return img, nil
+	}
+
+	if len(asset.People)+len(asset.UnassignedFaces) == 0 {
+		asset.CheckForFaces(requestID, deviceID)
+	}
+
+	bounds := img.Bounds()
+	regions := asset.PrivateFaceRects(bounds.Dx(), bounds.Dy(), requestConfig.FacePrivacyAllowedPeople)
+	if len(regions) == 0 {
+		return img, nil
+	}
+
+	pixelate := requestConfig.FacePrivacy == kiosk.FacePrivacyPixelate
+
+	return processing.Run(priority, processing.StageFacePrivacy, func() (image.Image, error) {
+		return utils.HideRegions(img, regions, pixelate), nil
+	})
+}
+
+// applyEffects applies the request's effects to an image on the processing pool. Images
+// with effects applied are kept in the disk cache.
+func applyEffects(img image.Image, asset *immich.Asset, requestConfig config.Config, priority processing.Priority) (image.Image, error) {
+	if len(requestConfig.Effects) == 0 {
+		return img, nil
 	}
 
-	logImageProcessing(config.Kiosk.DebugVerbose, requestID, deviceID, isPrefetch, "Blurred", startTime)
+	chain, _ := effects.ParseAll(requestConfig.Effects)
 
-	return imageToBase64(imgBlur, kiosk.MimeTypeJpeg, config.Kiosk.DebugVerbose, requestID, deviceID, "Converted blurred", isPrefetch)
+	apply := func() (image.Image, error) {
+		return processing.Run(priority, processing.StageEffects, func() (image.Image, error) {
+			return effects.Apply(img, chain), nil
+		})
+	}
+
+	if ShouldDrawFacesOnImages() {
+		return apply()
+	}
+
+	bounds := img.Bounds()
+	return diskcache.Image(asset.CacheKey("effects", bounds.Dx(), bounds.Dy(), effects.Signature(chain), requestConfig.FacePrivacyKey()), apply)
 }
 
 // logImageProcessing logs the time taken for image processing if debug verbose is enabled.
@@ -624,34 +927,52 @@ func processViewImageData(requestConfig config.Config, c common.ContextCopy, isP
 		return common.ViewImageData{}, fmt.Errorf("selecting asset: %w", err)
 	}
 
+	return buildViewImageData(img, immichAsset, requestConfig, metadata, isPrefetch)
+}
+
+// buildViewImageData prepares a retrieved image for display, applying face processing,
+// optimisation and format conversion.
+func buildViewImageData(img image.Image, immichAsset immich.Asset, requestConfig config.Config, metadata requestMetadata, isPrefetch bool) (common.ViewImageData, error) {
+	// Hide private faces before anything else is made from the image
+	img, err := hideFaces(img, &immichAsset, requestConfig, metadata.requestID, metadata.deviceID, processing.PriorityFor(isPrefetch))
+	if err != nil {
+		return common.ViewImageData{}, fmt.Errorf("hiding faces: %w", err)
+	}
+
 	// Handle face detection and smart zoom
 	img = handleFaceProcessing(img, &immichAsset, requestConfig, metadata)
 
+	// Crop cover fit images to the client, keeping faces in frame
+	img = smartCropImage(img, &immichAsset, requestConfig, metadata, isPrefetch)
+
 	// Optimize image if needed
 	if requestConfig.OptimizeImages {
-		img, err = utils.OptimizeImage(img, requestConfig.ClientData.Width, requestConfig.ClientData.Height)
+		img, err = optimizeImage(img, &immichAsset, requestConfig, isPrefetch)
 		if err != nil {
 			return common.ViewImageData{}, err
 		}
 	}
 
+	img, err = applyEffects(img, &immichAsset, requestConfig, processing.PriorityFor(isPrefetch))
+	if err != nil {
+		return common.ViewImageData{}, err
+	}
+
 	// Convert images to required formats
 	mimeType := kiosk.MimeTypeJpeg
 	if requestConfig.UseOriginalImage && slices.Contains(kiosk.SupportedImageMimeTypes, immichAsset.OriginalMimeType) {
 		mimeType = immichAsset.OriginalMimeType
 	}
-	imgString, imgBlurString, dominantColor, err := convertImages(img, immichAsset.Type, mimeType, requestConfig, metadata, isPrefetch)
+	viewImageData, err := convertImages(img, &immichAsset, mimeType, requestConfig, metadata, isPrefetch)
 	if err != nil {
 		return common.ViewImageData{}, err
 	}
 
-	return common.ViewImageData{
-		ImmichAsset:        immichAsset,
-		ImageData:          imgString,
-		ImageBlurData:      imgBlurString,
-		ImageDominantColor: dominantColor,
-		User:               immichAsset.SelectedUser(),
-	}, nil
+	viewImageData.ImmichAsset = immichAsset
+	viewImageData.ImagePlaceholder = assetPlaceholder(&immichAsset, metadata.requestID)
+	viewImageData.User = immichAsset.SelectedUser()
+
+	return viewImageData, nil
 }
 
 // setupImmichAsset creates and configures a new ImmichAsset based on the provided config
@@ -669,6 +990,7 @@ func setupImmichAsset(config config.Config, orientation immich.ImageOrientation)
 func handleRelativeAssetConfig(config *config.Config, options common.ViewImageDataOptions) {
 	config.ResetBuckets()
 	config.Memories = false
+	config.Journey = false
 
 	switch options.RelativeAssetBucket {
 	case kiosk.SourceAlbum:
@@ -682,6 +1004,8 @@ func handleRelativeAssetConfig(config *config.Config, options common.ViewImageDa
 	case kiosk.SourceMemories:
 		config.Memories = true
 		config.MemoriesOnly = true
+	case kiosk.SourceJourney:
+		config.Journey = true
 	case kiosk.SourceRandom:
 	}
 }
@@ -701,29 +1025,42 @@ func handleFaceProcessing(img image.Image, asset *immich.Asset, config config.Co
 	return img
 }
 
-// convertImages converts the provided image to base64 strings for both normal and blurred versions.
-// Returns the base64 encoded normal image, blurred image, and any error that occurred.
-func convertImages(img image.Image, assetType immich.AssetType, mimeType string, config config.Config, metadata requestMetadata, isPrefetch bool) (string, string, color.RGBA, error) {
-	var dominantColor color.RGBA
-
-	imgString, err := imageToBase64(img, mimeType, config.Kiosk.DebugVerbose, metadata.requestID, metadata.deviceID, "Converted", isPrefetch)
-	if err != nil {
-		return "", "", dominantColor, err
-	}
+// convertImages adds the provided image and its background to the image store and
+// extracts its dominant colour when needed.
+// Returns view image data holding the image, its background and dominant colour, and
+// any error that occurred.
+func convertImages(img image.Image, asset *immich.Asset, mimeType string, config config.Config, metadata requestMetadata, isPrefetch bool) (common.ViewImageData, error) {
+	var converted common.ViewImageData
+	var err error
 
-	imgBlurString, err := processBlurredImage(img, assetType, config, metadata.requestID, metadata.deviceID, isPrefetch)
+	converted.ImageData, err = imageToURL(img, mimeType, config, metadata.requestID, metadata.deviceID, "Converted", isPrefetch)
 	if err != nil {
-		return "", "", dominantColor, err
+		return converted, err
 	}
 
-	if config.Theme == kiosk.ThemeBubble || config.UseOfflineMode {
-		dominantColor, err = utils.ExtractDominantColor(img)
+	if needsDominantColor(config) {
+		converted.ImageDominantColor, err = utils.ExtractDominantColor(img)
 		if err != nil {
-			return "", "", dominantColor, err
+			return converted, err
 		}
 	}
 
-	return imgString, imgBlurString, dominantColor, nil
+	converted.ImageBlurData, converted.ImageBackground, err = processBackground(img, asset, config, converted.ImageDominantColor, metadata.requestID, metadata.deviceID, isPrefetch)
+	if err != nil {
+		return converted, err
+	}
+
+	return converted, nil
+}
+
+// needsDominantColor reports whether an image's dominant colour is used, by the bubble
+// theme, offline mode, similar colour pairing of assets without a thumbhash or the colour
+// background style.
+func needsDominantColor(config config.Config) bool {
+	return config.Theme == kiosk.ThemeBubble ||
+		config.UseOfflineMode ||
+		slices.Contains(config.SplitViewPairing, kiosk.PairingSimilarColour) ||
+		config.BackgroundStyle == kiosk.BackgroundStyleColour
 }
 
 // ProcessViewImageData processes view data for an image without orientation constraints
@@ -748,7 +1085,7 @@ func assetPreFetch(common *common.Common, requestData *common.RouteRequestData,
 	requestID := requestData.RequestID
 	deviceID := requestData.DeviceID
 
-	viewDataToAdd, err := generateViewData(requestConfig, c, requestID, deviceID, true)
+	viewDataToAdd, err := generateViewData(common.Context(), requestConfig, c, requestID, deviceID, true)
 	if err != nil {
 		log.Error("generateViewData", "prefetch", true, "err", err)
 		return
@@ -800,11 +1137,23 @@ func renderCachedViewData(c *echo.Context, cachedViewData []common.ViewData, req
 }
 
 // fetchSecondSplitViewAsset attempts to retrieve a second asset for split view layouts that is different from the first asset.
-// It tries up to three times to obtain a unique asset and appends it to the provided ViewData if successful.
+// Each configured pairing strategy is tried in order. If none finds a pair it tries up to three times to obtain
+// a unique asset from the first asset's bucket and appends it to the provided ViewData if successful.
 // Returns an error if asset retrieval fails.
 func fetchSecondSplitViewAsset(viewData *common.ViewData, viewDataSplitView common.ViewImageData, requestConfig config.Config, c common.ContextCopy, isPrefetch bool, options common.ViewImageDataOptions) error {
 	const maxImageRetrievalAttempts = 3
 
+	for _, strategy := range requestConfig.SplitViewPairing {
+		viewDataSplitViewSecond, err := pairSplitViewAsset(strategy, viewDataSplitView, requestConfig, c, isPrefetch, options)
+		if err != nil {
+			log.Debug("Split view pairing failed, trying next", "strategy", strategy, "err", err)
+			continue
+		}
+
+		viewData.Assets = append(viewData.Assets, viewDataSplitViewSecond)
+		return nil
+	}
+
 	for range maxImageRetrievalAttempts {
 		viewDataSplitViewSecond, err := ProcessViewImageDataWithOptions(requestConfig, c, isPrefetch, options)
 		if err != nil {
@@ -829,8 +1178,9 @@ func determineLayoutMode(layout string, clientHeight, clientWidth int) string {
 }
 
 // generateViewData prepares view data for a kiosk page request based on the specified layout and client display dimensions.
-// It selects and processes one or two assets as needed for the layout, handling orientation and split view logic, and returns the resulting ViewData or an error.
-func generateViewData(requestConfig config.Config, c common.ContextCopy, requestID, deviceID string, isPrefetch bool) (common.ViewData, error) {
+// It selects and processes as many assets as the layout needs, handling orientation and split view logic, and returns the resulting ViewData or an error.
+// Views that are not prefetched are served straight away, so any story they start or advance is updated, see serveStory.
+func generateViewData(ctx context.Context, requestConfig config.Config, c common.ContextCopy, requestID, deviceID string, isPrefetch bool) (common.ViewData, error) {
 	viewData := common.ViewData{
 		RequestID: requestID,
 		DeviceID:  deviceID,
@@ -839,6 +1189,18 @@ func generateViewData(requestConfig config.Config, c common.ContextCopy, request
 
 	requestConfig.Layout = determineLayoutMode(requestConfig.Layout, requestConfig.ClientData.Height, requestConfig.ClientData.Width)
 
+	if requestConfig.Story {
+		if storyViewData, ok := nextStoryViewData(ctx, viewData, requestConfig, c, isPrefetch); ok {
+			if !isPrefetch {
+				serveStory(storyViewData, deviceID)
+			}
+			return storyViewData, nil
+		}
+	} else {
+		// a device that turns story mode off leaves any story it was playing
+		story.End(deviceID)
+	}
+
 	switch requestConfig.Layout {
 	case kiosk.LayoutLandscape, kiosk.LayoutPortrait:
 		options := common.ViewImageDataOptions{
@@ -899,6 +1261,11 @@ func generateViewData(requestConfig config.Config, c common.ContextCopy, request
 			return viewData, secondAssetErr
 		}
 
+	case kiosk.LayoutGrid2x2, kiosk.LayoutTriptych, kiosk.LayoutMosaic:
+		if err := fetchLayoutAssets(&viewData, requestConfig, c, isPrefetch); err != nil {
+			return viewData, err
+		}
+
 	default:
 		viewDataSingle, err := ProcessViewImageData(requestConfig, c, isPrefetch)
 		if err != nil {
@@ -907,5 +1274,12 @@ func generateViewData(requestConfig config.Config, c common.ContextCopy, request
 		viewData.Assets = append(viewData.Assets, viewDataSingle)
 	}
 
+	if requestConfig.Story {
+		startStory(&viewData, requestConfig, requestID, deviceID)
+		if !isPrefetch {
+			serveStory(viewData, deviceID)
+		}
+	}
+
 	return viewData, nil
 }
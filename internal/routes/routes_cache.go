@@ -1,12 +1,16 @@
 package routes
 
 import (
+	"context"
 	"net/http"
+	"strings"
+	"time"
 
 	"charm.land/log/v2"
 	"github.com/damongolding/immich-kiosk/internal/cache"
 	"github.com/damongolding/immich-kiosk/internal/common"
 	"github.com/damongolding/immich-kiosk/internal/config"
+	"github.com/damongolding/immich-kiosk/internal/immich"
 	"github.com/damongolding/immich-kiosk/internal/webhooks"
 	"github.com/labstack/echo/v5"
 )
@@ -25,12 +29,166 @@ func FlushCache(baseConfig *config.Config, com *common.Common) echo.HandlerFunc
 
 		log.Info("Cache before flush", "cache_items", cache.ItemCount())
 
+		flushed := cache.NewReport(cache.Filter{}, cache.Entries(cache.Filter{}))
 		cache.Flush()
 
 		log.Info("Cache after flush ", "cache_items", cache.ItemCount())
 
 		c.Response().Header().Set("HX-Refresh", "true")
-		go webhooks.Trigger(com.Context(), requestData, KioskVersion, webhooks.CacheFlush, common.ViewData{})
+		go webhooks.TriggerCacheFlush(com.Context(), requestData, KioskVersion, webhooks.CacheFlush, flushed)
 		return c.NoContent(http.StatusNoContent)
 	}
 }
+
+// CacheEntries returns an echo.HandlerFunc that lists cache entries with their sizes
+// and TTLs, largest first.
+//
+// Parameters (query or form):
+//   - category: api, memories, view, video, image or other
+//   - deviceID: Only entries for this device
+//   - url: Glob or "re:" regex matched against the URL an entry was made from
+func CacheEntries(baseConfig *config.Config) echo.HandlerFunc {
+	return func(c *echo.Context) error {
+		requestData, err := InitializeRequestData(c, baseConfig)
+		if err != nil {
+			return err
+		}
+
+		if requestData == nil {
+			log.Info("Refreshing clients")
+			return nil
+		}
+
+		filter, err := cacheFilter(c)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		entries := append(cache.Entries(filter), videoCacheEntries(filter)...)
+		cache.SortEntries(entries)
+
+		return c.JSON(http.StatusOK, cache.NewReport(filter, entries))
+	}
+}
+
+// CacheStats returns an echo.HandlerFunc that reports cache hit and miss rates, and how
+// many entries are cached, by category.
+func CacheStats(baseConfig *config.Config) echo.HandlerFunc {
+	return func(c *echo.Context) error {
+		requestData, err := InitializeRequestData(c, baseConfig)
+		if err != nil {
+			return err
+		}
+
+		if requestData == nil {
+			log.Info("Refreshing clients")
+			return nil
+		}
+
+		stats := cache.GetStats()
+
+		videos := stats.Categories[cache.CategoryVideo]
+		for _, entry := range videoCacheEntries(cache.Filter{}) {
+			videos.Items++
+			videos.Size += entry.Size
+			stats.Total.Items++
+			stats.Total.Size += entry.Size
+		}
+		stats.Categories[cache.CategoryVideo] = videos
+
+		return c.JSON(http.StatusOK, stats)
+	}
+}
+
+// InvalidateCache returns an echo.HandlerFunc that removes the cache entries selected
+// by the request, responding with and sending a cache.flush.partial webhook reporting
+// what was removed. At least one parameter is required, use /cache/flush to remove everything.
+//
+// Parameters (query or form):
+//   - category: api, memories, view, video, image or other
+//   - deviceID: Only entries for this device
+//   - url: Glob or "re:" regex matched against the URL an entry was made from, e.g. "/albums/<album id>"
+func InvalidateCache(baseConfig *config.Config, com *common.Common) echo.HandlerFunc {
+	return func(c *echo.Context) error {
+		requestData, err := InitializeRequestData(c, baseConfig)
+		if err != nil {
+			return err
+		}
+
+		if requestData == nil {
+			log.Info("Refreshing clients")
+			return nil
+		}
+
+		filter, err := cacheFilter(c)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		if filter == (cache.Filter{}) {
+			return echo.NewHTTPError(http.StatusBadRequest, "category, deviceID or url is required")
+		}
+
+		invalidated := cache.Invalidate(filter)
+
+		videos := videoCacheEntries(filter)
+		for _, entry := range videos {
+			VideoManager.Remove(entry.Key)
+		}
+
+		entries := append(invalidated.Entries, videos...)
+		cache.SortEntries(entries)
+		report := cache.NewReport(filter, entries)
+
+		log.Info(requestData.RequestID+" Cache invalidated", "filter", filter, "entries", report.Count, "size", report.Size)
+
+		go webhooks.TriggerCacheFlush(com.Context(), requestData, KioskVersion, webhooks.CacheFlushPartial, report)
+
+		return c.JSON(http.StatusOK, report)
+	}
+}
+
+// WatchImmichChanges checks Immich for changed assets, albums and people every
+// kiosk.watch_immich_interval seconds until ctx is done, removing the cached responses
+// they affect and sending a cache.flush.changes webhook for each check that removed any.
+func WatchImmichChanges(ctx context.Context, baseConfig *config.Config) {
+	interval := time.Duration(baseConfig.Kiosk.WatchImmichInterval) * time.Second
+
+	log.Info("Watching Immich for changes", "interval", interval)
+
+	watcher := immich.NewChangeWatcher(ctx, *baseConfig)
+	watcher.Watch(ctx, interval, func(changes immich.Changes) {
+		requestData := &common.RouteRequestData{
+			RequestConfig: *baseConfig,
+		}
+
+		webhooks.TriggerCacheFlush(ctx, requestData, KioskVersion, webhooks.CacheFlushChanges, changes.Flushed)
+	})
+}
+
+// cacheFilter reads the cache entries a request selects from its query or form values.
+func cacheFilter(c *echo.Context) (cache.Filter, error) {
+	filter := cache.Filter{
+		Category: cache.Category(strings.ToLower(strings.TrimSpace(c.FormValue("category")))),
+		DeviceID: strings.TrimSpace(c.FormValue("deviceID")),
+		URL:      strings.TrimSpace(c.FormValue("url")),
+	}
+
+	return filter, filter.Validate()
+}
+
+// videoCacheEntries returns the downloaded videos selected by filter.
+func videoCacheEntries(filter cache.Filter) []cache.Entry {
+	if VideoManager == nil {
+		return nil
+	}
+
+	var entries []cache.Entry
+	for _, entry := range VideoManager.CacheEntries() {
+		if filter.Matches(entry) {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries
+}
@@ -0,0 +1,179 @@
+package routes
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"charm.land/log/v2"
+	"github.com/labstack/echo/v5"
+
+	"github.com/damongolding/immich-kiosk/internal/cache"
+	"github.com/damongolding/immich-kiosk/internal/config"
+	"github.com/damongolding/immich-kiosk/internal/imagestore"
+	"github.com/damongolding/immich-kiosk/internal/kiosk"
+	"github.com/damongolding/immich-kiosk/internal/processing"
+	"github.com/damongolding/immich-kiosk/internal/utils"
+)
+
+// OfflineImagesPath is where the images of offline assets are kept, so they can be
+// served after a restart.
+var OfflineImagesPath = filepath.Join(OfflineAssetsPath, "_images")
+
+// StoredImage returns an HTTP handler that serves processed images from the image store
+// by their content hash. Images of offline assets are served from disk when they are not
+// in the store.
+//
+// JPEG images are sent as WebP or AVIF when the URL's format allows it and the browser
+// accepts it, and at the URL's quality when it has one. Stored JPEGs are kept at a high
+// quality so these variants lose little. As an image's URL changes whenever its content
+// does, responses carry a strong ETag and may be cached indefinitely. Range requests are
+// supported.
+// Returns 404 if the image is not found.
+func StoredImage(c *echo.Context) error {
+	name := c.Param("name")
+	if !imagestore.ValidName(name) {
+		return echo.NewHTTPError(http.StatusNotFound, "Image not found")
+	}
+
+	img, found := imagestore.Get(name)
+	if !found {
+		img, found = offlineImage(name)
+	}
+
+	if !found {
+		return echo.NewHTTPError(http.StatusNotFound, "Image not found")
+	}
+
+	format := c.QueryParam("format")
+	quality, _ := strconv.Atoi(c.QueryParam("quality"))
+	quality = min(max(quality, 0), 100)
+
+	if img.MimeType == kiosk.MimeTypeJpeg && (format != "" || quality != 0) {
+		mimeType := kiosk.MimeTypeJpeg
+		if format != "" {
+			c.Response().Header().Add("Vary", "Accept")
+			mimeType = negotiateMimeType(format, c.Request().Header.Get("Accept"))
+		}
+
+		if mimeType != img.MimeType || quality != 0 {
+			variant, err := imagestore.Variant(img, mimeType, quality, encodeVariant)
+			if err != nil {
+				log.Error("Encoding image variant", "name", name, "mimeType", mimeType, "err", err)
+			} else {
+				img = variant
+			}
+		}
+	}
+
+	c.Response().Header().Set("ETag", img.ETag)
+	c.Response().Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+	c.Response().Header().Set("Content-Type", img.MimeType)
+
+	http.ServeContent(c.Response(), c.Request(), name, img.Created, bytes.NewReader(img.Data))
+	return nil
+}
+
+// offlineImage reads an image of an offline asset from disk.
+func offlineImage(name string) (imagestore.Image, bool) {
+	filePath := filepath.Join(OfflineImagesPath, name)
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return imagestore.Image{}, false
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return imagestore.Image{}, false
+	}
+
+	return imagestore.Image{
+		Name:     name,
+		MimeType: utils.ImageMimeType(bytes.NewReader(data)),
+		Data:     data,
+		Created:  info.ModTime(),
+		ETag:     imagestore.ETag(name),
+	}, true
+}
+
+// storeImage adds an image to the image store and returns the URL it is served from in
+// the device's image format. The image is pinned for as long as views are cached, so a
+// view that links to it is never left with a missing image.
+func storeImage(data []byte, mimeType string, requestConfig config.Config) string {
+	pinFor := cache.Expiration(requestConfig.Duration, requestConfig.CacheDuration)
+	return imagestore.PutFor(data, mimeType, pinFor) + imageFormatQuery(requestConfig)
+}
+
+// imageFormatQuery returns the query added to image URLs so they are sent in the
+// device's image format and quality. JPEG needs no format as images are stored as JPEG,
+// and the default quality needs no quality.
+func imageFormatQuery(requestConfig config.Config) string {
+	query := url.Values{}
+
+	if requestConfig.ImageFormat != "" && requestConfig.ImageFormat != kiosk.ImageFormatJpeg {
+		query.Set("format", requestConfig.ImageFormat)
+	}
+
+	if requestConfig.ImageQuality != 0 {
+		query.Set("quality", strconv.Itoa(requestConfig.ImageQuality))
+	}
+
+	if len(query) == 0 {
+		return ""
+	}
+
+	return "?" + query.Encode()
+}
+
+// negotiateMimeType returns the MIME type a JPEG image is sent as, given the device's
+// image format and the browser's Accept header. AVIF falls back to WebP and WebP to
+// JPEG when the browser does not accept them, keeping older browsers on JPEG.
+func negotiateMimeType(format, accept string) string {
+	if format == kiosk.ImageFormatAvif && acceptsMimeType(accept, kiosk.MimeTypeAvif) {
+		return kiosk.MimeTypeAvif
+	}
+
+	if format != kiosk.ImageFormatJpeg && acceptsMimeType(accept, kiosk.MimeTypeWebp) {
+		return kiosk.MimeTypeWebp
+	}
+
+	return kiosk.MimeTypeJpeg
+}
+
+// acceptsMimeType reports whether an Accept header explicitly lists mimeType.
+// Wildcards are ignored, as browsers send them regardless of the formats they can show.
+func acceptsMimeType(accept, mimeType string) bool {
+	for part := range strings.SplitSeq(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || mediaType != mimeType {
+			continue
+		}
+
+		if q, ok := params["q"]; ok {
+			if qValue, parseErr := strconv.ParseFloat(q, 64); parseErr == nil && qValue == 0 {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// encodeVariant re-encodes a stored image as mimeType at quality on the processing pool.
+func encodeVariant(img imagestore.Image, mimeType string, quality int) ([]byte, error) {
+	decoded, _, err := bytesToImage(img.Data, false, processing.Interactive)
+	if err != nil {
+		return nil, err
+	}
+
+	data, _, err := encodeImage(decoded, mimeType, quality, processing.Interactive)
+	return data, err
+}
@@ -0,0 +1,172 @@
+package routes
+
+import (
+	"charm.land/log/v2"
+	"github.com/labstack/echo/v5"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/damongolding/immich-kiosk/internal/common"
+	"github.com/damongolding/immich-kiosk/internal/config"
+	"github.com/damongolding/immich-kiosk/internal/immich"
+	"github.com/damongolding/immich-kiosk/internal/kiosk"
+	"github.com/damongolding/immich-kiosk/internal/utils"
+)
+
+const (
+	gridAssetCount     = 4
+	triptychAssetCount = 3
+	mosaicAssetCount   = 5
+
+	// maxLayoutTopUps how many more assets are picked to replace duplicates
+	maxLayoutTopUps = 3
+)
+
+// layoutAssetCount returns how many assets a grid, triptych or mosaic layout shows.
+func layoutAssetCount(layout string) int {
+	switch layout {
+	case kiosk.LayoutGrid2x2:
+		return gridAssetCount
+	case kiosk.LayoutTriptych:
+		return triptychAssetCount
+	default:
+		return mosaicAssetCount
+	}
+}
+
+// layoutOrientation returns the orientation of asset that best fills a layout's tiles.
+// Grid tiles share the client's orientation, triptych tiles are the opposite of it and
+// mosaic tiles adapt to any orientation.
+func layoutOrientation(layout string, clientWidth, clientHeight int) immich.ImageOrientation {
+	landscapeClient := clientWidth >= clientHeight
+
+	switch layout {
+	case kiosk.LayoutGrid2x2:
+		if landscapeClient {
+			return immich.LandscapeOrientation
+		}
+		return immich.PortraitOrientation
+	case kiosk.LayoutTriptych:
+		if landscapeClient {
+			return immich.PortraitOrientation
+		}
+		return immich.LandscapeOrientation
+	default:
+		return ""
+	}
+}
+
+// fetchLayoutAssets fills a grid, triptych or mosaic layout with distinct assets.
+// After the first asset the rest are picked one at a time, so duplicates are replaced
+// before anything is marked as shown, and then processed in parallel.
+// If the first asset is a video it is shown on its own.
+//
+// Parameters:
+//   - viewData: ViewData the assets are appended to
+//   - requestConfig: Configuration settings for the request
+//   - c: Copy of the request context
+//   - isPrefetch: Whether this is a prefetch request
+//
+// Returns:
+//   - Error if the first asset could not be retrieved
+func fetchLayoutAssets(viewData *common.ViewData, requestConfig config.Config, c common.ContextCopy, isPrefetch bool) error {
+	count := layoutAssetCount(requestConfig.Layout)
+
+	options := common.ViewImageDataOptions{
+		ImageOrientation: layoutOrientation(requestConfig.Layout, requestConfig.ClientData.Width, requestConfig.ClientData.Height),
+	}
+
+	first, err := ProcessViewImageDataWithOptions(requestConfig, c, isPrefetch, options)
+	if err != nil {
+		return err
+	}
+	viewData.Assets = append(viewData.Assets, first)
+
+	if first.ImmichAsset.Type == immich.VideoType {
+		return nil
+	}
+
+	metadata := requestMetadata{
+		requestID: utils.ColorizeRequestID(c.ResponseHeader.Get(echo.HeaderXRequestID)),
+		deviceID:  c.RequestHeader.Get("kiosk-device-id"),
+		urlString: c.URL.String(),
+	}
+
+	picked, err := pickLayoutAssets(first.ImmichAsset, count-1, requestConfig, metadata, isPrefetch, options)
+	if err != nil {
+		log.Debug("Failed to pick layout assets", "layout", requestConfig.Layout, "err", err)
+	}
+
+	rest := make([]common.ViewImageData, len(picked))
+
+	var g errgroup.Group
+	for i := range picked {
+		g.Go(func() error {
+			img, imgErr := processImage(&picked[i], requestConfig, metadata.requestID, metadata.deviceID, isPrefetch)
+			if imgErr != nil {
+				return imgErr
+			}
+
+			asset, assetErr := buildViewImageData(img, picked[i], requestConfig, metadata, isPrefetch)
+			if assetErr != nil {
+				return assetErr
+			}
+
+			rest[i] = asset
+			return nil
+		})
+	}
+
+	if err = g.Wait(); err != nil {
+		log.Debug("Failed to get layout asset", "layout", requestConfig.Layout, "err", err)
+	}
+
+	for _, asset := range rest {
+		if asset.ImmichAsset.ID != "" {
+			viewData.Assets = append(viewData.Assets, asset)
+		}
+	}
+
+	return nil
+}
+
+// pickLayoutAssets picks up to count images, distinct from first and each other, from the
+// request's buckets and marks them as shown. Duplicates and videos are skipped, with up to
+// maxLayoutTopUps more picks made to replace them.
+func pickLayoutAssets(first immich.Asset, count int, requestConfig config.Config, metadata requestMetadata, isPrefetch bool, options common.ViewImageDataOptions) ([]immich.Asset, error) {
+	bucket := setupImmichAsset(requestConfig, options.ImageOrientation)
+
+	assets, err := gatherAssetBuckets(&bucket, requestConfig, metadata.requestID, metadata.deviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	shown := map[string]bool{first.ID: true}
+	picked := make([]immich.Asset, 0, count)
+
+	for range count + maxLayoutTopUps {
+		if len(picked) >= count {
+			break
+		}
+
+		candidate := setupImmichAsset(requestConfig, options.ImageOrientation)
+
+		if err = pickAsset(&candidate, assets, requestConfig, metadata.requestID, metadata.deviceID, isPrefetch); err != nil {
+			continue
+		}
+
+		// videos would need downloading before they could be shown
+		if candidate.ID == "" || shown[candidate.ID] || candidate.Type == immich.VideoType {
+			continue
+		}
+
+		shown[candidate.ID] = true
+		markShown(&candidate, requestConfig, metadata.deviceID)
+		picked = append(picked, candidate)
+	}
+
+	if len(picked) < count {
+		return picked, err
+	}
+
+	return picked, nil
+}
@@ -22,6 +22,7 @@ import (
 	"github.com/damongolding/immich-kiosk/internal/common"
 	"github.com/damongolding/immich-kiosk/internal/config"
 	"github.com/damongolding/immich-kiosk/internal/i18n"
+	"github.com/damongolding/immich-kiosk/internal/imagestore"
 	"github.com/damongolding/immich-kiosk/internal/immich"
 	"github.com/damongolding/immich-kiosk/internal/kiosk"
 	imageComponent "github.com/damongolding/immich-kiosk/internal/templates/components/image"
@@ -171,6 +172,8 @@ func downloadOfflineAssets(requestConfig config.Config, requestCtx common.Contex
 	defer mu.Unlock()
 
 	requestConfig.UseOfflineMode = true
+	// stories follow a device's live playback so are not stored offline
+	requestConfig.Story = false
 	parallelDownloads := requestConfig.OfflineMode.ParallelDownloads
 	numberOfAssets := requestConfig.OfflineMode.NumberOfAssets
 	maxSize, maxSizeErr := utils.ParseSize(requestConfig.OfflineMode.MaxSize)
@@ -217,7 +220,7 @@ func downloadOfflineAssets(requestConfig config.Config, requestCtx common.Contex
 					return err
 				}
 
-				viewData, err := generateViewData(requestConfig, requestCtx, requestID, deviceID, false)
+				viewData, err := generateViewData(egCtx, requestConfig, requestCtx, requestID, deviceID, false)
 				if err != nil {
 					log.Error("SaveOfflineAsset: generateViewData", "err", err)
 					if errorCount.Add(1) > fileExistsTolerance*2 {
@@ -271,7 +274,12 @@ func downloadOfflineAssets(requestConfig config.Config, requestCtx common.Contex
 
 				createdFiles.Store(filename, true)
 
-				err = saveMsgpackZstd(egCtx, filename, viewData, &offlineSize, maxSize, &createdFiles)
+				err = saveOfflineImages(viewData, &offlineSize, maxSize)
+				if err == nil {
+					err = saveMsgpackZstd(egCtx, filename, viewData, &offlineSize, maxSize, &createdFiles)
+				} else {
+					createdFiles.Delete(filename)
+				}
 				if errors.Is(err, ErrMaxStorageReached) {
 					once.Do(func() {
 						humanOfflineSize := humanize.Bytes(uint64(offlineSize.Load()))
@@ -397,6 +405,75 @@ func saveMsgpackZstd(ctx context.Context, filename string, data common.ViewData,
 	return nil
 }
 
+// saveOfflineImages writes the stored images a view uses to disk, so they can be served
+// once the image store no longer holds them. Images are named by content hash, so
+// views sharing an image share its file.
+// Returns ErrMaxStorageReached if adding an image would exceed the configured max size.
+func saveOfflineImages(viewData common.ViewData, offlineSize *atomic.Int64, maxSize int64) error {
+	if err := os.MkdirAll(OfflineImagesPath, 0o755); err != nil {
+		return err
+	}
+
+	for _, asset := range viewData.Assets {
+		for _, imgURL := range []string{asset.ImageData, asset.ImageBlurData} {
+			name, ok := imagestore.NameFromURL(imgURL)
+			if !ok {
+				continue
+			}
+
+			filePath := filepath.Join(OfflineImagesPath, name)
+			if _, statErr := os.Stat(filePath); statErr == nil {
+				continue
+			}
+
+			img, found := imagestore.Get(name)
+			if !found {
+				return fmt.Errorf("image %s is no longer stored", name)
+			}
+
+			newTotal := offlineSize.Add(int64(len(img.Data)))
+			if maxSize != 0 && newTotal > maxSize {
+				offlineSize.Add(-int64(len(img.Data)))
+				return ErrMaxStorageReached
+			}
+
+			if err := writeFileAtomic(filePath, img.Data); err != nil {
+				offlineSize.Add(-int64(len(img.Data)))
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// writeFileAtomic writes data to a temporary file and renames it into place, so
+// readers never see a partly written file.
+func writeFileAtomic(filename string, data []byte) error {
+	tmp, err := os.CreateTemp(path.Dir(filename), ".offline-*")
+	if err != nil {
+		return err
+	}
+
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+
+	if err = tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+
+	if err = os.Rename(tmp.Name(), filename); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+
+	return nil
+}
+
 // loadMsgpackZstd loads and decodes a msgpack+zstd compressed file into ViewData.
 // Returns the decoded ViewData and any error encountered during the process.
 func loadMsgpackZstd(filename string) (common.ViewData, error) {
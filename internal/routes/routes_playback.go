@@ -0,0 +1,159 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"charm.land/log/v2"
+	"github.com/labstack/echo/v5"
+
+	"github.com/damongolding/immich-kiosk/internal/config"
+	"github.com/damongolding/immich-kiosk/internal/deck"
+	"github.com/damongolding/immich-kiosk/internal/immich"
+	"github.com/damongolding/immich-kiosk/internal/kiosk"
+	"github.com/damongolding/immich-kiosk/internal/playback"
+)
+
+// playbackTarget resolves the device and bucket a playback request applies to.
+// The device defaults to the requesting device, the bucket is either an album ID,
+// "memories" or "journey" and is scoped to the selected user.
+// Another device can only be targeted once it has a playback position or deck, so
+// requests cannot fill the store with made up devices.
+func playbackTarget(c *echo.Context, deviceID string, requestConfig config.Config) (string, string, error) {
+	if d := c.FormValue("deviceID"); d != "" && d != deviceID {
+		if !playback.HasDevice(d) && !deck.HasDevice(d) {
+			return "", "", echo.NewHTTPError(http.StatusNotFound, "Unknown device")
+		}
+		deviceID = d
+	}
+
+	bucket := strings.TrimSpace(c.FormValue("bucket"))
+	switch {
+	case strings.EqualFold(bucket, string(kiosk.SourceMemories)):
+		bucket = string(kiosk.SourceMemories)
+	case strings.EqualFold(bucket, string(kiosk.SourceJourney)):
+		bucket = immich.JourneyBucketID(requestConfig)
+	}
+
+	if bucket == "" {
+		return deviceID, "", nil
+	}
+
+	return deviceID, playback.BucketID(bucket, requestConfig.SelectedUser), nil
+}
+
+// ResetPlayback returns an echo.HandlerFunc that returns a device to the start of an
+// ordered album, memories or journey. If no bucket is supplied all of the device's positions are reset.
+// The "deck" bucket reshuffles all of the device's decks.
+//
+// Parameters (query or form):
+//   - deviceID: Device to reset, defaults to the requesting device. Must be a known device
+//   - bucket: Album ID, "memories", "journey" or "deck"
+func ResetPlayback(baseConfig *config.Config) echo.HandlerFunc {
+	return func(c *echo.Context) error {
+		requestData, err := InitializeRequestData(c, baseConfig)
+		if err != nil {
+			return err
+		}
+
+		if requestData == nil {
+			log.Info("Refreshing clients")
+			return nil
+		}
+
+		requestID := requestData.RequestID
+
+		log.Debug(
+			requestID,
+			"method", c.Request().Method,
+			"path", c.Request().URL.String(),
+		)
+
+		deviceID, bucketID, err := playbackTarget(c, requestData.DeviceID, requestData.RequestConfig)
+		if err != nil {
+			return err
+		}
+
+		if deviceID == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "Device ID is required")
+		}
+
+		if strings.EqualFold(strings.TrimSpace(c.FormValue("bucket")), "deck") {
+			reset := deck.Reset(deviceID)
+			log.Info(requestID+" Decks reset", "deviceID", deviceID, "decks", reset)
+			return c.String(http.StatusOK, "SUCCESS")
+		}
+
+		reset := playback.Reset(deviceID, bucketID)
+
+		log.Info(requestID+" Playback position reset", "deviceID", deviceID, "bucket", bucketID, "positions", reset)
+
+		return c.String(http.StatusOK, "SUCCESS")
+	}
+}
+
+// SeekPlayback returns an echo.HandlerFunc that moves a device's position within an
+// ordered album, memories or journey. Playback resumes from the given asset, or from the given
+// index within the ordered bucket (the journey only supports seeking to an asset).
+//
+// Parameters (query or form):
+//   - deviceID: Device to seek, defaults to the requesting device. Must be a known device
+//   - bucket: Album ID, "memories" or "journey"
+//   - assetID: Asset to resume from
+//   - index: Zero based position to resume from, used when assetID is not set
+func SeekPlayback(baseConfig *config.Config) echo.HandlerFunc {
+	return func(c *echo.Context) error {
+		requestData, err := InitializeRequestData(c, baseConfig)
+		if err != nil {
+			return err
+		}
+
+		if requestData == nil {
+			log.Info("Refreshing clients")
+			return nil
+		}
+
+		requestID := requestData.RequestID
+
+		log.Debug(
+			requestID,
+			"method", c.Request().Method,
+			"path", c.Request().URL.String(),
+		)
+
+		deviceID, bucketID, err := playbackTarget(c, requestData.DeviceID, requestData.RequestConfig)
+		if err != nil {
+			return err
+		}
+
+		if deviceID == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "Device ID is required")
+		}
+
+		if bucketID == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "Bucket is required")
+		}
+
+		assetID := c.FormValue("assetID")
+		index := 0
+
+		if assetID == "" {
+			indexStr := c.FormValue("index")
+			if indexStr == "" {
+				return echo.NewHTTPError(http.StatusBadRequest, "Asset ID or index is required")
+			}
+
+			index, err = strconv.Atoi(indexStr)
+			if err != nil || index < 0 {
+				return echo.NewHTTPError(http.StatusBadRequest, "Index must be a positive number")
+			}
+		}
+
+		playback.Seek(deviceID, bucketID, assetID, index)
+
+		log.Info(requestID+" Playback position changed", "deviceID", deviceID, "bucket", bucketID, "assetID", assetID, "index", index)
+
+		return c.String(http.StatusOK, "SUCCESS")
+	}
+}
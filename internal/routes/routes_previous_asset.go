@@ -17,6 +17,7 @@ import (
 	"github.com/damongolding/immich-kiosk/internal/i18n"
 	"github.com/damongolding/immich-kiosk/internal/immich"
 	"github.com/damongolding/immich-kiosk/internal/kiosk"
+	"github.com/damongolding/immich-kiosk/internal/processing"
 	imageComponent "github.com/damongolding/immich-kiosk/internal/templates/components/image"
 	videoComponent "github.com/damongolding/immich-kiosk/internal/templates/components/video"
 	"github.com/damongolding/immich-kiosk/internal/utils"
@@ -184,7 +185,7 @@ func getHistoryAsset(requestConfig config.Config, com *common.Common, requestID,
 			asset.AlbumsThatContainAsset(requestID, deviceID)
 		}
 
-		var imgString, imgBlurString string
+		var imgString, imgBlurString, imgBackground string
 		var dominantColor color.RGBA
 		var err error
 
@@ -194,6 +195,8 @@ func getHistoryAsset(requestConfig config.Config, com *common.Common, requestID,
 				ImmichAsset:        asset,
 				ImageData:          imgString,
 				ImageBlurData:      imgBlurString,
+				ImagePlaceholder:   assetPlaceholder(&asset, requestID),
+				ImageBackground:    imgBackground,
 				ImageDominantColor: dominantColor,
 				User:               selectedUser,
 			}
@@ -212,28 +215,38 @@ func getHistoryAsset(requestConfig config.Config, com *common.Common, requestID,
 			}
 		}
 
-		img, mimeType, byteErr := utils.BytesToImage(imgBytes, requestConfig.UseOriginalImage)
+		img, mimeType, byteErr := decodeImage(&asset, imgBytes, requestConfig.UseOriginalImage, requestID, processing.Interactive)
 		if byteErr != nil {
 			return byteErr
 		}
 
-		imgString, base64Err := imageToBase64(img, mimeType, requestConfig.Kiosk.DebugVerbose, requestID, deviceID, "Converted", false)
-		if base64Err != nil {
-			return fmt.Errorf("converting image to base64: %w", base64Err)
+		img, byteErr = hideFaces(img, &asset, requestConfig, requestID, deviceID, processing.Interactive)
+		if byteErr != nil {
+			return fmt.Errorf("hiding faces: %w", byteErr)
+		}
+
+		img, byteErr = applyEffects(img, &asset, requestConfig, processing.Interactive)
+		if byteErr != nil {
+			return fmt.Errorf("applying effects: %w", byteErr)
 		}
 
-		imgBlurString, blurErr := processBlurredImage(img, asset.Type, requestConfig, requestID, deviceID, false)
-		if blurErr != nil {
-			return fmt.Errorf("converting blurred image to base64: %w", blurErr)
+		imgString, urlErr := imageToURL(img, mimeType, requestConfig, requestID, deviceID, "Converted", false)
+		if urlErr != nil {
+			return fmt.Errorf("converting image: %w", urlErr)
 		}
 
-		if requestConfig.Theme == kiosk.ThemeBubble {
+		if requestConfig.Theme == kiosk.ThemeBubble || requestConfig.BackgroundStyle == kiosk.BackgroundStyleColour {
 			dominantColor, err = utils.ExtractDominantColor(img)
 			if err != nil {
 				return fmt.Errorf("extracting dominant colour: %w", err)
 			}
 		}
 
+		imgBlurString, imgBackground, err = processBackground(img, &asset, requestConfig, dominantColor, requestID, deviceID, false)
+		if err != nil {
+			return fmt.Errorf("converting background: %w", err)
+		}
+
 		return nil
 	}
 }
@@ -0,0 +1,29 @@
+package routes
+
+import (
+	"net/http"
+
+	"charm.land/log/v2"
+	"github.com/labstack/echo/v5"
+
+	"github.com/damongolding/immich-kiosk/internal/config"
+	"github.com/damongolding/immich-kiosk/internal/processing"
+)
+
+// ProcessingStats returns an echo.HandlerFunc that responds with the image processing
+// pool's size and load, and how long each stage has taken and waited to run.
+func ProcessingStats(baseConfig *config.Config) echo.HandlerFunc {
+	return func(c *echo.Context) error {
+		requestData, err := InitializeRequestData(c, baseConfig)
+		if err != nil {
+			return err
+		}
+
+		if requestData == nil {
+			log.Info("Refreshing clients")
+			return nil
+		}
+
+		return c.JSON(http.StatusOK, processing.GetStats())
+	}
+}
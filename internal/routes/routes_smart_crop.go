@@ -0,0 +1,78 @@
+package routes
+
+import (
+	"image"
+	"strings"
+	"time"
+
+	"github.com/damongolding/immich-kiosk/internal/cache"
+	"github.com/damongolding/immich-kiosk/internal/config"
+	"github.com/damongolding/immich-kiosk/internal/immich"
+	"github.com/damongolding/immich-kiosk/internal/kiosk"
+	"github.com/damongolding/immich-kiosk/internal/smartcrop"
+)
+
+// smartCropSize returns the size an image is cropped to when smart crop applies.
+// Smart crop only applies to cover fit images shown on their own, and not to live
+// photos, whose video is cropped by the browser, or with smart-zoom, which positions
+// itself on faces.
+func smartCropSize(asset *immich.Asset, requestConfig config.Config) (int, int, bool) {
+	if !requestConfig.SmartCrop || !strings.EqualFold(requestConfig.ImageFit, "cover") {
+		return 0, 0, false
+	}
+
+	if asset.Type != immich.ImageType || (requestConfig.LivePhotos && asset.LivePhotoVideoID != "") {
+		return 0, 0, false
+	}
+
+	if strings.EqualFold(requestConfig.ImageEffect, "smart-zoom") {
+		return 0, 0, false
+	}
+
+	switch requestConfig.Layout {
+	case kiosk.LayoutSplitview, kiosk.LayoutSplitviewLandscape, kiosk.LayoutGrid2x2, kiosk.LayoutTriptych, kiosk.LayoutMosaic:
+		return 0, 0, false
+	}
+
+	width, height := requestConfig.ClientData.Width, requestConfig.ClientData.Height
+	if width <= 0 || height <= 0 {
+		return 0, 0, false
+	}
+
+	return width, height, true
+}
+
+// smartCropImage crops an image to the client's aspect ratio, keeping faces in frame.
+// The area cropped to is cached per asset and client size, so faces are not looked up
+// and the image is not measured again.
+// The original image is returned when smart crop does not apply.
+func smartCropImage(img image.Image, asset *immich.Asset, requestConfig config.Config, metadata requestMetadata, isPrefetch bool) image.Image {
+	width, height, ok := smartCropSize(asset, requestConfig)
+	if !ok {
+		return img
+	}
+
+	cacheKey := cache.SmartCropCacheKey(asset.ID, width, height, requestConfig.UseOriginalImage, requestConfig.FacePrivacyKey())
+
+	if cached, found := cache.Get(cacheKey); found {
+		if rect, isRect := cached.(image.Rectangle); isRect && rect.In(img.Bounds()) {
+			return smartcrop.CropTo(img, rect)
+		}
+	}
+
+	startTime := time.Now()
+
+	if len(asset.People)+len(asset.UnassignedFaces) == 0 {
+		asset.CheckForFaces(metadata.requestID, metadata.deviceID)
+	}
+
+	bounds := img.Bounds()
+	rect := smartcrop.Rect(img, width, height, asset.FaceRects(bounds.Dx(), bounds.Dy()))
+	cropped := smartcrop.CropTo(img, rect)
+
+	logImageProcessing(requestConfig.Kiosk.DebugVerbose, metadata.requestID, metadata.deviceID, isPrefetch, "Smart cropped", startTime)
+
+	cache.Set(cacheKey, rect, requestConfig.Duration, requestConfig.CacheDuration)
+
+	return cropped
+}
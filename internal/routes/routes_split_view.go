@@ -0,0 +1,212 @@
+package routes
+
+import (
+	"errors"
+	"image/color"
+	"math"
+	"slices"
+	"strings"
+
+	"charm.land/log/v2"
+	"github.com/labstack/echo/v5"
+
+	"github.com/damongolding/immich-kiosk/internal/common"
+	"github.com/damongolding/immich-kiosk/internal/config"
+	"github.com/damongolding/immich-kiosk/internal/immich"
+	"github.com/damongolding/immich-kiosk/internal/kiosk"
+	"github.com/damongolding/immich-kiosk/internal/thumbhash"
+	"github.com/damongolding/immich-kiosk/internal/utils"
+)
+
+const (
+	// pairingColourCandidates how many assets the similar-colour strategy compares
+	pairingColourCandidates = 3
+
+	// pairingMaxColourDistance assets with a dominant colour further than this from the
+	// first asset's are not considered similar
+	pairingMaxColourDistance = 120.0
+)
+
+var (
+	errNoSimilarColour = errors.New("no asset with a similar colour")
+	errOrderedBucket   = errors.New("colour pairing skips buckets played in order")
+)
+
+// hasOrderedBucket reports whether any of the buckets is played in order, or keeps its
+// own position, so picking an asset from it moves on even if the asset is not shown.
+func hasOrderedBucket(assets []utils.AssetWithWeighting, albumOrder string) bool {
+	for _, asset := range assets {
+		switch asset.Asset.Type {
+		case kiosk.SourceMemories, kiosk.SourceJourney:
+			return true
+		case kiosk.SourceAlbum:
+			switch strings.ToLower(albumOrder) {
+			case config.AlbumOrderDescending, config.AlbumOrderDesc, config.AlbumOrderNewest,
+				config.AlbumOrderAscending, config.AlbumOrderAsc, config.AlbumOrderOldest:
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// colourDistance returns the distance between two colours using the "redmean"
+// approximation of perceived colour difference.
+func colourDistance(a, b color.RGBA) float64 {
+	redMean := (float64(a.R) + float64(b.R)) / 2
+	dr := float64(a.R) - float64(b.R)
+	dg := float64(a.G) - float64(b.G)
+	db := float64(a.B) - float64(b.B)
+
+	return math.Sqrt((2+redMean/256)*dr*dr + 4*dg*dg + (2+(255-redMean)/256)*db*db)
+}
+
+// pairSplitViewAsset picks the second split view asset using a pairing strategy.
+//
+// Parameters:
+//   - strategy: The pairing strategy to use
+//   - first: The first split view asset
+//   - requestConfig: Configuration settings for the request
+//   - c: Copy of the request context
+//   - isPrefetch: Whether this is a prefetch request
+//   - options: Options used to pick a second asset from the first asset's bucket
+//
+// Returns:
+//   - ViewImageData containing the paired asset
+//   - Error if no asset pairs with the first asset
+func pairSplitViewAsset(strategy string, first common.ViewImageData, requestConfig config.Config, c common.ContextCopy, isPrefetch bool, options common.ViewImageDataOptions) (common.ViewImageData, error) {
+	if strategy == kiosk.PairingSimilarColour {
+		return similarColourAsset(first, requestConfig, c, isPrefetch, options)
+	}
+
+	metadata := requestMetadata{
+		requestID: utils.ColorizeRequestID(c.ResponseHeader.Get(echo.HeaderXRequestID)),
+		deviceID:  c.RequestHeader.Get("kiosk-device-id"),
+		urlString: c.URL.String(),
+	}
+
+	immichAsset := setupImmichAsset(requestConfig, options.ImageOrientation)
+
+	if err := immichAsset.PairedAsset(strategy, first.ImmichAsset, metadata.requestID, metadata.deviceID); err != nil {
+		return common.ViewImageData{}, err
+	}
+
+	markShown(&immichAsset, requestConfig, metadata.deviceID)
+
+	img, err := processImage(&immichAsset, requestConfig, metadata.requestID, metadata.deviceID, isPrefetch)
+	if err != nil {
+		return common.ViewImageData{}, err
+	}
+
+	return buildViewImageData(img, immichAsset, requestConfig, metadata, isPrefetch)
+}
+
+// similarColourAsset picks, from a few assets in the first asset's bucket, the one whose
+// colour is closest to the first asset's. Candidates are compared by their thumbhash's
+// average colour, so only the chosen asset is processed and marked as shown, and the
+// others are put back into the cached lists they were taken from. Buckets played in
+// order are skipped, as picking from them moves their position on.
+func similarColourAsset(first common.ViewImageData, requestConfig config.Config, c common.ContextCopy, isPrefetch bool, options common.ViewImageDataOptions) (common.ViewImageData, error) {
+	metadata := requestMetadata{
+		requestID: utils.ColorizeRequestID(c.ResponseHeader.Get(echo.HeaderXRequestID)),
+		deviceID:  c.RequestHeader.Get("kiosk-device-id"),
+		urlString: c.URL.String(),
+	}
+
+	if options.RelativeAssetWanted {
+		handleRelativeAssetConfig(&requestConfig, options)
+	}
+
+	firstColour, ok := thumbhashColour(first.ImmichAsset)
+	if !ok {
+		firstColour = first.ImageDominantColor
+	}
+
+	bucket := setupImmichAsset(requestConfig, options.ImageOrientation)
+
+	assets, err := gatherAssetBuckets(&bucket, requestConfig, metadata.requestID, metadata.deviceID)
+	if err != nil {
+		return common.ViewImageData{}, err
+	}
+
+	if hasOrderedBucket(assets, requestConfig.AlbumOrder) {
+		return common.ViewImageData{}, errOrderedBucket
+	}
+
+	candidates := make([]immich.Asset, 0, pairingColourCandidates)
+
+	// put back the candidates that are not chosen
+	defer func() {
+		for i := range candidates {
+			if putBackErr := candidates[i].PutBack(); putBackErr != nil {
+				log.Error(metadata.requestID+" Failed to put back colour pairing candidate", "assetID", candidates[i].ID, "err", putBackErr)
+			}
+		}
+	}()
+
+	var best immich.Asset
+	bestIndex := -1
+	bestDistance := pairingMaxColourDistance
+
+	for range pairingColourCandidates {
+		candidate := setupImmichAsset(requestConfig, options.ImageOrientation)
+
+		if err = pickAsset(&candidate, assets, requestConfig, metadata.requestID, metadata.deviceID, isPrefetch); err != nil {
+			return common.ViewImageData{}, err
+		}
+
+		// the same asset as the first one is not put back, as it is already shown
+		if candidate.ID == first.ImmichAsset.ID {
+			continue
+		}
+
+		candidates = append(candidates, candidate)
+
+		// videos would need downloading before they could be shown
+		if candidate.Type != immich.ImageType {
+			continue
+		}
+
+		colour, hasColour := thumbhashColour(candidate)
+		if !hasColour {
+			continue
+		}
+
+		if distance := colourDistance(firstColour, colour); distance <= bestDistance {
+			bestIndex, bestDistance = len(candidates)-1, distance
+		}
+	}
+
+	if bestIndex < 0 {
+		return common.ViewImageData{}, errNoSimilarColour
+	}
+
+	best = candidates[bestIndex]
+	candidates = slices.Delete(candidates, bestIndex, bestIndex+1)
+
+	markShown(&best, requestConfig, metadata.deviceID)
+
+	img, err := processImage(&best, requestConfig, metadata.requestID, metadata.deviceID, isPrefetch)
+	if err != nil {
+		return common.ViewImageData{}, err
+	}
+
+	return buildViewImageData(img, best, requestConfig, metadata, isPrefetch)
+}
+
+// thumbhashColour returns the average colour of an asset's thumbhash, and false if it has
+// no valid thumbhash.
+func thumbhashColour(asset immich.Asset) (color.RGBA, bool) {
+	hash, err := thumbhash.Parse(asset.Thumbhash)
+	if err != nil {
+		return color.RGBA{}, false
+	}
+
+	average, err := thumbhash.AverageColor(hash)
+	if err != nil {
+		return color.RGBA{}, false
+	}
+
+	return color.RGBA{R: average.R, G: average.G, B: average.B, A: 255}, true
+}
@@ -0,0 +1,178 @@
+package routes
+
+import (
+	"context"
+	"strings"
+
+	"charm.land/log/v2"
+	"github.com/labstack/echo/v5"
+
+	"github.com/damongolding/immich-kiosk/internal/common"
+	"github.com/damongolding/immich-kiosk/internal/config"
+	"github.com/damongolding/immich-kiosk/internal/immich"
+	"github.com/damongolding/immich-kiosk/internal/kiosk"
+	"github.com/damongolding/immich-kiosk/internal/seen"
+	"github.com/damongolding/immich-kiosk/internal/story"
+	"github.com/damongolding/immich-kiosk/internal/utils"
+)
+
+const (
+	// minStoryAssets albums and memories with fewer assets are not told as stories
+	minStoryAssets = 2
+
+	// storyPeopleLimit the most people named on a story's title card
+	storyPeopleLimit = 5
+)
+
+// storyDates formats the span of dates a story covers using the image date format.
+func storyDates(s story.Story, requestConfig config.Config) string {
+	if s.Start.IsZero() {
+		return ""
+	}
+
+	layout := utils.DateToLayout(requestConfig.ImageDateFormat)
+	if layout == "" {
+		layout = config.DefaultDateLayout
+	}
+
+	start := s.Start.Format(layout)
+	end := s.End.Format(layout)
+
+	if start == end {
+		return start
+	}
+
+	return start + " – " + end
+}
+
+// startStory turns the view into a story's title card when its asset was picked from an
+// album or memory the device has not been told recently. The story starts once the view
+// is served, see serveStory.
+//
+// Parameters:
+//   - viewData: The view, which is turned into a title card if a story starts
+//   - requestConfig: Configuration settings for the request
+//   - requestID: ID used for tracking API call chain
+//   - deviceID: ID of the device making the request
+func startStory(viewData *common.ViewData, requestConfig config.Config, requestID, deviceID string) {
+	if len(viewData.Assets) != 1 || viewData.Assets[0].ImmichAsset.Type != immich.ImageType {
+		return
+	}
+
+	asset := viewData.Assets[0].ImmichAsset
+
+	var s story.Story
+
+	switch asset.Bucket {
+	case kiosk.SourceAlbum:
+		albumID, _, _ := strings.Cut(asset.BucketID, "@")
+		if albumID == "" || story.Told(deviceID, albumID) {
+			return
+		}
+
+		albumStory, err := asset.AlbumStory(albumID, requestID, deviceID)
+		if err != nil {
+			log.Error(requestID+" Failed to get album story", "albumID", albumID, "err", err)
+			return
+		}
+		s = albumStory
+
+	case kiosk.SourceMemories:
+		memoryStory, ok := asset.MemoryStory(requestID)
+		if !ok || story.Told(deviceID, memoryStory.ID) {
+			return
+		}
+		s = memoryStory
+
+	default:
+		return
+	}
+
+	if len(s.AssetIDs) < minStoryAssets {
+		return
+	}
+
+	log.Debug(requestID+" Starting story", "title", s.Title, "assets", len(s.AssetIDs))
+
+	people := s.People
+	if len(people) > storyPeopleLimit {
+		people = people[:storyPeopleLimit]
+	}
+
+	viewData.Story = &s
+	viewData.StoryCard = &common.StoryCard{
+		Title:  s.Title,
+		Dates:  storyDates(s, requestConfig),
+		People: people,
+	}
+	viewData.AssetDuration = requestConfig.StoryDuration
+}
+
+// nextStoryViewData returns a view of the next asset of the story playing on the device.
+// Assets that can no longer be retrieved are skipped. The story advances past the asset
+// once the view is served, see serveStory.
+//
+// Returns:
+//   - ViewData containing the story's next asset
+//   - false if no story is playing or the story has ended
+func nextStoryViewData(ctx context.Context, viewData common.ViewData, requestConfig config.Config, c common.ContextCopy, isPrefetch bool) (common.ViewData, bool) {
+	metadata := requestMetadata{
+		requestID: utils.ColorizeRequestID(c.ResponseHeader.Get(echo.HeaderXRequestID)),
+		deviceID:  c.RequestHeader.Get("kiosk-device-id"),
+		urlString: c.URL.String(),
+	}
+
+	for {
+		assetID, ok := story.Peek(metadata.deviceID)
+		if !ok {
+			return viewData, false
+		}
+
+		imageData, err := storyAsset(ctx, assetID, requestConfig, metadata, isPrefetch)
+		if err != nil {
+			log.Error(metadata.requestID+" Failed to get story asset", "assetID", assetID, "err", err)
+			story.Advance(metadata.deviceID, assetID)
+			continue
+		}
+
+		viewData.Assets = append(viewData.Assets, imageData)
+		viewData.AssetDuration = requestConfig.StoryDuration
+		viewData.StoryAssetID = assetID
+
+		return viewData, true
+	}
+}
+
+// storyAsset retrieves and prepares a single asset of a story for display.
+func storyAsset(ctx context.Context, assetID string, requestConfig config.Config, metadata requestMetadata, isPrefetch bool) (common.ViewImageData, error) {
+	asset := immich.New(ctx, requestConfig)
+	asset.ID = assetID
+
+	if err := asset.AssetInfo(metadata.requestID, metadata.deviceID); err != nil {
+		return common.ViewImageData{}, err
+	}
+
+	asset.AddRatio()
+
+	seen.Mark(seen.Key(metadata.deviceID, requestConfig.NoRepeatGroup), asset.ID)
+
+	img, err := processImage(&asset, requestConfig, metadata.requestID, metadata.deviceID, isPrefetch)
+	if err != nil {
+		return common.ViewImageData{}, err
+	}
+
+	return buildViewImageData(img, asset, requestConfig, metadata, isPrefetch)
+}
+
+// serveStory starts the story a view is the title card of, or advances the story playing
+// on the device past the view's asset. It is called once the view is served, so views
+// that are prefetched but never shown leave the story where it was.
+func serveStory(viewData common.ViewData, deviceID string) {
+	if viewData.Story != nil {
+		story.Start(deviceID, *viewData.Story)
+	}
+
+	if viewData.StoryAssetID != "" {
+		story.Advance(deviceID, viewData.StoryAssetID)
+	}
+}
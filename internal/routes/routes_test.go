@@ -1,19 +1,30 @@
 package routes
 
 import (
+	"image"
+	"image/color"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/damongolding/immich-kiosk/internal/cache"
 	"github.com/damongolding/immich-kiosk/internal/common"
 	"github.com/damongolding/immich-kiosk/internal/config"
+	"github.com/damongolding/immich-kiosk/internal/imagestore"
+	"github.com/damongolding/immich-kiosk/internal/immich"
+	"github.com/damongolding/immich-kiosk/internal/kiosk"
+	"github.com/damongolding/immich-kiosk/internal/playback"
+	"github.com/damongolding/immich-kiosk/internal/story"
 	"github.com/damongolding/immich-kiosk/internal/utils"
 	"github.com/damongolding/immich-kiosk/internal/video"
+	"github.com/damongolding/immich-kiosk/internal/warmup"
 	"github.com/labstack/echo/v5"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // TestRawImage tests the NewRawImage handler function.
@@ -238,3 +249,421 @@ func TestShouldSkipBlur(t *testing.T) {
 		})
 	}
 }
+
+// TestColourDistance tests that similar colours are closer than different ones
+func TestColourDistance(t *testing.T) {
+	red := color.RGBA{R: 200, G: 30, B: 30, A: 255}
+	darkRed := color.RGBA{R: 180, G: 20, B: 25, A: 255}
+	blue := color.RGBA{R: 30, G: 30, B: 200, A: 255}
+
+	assert.Zero(t, colourDistance(red, red))
+	assert.InDelta(t, colourDistance(red, blue), colourDistance(blue, red), 0.0001)
+	assert.Less(t, colourDistance(red, darkRed), pairingMaxColourDistance)
+	assert.Greater(t, colourDistance(red, blue), pairingMaxColourDistance)
+}
+
+// TestThumbhashColour tests similar colour pairing reads colours from thumbhashes
+func TestThumbhashColour(t *testing.T) {
+	colour, ok := thumbhashColour(immich.Asset{Thumbhash: "1QcSHQRnh493V4dIh4eXh1h4kJUI"})
+	require.True(t, ok)
+	assert.Equal(t, uint8(255), colour.A)
+	assert.NotEqual(t, color.RGBA{A: 255}, colour)
+
+	_, ok = thumbhashColour(immich.Asset{})
+	assert.False(t, ok, "assets without a thumbhash have no colour")
+
+	_, ok = thumbhashColour(immich.Asset{Thumbhash: "not a thumbhash"})
+	assert.False(t, ok)
+}
+
+// TestHasOrderedBucket tests similar colour pairing skips buckets whose position moves on
+// when an asset is picked
+func TestHasOrderedBucket(t *testing.T) {
+	bucket := func(source kiosk.Source) []utils.AssetWithWeighting {
+		return []utils.AssetWithWeighting{
+			{Asset: utils.WeightedAsset{Type: kiosk.SourcePerson, ID: "person-1"}, Weight: 1},
+			{Asset: utils.WeightedAsset{Type: source, ID: "bucket-1"}, Weight: 1},
+		}
+	}
+
+	assert.False(t, hasOrderedBucket(bucket(kiosk.SourceTag), config.AlbumOrderRandom))
+	assert.False(t, hasOrderedBucket(bucket(kiosk.SourceAlbum), config.AlbumOrderRandom))
+	assert.True(t, hasOrderedBucket(bucket(kiosk.SourceAlbum), "Newest"))
+	assert.True(t, hasOrderedBucket(bucket(kiosk.SourceMemories), config.AlbumOrderRandom))
+	assert.True(t, hasOrderedBucket(bucket(kiosk.SourceJourney), config.AlbumOrderRandom))
+}
+
+// TestLayoutOrientation tests which orientation of asset fills each layout's tiles
+func TestLayoutOrientation(t *testing.T) {
+	tests := []struct {
+		layout string
+		width  int
+		height int
+		want   immich.ImageOrientation
+	}{
+		{kiosk.LayoutGrid2x2, 1920, 1080, immich.LandscapeOrientation},
+		{kiosk.LayoutGrid2x2, 1080, 1920, immich.PortraitOrientation},
+		{kiosk.LayoutTriptych, 1920, 1080, immich.PortraitOrientation},
+		{kiosk.LayoutTriptych, 1080, 1920, immich.LandscapeOrientation},
+		{kiosk.LayoutMosaic, 1920, 1080, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.layout, func(t *testing.T) {
+			assert.Equal(t, tt.want, layoutOrientation(tt.layout, tt.width, tt.height))
+		})
+	}
+
+	assert.Equal(t, 4, layoutAssetCount(kiosk.LayoutGrid2x2))
+	assert.Equal(t, 3, layoutAssetCount(kiosk.LayoutTriptych))
+	assert.Equal(t, 5, layoutAssetCount(kiosk.LayoutMosaic))
+}
+
+// TestStoryDates tests the date span shown on a story's title card
+func TestStoryDates(t *testing.T) {
+	requestConfig := config.Config{ImageDateFormat: "YYYY-MM-DD"}
+
+	start := time.Date(2024, 7, 1, 10, 0, 0, 0, time.UTC)
+
+	assert.Empty(t, storyDates(story.Story{}, requestConfig))
+	assert.Equal(t, "2024-07-01", storyDates(story.Story{Start: start, End: start.Add(time.Hour)}, requestConfig))
+	assert.Equal(t, "2024-07-01 – 2024-07-04", storyDates(story.Story{Start: start, End: start.AddDate(0, 0, 3)}, requestConfig))
+}
+
+// TestServeStory tests stories only start and advance once their views are served
+func TestServeStory(t *testing.T) {
+	deviceID := "device-serve-story"
+	t.Cleanup(func() { story.End(deviceID) })
+
+	card := common.ViewData{Story: &story.Story{ID: "album-serve", AssetIDs: []string{"a", "b"}}}
+	assert.False(t, story.Playing(deviceID), "a prefetched title card does not start its story")
+
+	serveStory(card, deviceID)
+	assert.True(t, story.Playing(deviceID))
+
+	next, _ := story.Peek(deviceID)
+	assert.Equal(t, "a", next)
+
+	serveStory(common.ViewData{StoryAssetID: "a"}, deviceID)
+	next, _ = story.Peek(deviceID)
+	assert.Equal(t, "b", next, "serving a story asset advances the story")
+
+	serveStory(common.ViewData{StoryAssetID: "a"}, deviceID)
+	next, _ = story.Peek(deviceID)
+	assert.Equal(t, "b", next, "serving an asset again does not skip the next one")
+}
+
+// TestSmartCropSize tests when images are smart cropped and to what size
+func TestSmartCropSize(t *testing.T) {
+	image := &immich.Asset{Type: immich.ImageType}
+	livePhoto := &immich.Asset{Type: immich.ImageType, LivePhotoVideoID: "video"}
+
+	cover := config.Config{SmartCrop: true, ImageFit: "cover", Layout: "single"}
+	cover.ClientData.Width = 1080
+	cover.ClientData.Height = 1920
+
+	tests := []struct {
+		name   string
+		asset  *immich.Asset
+		modify func(c *config.Config)
+		want   bool
+	}{
+		{name: "cover fit single image", asset: image, want: true},
+		{name: "smart crop off", asset: image, modify: func(c *config.Config) { c.SmartCrop = false }},
+		{name: "contain fit", asset: image, modify: func(c *config.Config) { c.ImageFit = "contain" }},
+		{name: "smart zoom", asset: image, modify: func(c *config.Config) { c.ImageEffect = "smart-zoom" }},
+		{name: "split view", asset: image, modify: func(c *config.Config) { c.Layout = kiosk.LayoutSplitview }},
+		{name: "live photo", asset: livePhoto, modify: func(c *config.Config) { c.LivePhotos = true }},
+		{name: "live photo with live photos off", asset: livePhoto, want: true},
+		{name: "unknown client size", asset: image, modify: func(c *config.Config) { c.ClientData.Width = 0 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requestConfig := cover
+			if tt.modify != nil {
+				tt.modify(&requestConfig)
+			}
+
+			width, height, ok := smartCropSize(tt.asset, requestConfig)
+			assert.Equal(t, tt.want, ok)
+			if tt.want {
+				assert.Equal(t, 1080, width)
+				assert.Equal(t, 1920, height)
+			}
+		})
+	}
+}
+
+// TestSmartCropImageFacePrivacy tests crops cached without face privacy are not used once it is on
+func TestSmartCropImageFacePrivacy(t *testing.T) {
+	cache.Initialize()
+	t.Cleanup(cache.Flush)
+
+	asset := &immich.Asset{
+		ID:   "smart-crop-privacy",
+		Type: immich.ImageType,
+		People: []immich.Person{{
+			Faces: []immich.Face{{ImageWidth: 200, ImageHeight: 100, BoundingBoxX1: 90, BoundingBoxY1: 40, BoundingBoxX2: 110, BoundingBoxY2: 60}},
+		}},
+	}
+
+	requestConfig := config.Config{SmartCrop: true, ImageFit: "cover", Layout: "single", Duration: 60}
+	requestConfig.ClientData.Width = 100
+	requestConfig.ClientData.Height = 100
+
+	solid := func(c color.RGBA) image.Image {
+		img := image.NewRGBA(image.Rect(0, 0, 200, 100))
+		for y := range 100 {
+			for x := range 200 {
+				img.SetRGBA(x, y, c)
+			}
+		}
+		return img
+	}
+
+	red := color.RGBA{R: 255, A: 255}
+	blue := color.RGBA{B: 255, A: 255}
+
+	cropped := smartCropImage(solid(red), asset, requestConfig, requestMetadata{}, false)
+	assert.Equal(t, red, color.RGBAModel.Convert(cropped.At(50, 50)))
+
+	requestConfig.FacePrivacy = kiosk.FacePrivacyBlur
+
+	cropped = smartCropImage(solid(blue), asset, requestConfig, requestMetadata{}, false)
+	assert.Equal(t, blue, color.RGBAModel.Convert(cropped.At(50, 50)), "crop cached without face privacy was used")
+}
+
+// TestSmartCropImageCachesRect tests only the area cropped to is cached, and is used to
+// crop the image again
+func TestSmartCropImageCachesRect(t *testing.T) {
+	cache.Initialize()
+	t.Cleanup(cache.Flush)
+
+	asset := &immich.Asset{
+		ID:   "smart-crop-rect",
+		Type: immich.ImageType,
+		People: []immich.Person{{
+			Faces: []immich.Face{{ImageWidth: 200, ImageHeight: 100, BoundingBoxX1: 150, BoundingBoxY1: 40, BoundingBoxX2: 170, BoundingBoxY2: 60}},
+		}},
+	}
+
+	requestConfig := config.Config{SmartCrop: true, ImageFit: "cover", Layout: "single", Duration: 60}
+	requestConfig.ClientData.Width = 100
+	requestConfig.ClientData.Height = 100
+
+	red := color.RGBA{R: 255, A: 255}
+
+	img := image.NewRGBA(image.Rect(0, 0, 200, 100))
+	for y := range 100 {
+		for x := 150; x < 200; x++ {
+			img.SetRGBA(x, y, red)
+		}
+	}
+
+	cropped := smartCropImage(img, asset, requestConfig, requestMetadata{}, false)
+	assert.Equal(t, 100, cropped.Bounds().Dx())
+	assert.Equal(t, 100, cropped.Bounds().Dy())
+
+	cached, found := cache.Get(cache.SmartCropCacheKey(asset.ID, 100, 100, false, requestConfig.FacePrivacyKey()))
+	require.True(t, found)
+	assert.Equal(t, image.Rect(100, 0, 200, 100), cached, "the crop area should be cached, not the image")
+
+	asset.People = nil
+
+	cropped = smartCropImage(img, asset, requestConfig, requestMetadata{}, false)
+	assert.Equal(t, red, color.RGBAModel.Convert(cropped.At(cropped.Bounds().Min.X+75, cropped.Bounds().Min.Y+50)), "the cached crop area should be used")
+}
+
+// TestStoredImage tests stored images are served with caching headers and range support
+func TestStoredImage(t *testing.T) {
+	t.Cleanup(imagestore.Flush)
+
+	e := echo.New()
+	e.GET("/img/:name", StoredImage)
+
+	imgURL := imagestore.Put([]byte("0123456789"), kiosk.MimeTypeJpeg)
+	name, _ := imagestore.NameFromURL(imgURL)
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, imgURL, nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "0123456789", rec.Body.String())
+	assert.Equal(t, imagestore.ETag(name), rec.Header().Get("ETag"))
+	assert.Equal(t, kiosk.MimeTypeJpeg, rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Header().Get("Cache-Control"), "immutable")
+
+	req := httptest.NewRequest(http.MethodGet, imgURL, nil)
+	req.Header.Set("If-None-Match", imagestore.ETag(name))
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+
+	req = httptest.NewRequest(http.MethodGet, imgURL, nil)
+	req.Header.Set("Range", "bytes=2-4")
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusPartialContent, rec.Code)
+	assert.Equal(t, "234", rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, imagestore.URLPrefix+strings.Repeat("0", 64)+".jpg", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestNegotiateMimeType(t *testing.T) {
+	const chrome = "image/avif,image/webp,image/apng,image/svg+xml,image/*,*/*;q=0.8"
+
+	tests := []struct {
+		name   string
+		format string
+		accept string
+		want   string
+	}{
+		{name: "auto with webp", format: kiosk.ImageFormatAuto, accept: chrome, want: kiosk.MimeTypeWebp},
+		{name: "auto without webp", format: kiosk.ImageFormatAuto, accept: "image/*,*/*;q=0.8", want: kiosk.MimeTypeJpeg},
+		{name: "avif", format: kiosk.ImageFormatAvif, accept: chrome, want: kiosk.MimeTypeAvif},
+		{name: "avif falls back to webp", format: kiosk.ImageFormatAvif, accept: "image/webp,*/*", want: kiosk.MimeTypeWebp},
+		{name: "webp refused", format: kiosk.ImageFormatWebp, accept: "image/webp;q=0,*/*", want: kiosk.MimeTypeJpeg},
+		{name: "jpeg", format: kiosk.ImageFormatJpeg, accept: chrome, want: kiosk.MimeTypeJpeg},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, negotiateMimeType(tt.format, tt.accept))
+		})
+	}
+}
+
+func TestStoredImageVariant(t *testing.T) {
+	t.Cleanup(imagestore.Flush)
+
+	e := echo.New()
+	e.GET("/img/:name", StoredImage)
+
+	data, mimeType, err := utils.EncodeImage(image.NewRGBA(image.Rect(0, 0, 8, 8)), kiosk.MimeTypeJpeg, 0)
+	assert.NoError(t, err)
+
+	imgURL := imagestore.Put(data, mimeType) + imageFormatQuery(config.Config{ImageFormat: kiosk.ImageFormatWebp, ImageQuality: 70})
+
+	req := httptest.NewRequest(http.MethodGet, imgURL, nil)
+	req.Header.Set("Accept", "image/webp,*/*")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, kiosk.MimeTypeWebp, rec.Header().Get("Content-Type"))
+	assert.Equal(t, "Accept", rec.Header().Get("Vary"))
+	assert.Contains(t, rec.Header().Get("ETag"), "-webp-70")
+
+	req = httptest.NewRequest(http.MethodGet, imgURL, nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, kiosk.MimeTypeJpeg, rec.Header().Get("Content-Type"), "browsers that do not accept webp get the jpeg")
+}
+
+// TestStoredImageQuality tests images are stored at a high quality and only encoded at
+// the device's quality when they are sent
+func TestStoredImageQuality(t *testing.T) {
+	t.Cleanup(imagestore.Flush)
+
+	e := echo.New()
+	e.GET("/img/:name", StoredImage)
+
+	assert.Empty(t, imageFormatQuery(config.Config{ImageFormat: kiosk.ImageFormatJpeg}))
+	assert.Equal(t, "?quality=40", imageFormatQuery(config.Config{ImageFormat: kiosk.ImageFormatJpeg, ImageQuality: 40}))
+
+	src := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	for y := range 64 {
+		for x := range 64 {
+			src.SetNRGBA(x, y, color.NRGBA{R: uint8(x * 4), G: uint8(y * 4), B: uint8(x ^ y), A: 255})
+		}
+	}
+
+	data, mimeType, err := utils.EncodeImage(src, kiosk.MimeTypeJpeg, 0)
+	require.NoError(t, err)
+
+	imgURL := imagestore.Put(data, mimeType) + imageFormatQuery(config.Config{ImageFormat: kiosk.ImageFormatJpeg, ImageQuality: 40})
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, imgURL, nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, kiosk.MimeTypeJpeg, rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Header().Get("ETag"), "-jpg-40")
+	assert.Less(t, rec.Body.Len(), len(data), "the image is sent at the device's quality")
+	assert.Empty(t, rec.Header().Get("Vary"), "the format does not depend on the browser")
+}
+
+func TestInvalidateCache(t *testing.T) {
+	cache.Initialize()
+	t.Cleanup(cache.Flush)
+
+	baseConfig := config.New()
+	e := echo.New()
+	e.POST("/cache/invalidate", InvalidateCache(baseConfig, common.New()))
+
+	cache.Set(cache.ViewCacheKey("/image", "device-1"), []string{"view"}, 60, 0)
+	cache.Set(cache.ViewCacheKey("/image", "device-2"), []string{"view"}, 60, 0)
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/cache/invalidate", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code, "invalidating requires a filter")
+
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/cache/invalidate?category=unknown", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/cache/invalidate?category=view&deviceID=device-1", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"count":1`)
+	assert.Equal(t, 1, cache.ItemCount())
+}
+
+func TestPlaybackTargetDevice(t *testing.T) {
+	target := func(query string) (string, string, error) {
+		req := httptest.NewRequest(http.MethodPost, "/playback/reset?"+query, nil)
+		c := echo.New().NewContext(req, httptest.NewRecorder())
+		return playbackTarget(c, "requesting-device", config.Config{})
+	}
+
+	deviceID, bucketID, err := target("bucket=album-1")
+	require.NoError(t, err)
+	assert.Equal(t, "requesting-device", deviceID)
+	assert.Equal(t, "album-1", bucketID)
+
+	_, _, err = target("deviceID=made-up-device")
+	var httpErr *echo.HTTPError
+	require.ErrorAs(t, err, &httpErr)
+	assert.Equal(t, http.StatusNotFound, httpErr.Code)
+
+	playback.Set("known-device", "album-1", "asset-1")
+
+	deviceID, _, err = target("deviceID=known-device")
+	require.NoError(t, err)
+	assert.Equal(t, "known-device", deviceID)
+}
+
+func TestProfileRequestData(t *testing.T) {
+	baseConfig := config.New()
+	baseConfig.Duration = 60
+
+	profile := warmup.Profile{
+		DeviceID: "device-1",
+		Method:   http.MethodPost,
+		URL:      "/asset/new?duration=90",
+		Form:     url.Values{"client": {"lounge"}, "sleep_end": {"0700"}},
+	}
+
+	requestData, err := profileRequestData(echo.New(), baseConfig, profile)
+	require.NoError(t, err)
+
+	assert.Equal(t, "device-1", requestData.DeviceID)
+	assert.Equal(t, "lounge", requestData.ClientName)
+	assert.Equal(t, "0700", requestData.RequestConfig.SleepEnd)
+	assert.Equal(t, 90, requestData.RequestConfig.Duration, "the URL query is applied")
+	assert.Equal(t, 60, baseConfig.Duration, "the base config is not changed")
+}
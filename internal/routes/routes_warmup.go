@@ -0,0 +1,133 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"charm.land/log/v2"
+	"github.com/labstack/echo/v5"
+
+	"github.com/damongolding/immich-kiosk/internal/common"
+	"github.com/damongolding/immich-kiosk/internal/config"
+	"github.com/damongolding/immich-kiosk/internal/immich"
+	"github.com/damongolding/immich-kiosk/internal/utils"
+	"github.com/damongolding/immich-kiosk/internal/warmup"
+)
+
+// WarmUpCache fills the cache for every known device at startup, whenever
+// kiosk.warm_up.schedule is due and kiosk.warm_up.before_sleep_end minutes before each
+// device's sleep_end, until ctx is done.
+func WarmUpCache(ctx context.Context, baseConfig *config.Config) {
+	e := echo.New()
+
+	warmup.Run(ctx, func(now time.Time, atStartup bool) {
+		warmUp := baseConfig.Kiosk.WarmUp
+
+		scheduled := atStartup
+		if !scheduled && warmUp.Schedule != "" {
+			schedule, err := warmup.ParseSchedule(warmUp.Schedule)
+			scheduled = err == nil && warmup.Due(schedule, now)
+		}
+
+		for _, profile := range warmup.Profiles() {
+			requestData, err := profileRequestData(e, baseConfig, profile)
+			if err != nil {
+				log.Error("Failed to read device profile", "deviceID", profile.DeviceID, "err", err)
+				continue
+			}
+
+			lead := time.Duration(warmUp.BeforeSleepEnd) * time.Minute
+			if !scheduled && !warmup.DueBeforeSleepEnd(requestData.RequestConfig.SleepEnd, lead, now) {
+				continue
+			}
+
+			warmUpDevice(ctx, requestData)
+		}
+	})
+}
+
+// rememberDevice records a device's asset request so its cache can be warmed later.
+func rememberDevice(c *echo.Context, baseConfig *config.Config, deviceID string) {
+	if !baseConfig.Kiosk.WarmUp.Enabled {
+		return
+	}
+
+	// the parsed form also holds the URL query, so only the body is kept
+	if _, err := c.FormValues(); err != nil {
+		return
+	}
+
+	warmup.Remember(deviceID, c.Request().Method, *c.Request().URL, c.Request().PostForm)
+}
+
+// profileRequestData replays a device's remembered request to build its request data,
+// as if the device had just made it.
+func profileRequestData(e *echo.Echo, baseConfig *config.Config, profile warmup.Profile) (*common.RouteRequestData, error) {
+	req, err := http.NewRequest(profile.Method, profile.URL, strings.NewReader(profile.Form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+	req.Header.Set("kiosk-device-id", profile.DeviceID)
+
+	rec := httptest.NewRecorder()
+	rec.Header().Set(echo.HeaderXRequestID, utils.GenerateUUID())
+
+	c := e.NewContext(req, rec)
+
+	requestData, err := InitializeRequestData(c, baseConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if requestData == nil {
+		return nil, errors.New("request was not accepted")
+	}
+
+	return requestData, nil
+}
+
+// warmUpDevice fills the cache with the album, people, tag and memories lists and the
+// bucket counts a device's requests use. No asset is prefetched, as picking one would
+// mark it as seen, deal it from the device's deck and advance its playback while the
+// device sleeps.
+func warmUpDevice(ctx context.Context, requestData *common.RouteRequestData) {
+	requestConfig := requestData.RequestConfig
+	requestID := requestData.RequestID
+	deviceID := requestData.DeviceID
+
+	startTime := time.Now()
+	log.Debug(requestID+" Warming up cache", "deviceID", deviceID)
+
+	immichAsset := immich.New(ctx, requestConfig)
+
+	if _, err := immichAsset.AllAlbums(requestID, deviceID); err != nil {
+		log.Error(requestID+" Warm up albums", "deviceID", deviceID, "err", err)
+	}
+
+	if _, err := immichAsset.AllNamedPeople(requestID, deviceID); err != nil {
+		log.Error(requestID+" Warm up people", "deviceID", deviceID, "err", err)
+	}
+
+	if _, _, err := immichAsset.AllTags(requestID, deviceID); err != nil {
+		log.Error(requestID+" Warm up tags", "deviceID", deviceID, "err", err)
+	}
+
+	if requestConfig.Memories {
+		if _, _, err := immichAsset.Memories(requestID, deviceID); err != nil {
+			log.Error(requestID+" Warm up memories", "deviceID", deviceID, "err", err)
+		}
+	}
+
+	bucketAsset := immich.New(ctx, requestConfig)
+	if _, err := gatherAssetBuckets(&bucketAsset, requestConfig, requestID, deviceID); err != nil {
+		log.Error(requestID+" Warm up asset buckets", "deviceID", deviceID, "err", err)
+	}
+
+	log.Info("Warmed up cache", "deviceID", deviceID, "took", time.Since(startTime).Round(time.Millisecond))
+}
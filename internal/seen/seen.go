@@ -0,0 +1,210 @@
+// Package seen keeps a server side record of when each asset was last shown,
+// per device or per group of devices.
+//
+// Unlike the client history, which is limited to kiosk.HistoryLimit assets, the
+// record covers thousands of assets and days of playback. It is used to stop assets
+// repeating within a window and by the least-shown weighting strategy.
+//
+// Shown assets are always tracked in memory. Once Initialize has been called they are
+// also written to disk, shortly after changing, so the record survives restarts.
+package seen
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"charm.land/log/v2"
+)
+
+const (
+	// DefaultPath is the file the seen record is persisted to
+	DefaultPath = "./kiosk-data/seen.json"
+
+	// MaxWindow is the longest no-repeat window supported. Older entries are pruned
+	MaxWindow = 365 * 24 * time.Hour
+
+	// maxAssetsPerKey caps how many assets are remembered per device or group
+	maxAssetsPerKey = 50000
+
+	// saveDelay batches changes into a single write
+	saveDelay = 30 * time.Second
+)
+
+var (
+	mu    sync.RWMutex
+	shown = map[string]map[string]time.Time{}
+
+	path      = DefaultPath
+	persist   = false
+	saveTimer *time.Timer
+)
+
+// Key returns the key a device's record is stored under.
+// Devices in the same group share a record.
+func Key(deviceID, group string) string {
+	if group != "" {
+		return "group:" + group
+	}
+	return deviceID
+}
+
+// Initialize enables persisting the record to disk and loads any previously persisted
+// record from filePath. An empty filePath uses DefaultPath.
+func Initialize(filePath string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if filePath != "" {
+		path = filePath
+	}
+
+	persist = true
+	shown = map[string]map[string]time.Time{}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("reading seen assets: %w", err)
+	}
+
+	if err = json.Unmarshal(data, &shown); err != nil {
+		shown = map[string]map[string]time.Time{}
+		return fmt.Errorf("parsing seen assets: %w", err)
+	}
+
+	prune()
+
+	return nil
+}
+
+// Mark records that an asset has just been shown.
+func Mark(key, assetID string) {
+	if key == "" || assetID == "" {
+		return
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	assets, ok := shown[key]
+	if !ok {
+		assets = map[string]time.Time{}
+		shown[key] = assets
+	}
+
+	if _, exists := assets[assetID]; !exists && len(assets) >= maxAssetsPerKey {
+		forgetOldest(assets)
+	}
+
+	assets[assetID] = time.Now()
+
+	scheduleSave()
+}
+
+// LastShown returns when an asset was last shown, or the zero time if it has not been.
+func LastShown(key, assetID string) time.Time {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	return shown[key][assetID]
+}
+
+// Within reports whether an asset has been shown within window.
+func Within(key, assetID string, window time.Duration) bool {
+	last := LastShown(key, assetID)
+	return !last.IsZero() && time.Since(last) < window
+}
+
+// Flush writes any pending changes to disk immediately.
+func Flush() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if saveTimer != nil {
+		saveTimer.Stop()
+		saveTimer = nil
+	}
+
+	save()
+}
+
+// forgetOldest removes the asset that was shown longest ago.
+func forgetOldest(assets map[string]time.Time) {
+	var oldestID string
+	var oldest time.Time
+
+	for id, t := range assets {
+		if oldestID == "" || t.Before(oldest) {
+			oldestID, oldest = id, t
+		}
+	}
+
+	delete(assets, oldestID)
+}
+
+// prune removes entries older than MaxWindow. mu must be held.
+func prune() {
+	cutoff := time.Now().Add(-MaxWindow)
+	for key, assets := range shown {
+		for id, t := range assets {
+			if t.Before(cutoff) {
+				delete(assets, id)
+			}
+		}
+		if len(assets) == 0 {
+			delete(shown, key)
+		}
+	}
+}
+
+// scheduleSave queues a write to disk if one is not already pending. mu must be held.
+func scheduleSave() {
+	if !persist || saveTimer != nil {
+		return
+	}
+
+	saveTimer = time.AfterFunc(saveDelay, func() {
+		mu.Lock()
+		defer mu.Unlock()
+
+		saveTimer = nil
+		save()
+	})
+}
+
+// save writes the record to disk. mu must be held.
+func save() {
+	if !persist {
+		return
+	}
+
+	prune()
+
+	data, err := json.Marshal(shown)
+	if err != nil {
+		log.Error("Failed to marshal seen assets", "err", err)
+		return
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		log.Error("Failed to create seen assets directory", "err", err)
+		return
+	}
+
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0o600); err != nil {
+		log.Error("Failed to write seen assets", "err", err)
+		return
+	}
+
+	if err = os.Rename(tmp, path); err != nil {
+		log.Error("Failed to save seen assets", "err", err)
+	}
+}
@@ -0,0 +1,48 @@
+package seen
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKey(t *testing.T) {
+	assert.Equal(t, "device", Key("device", ""))
+	assert.Equal(t, "group:lounge", Key("device", "lounge"))
+}
+
+func TestLastShown(t *testing.T) {
+	assert.True(t, LastShown("test-device", "asset").IsZero())
+
+	Mark("test-device", "asset")
+
+	assert.WithinDuration(t, time.Now(), LastShown("test-device", "asset"), time.Second)
+	assert.True(t, LastShown("other-device", "asset").IsZero())
+}
+
+func TestWithin(t *testing.T) {
+	Mark("device", "asset")
+
+	assert.True(t, Within("device", "asset", time.Hour))
+	assert.False(t, Within("device", "asset", 0))
+	assert.False(t, Within("device", "other-asset", time.Hour))
+	assert.False(t, Within("other-device", "asset", time.Hour))
+}
+
+func TestPersistence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "seen.json")
+
+	require.NoError(t, Initialize(file))
+
+	Mark(Key("device", "lounge"), "asset-1")
+	Flush()
+
+	// reload from disk
+	require.NoError(t, Initialize(file))
+
+	assert.WithinDuration(t, time.Now(), LastShown("group:lounge", "asset-1"), time.Minute)
+	assert.True(t, LastShown("device", "asset-1").IsZero())
+}
@@ -0,0 +1,201 @@
+// Package smartcrop crops images to an aspect ratio while keeping what matters in frame.
+//
+// When faces are known the crop is placed so every face stays in frame. Without faces
+// the crop is placed over the most detailed part of the image, measured by the entropy
+// of its luminance.
+package smartcrop
+
+import (
+	"image"
+	"math"
+	"sort"
+
+	"github.com/disintegration/imaging"
+)
+
+const (
+	// analysisSize the longest side an image is scaled to when measuring entropy
+	analysisSize = 128
+
+	// entropySteps how many crop positions are compared when there are no faces
+	entropySteps = 16
+
+	// entropyBins the number of luminance levels entropy is measured over
+	entropyBins = 64
+
+	// cellSize the size, in analysis pixels, of the cells local entropy is measured in
+	cellSize = 8
+
+	// detailTolerance how much more detail a position needs to be preferred over one
+	// closer to the centre
+	detailTolerance = 0.5
+)
+
+// cropSize returns the largest width and height with the ratio width / height that fit
+// within bounds.
+func cropSize(bounds image.Rectangle, width, height int) (int, int) {
+	boundsWidth, boundsHeight := bounds.Dx(), bounds.Dy()
+
+	cropWidth := int(math.Round(float64(boundsHeight) * float64(width) / float64(height)))
+	if cropWidth <= boundsWidth {
+		return max(cropWidth, 1), boundsHeight
+	}
+
+	cropHeight := int(math.Round(float64(boundsWidth) * float64(height) / float64(width)))
+	return boundsWidth, max(min(cropHeight, boundsHeight), 1)
+}
+
+// Rect returns the area of img to crop to so it matches the aspect ratio of width by
+// height. The area is as large as possible and keeps all faces in frame; if the faces
+// cannot all fit they are centred instead.
+func Rect(img image.Image, width, height int, faces []image.Rectangle) image.Rectangle {
+	bounds := img.Bounds()
+	if width <= 0 || height <= 0 || bounds.Empty() {
+		return bounds
+	}
+
+	cropWidth, cropHeight := cropSize(bounds, width, height)
+	if cropWidth == bounds.Dx() && cropHeight == bounds.Dy() {
+		return bounds
+	}
+
+	var union image.Rectangle
+	for _, face := range faces {
+		face = face.Intersect(bounds)
+		if face.Empty() {
+			continue
+		}
+		union = union.Union(face)
+	}
+
+	var x, y int
+	if union.Empty() {
+		x, y = entropyOffset(img, cropWidth, cropHeight)
+	} else {
+		x = (union.Min.X+union.Max.X)/2 - cropWidth/2
+		y = (union.Min.Y+union.Max.Y)/2 - cropHeight/2
+	}
+
+	x = min(max(x, bounds.Min.X), bounds.Max.X-cropWidth)
+	y = min(max(y, bounds.Min.Y), bounds.Max.Y-cropHeight)
+
+	return image.Rect(x, y, x+cropWidth, y+cropHeight)
+}
+
+// Crop crops img to the aspect ratio of width by height, keeping faces in frame.
+func Crop(img image.Image, width, height int, faces []image.Rectangle) image.Image {
+	return CropTo(img, Rect(img, width, height, faces))
+}
+
+// CropTo crops img to rect, an area found by Rect. img is returned as it is when rect
+// covers all of it.
+func CropTo(img image.Image, rect image.Rectangle) image.Image {
+	if rect == img.Bounds() {
+		return img
+	}
+
+	return imaging.Crop(img, rect)
+}
+
+// entropyOffset returns where a crop of cropWidth by cropHeight has the most detail,
+// the sum of the local entropy of the cells it covers. Positions are compared from the
+// centre outwards so featureless images are cropped from the centre.
+func entropyOffset(img image.Image, cropWidth, cropHeight int) (int, int) {
+	bounds := img.Bounds()
+
+	gray := imaging.Grayscale(imaging.Fit(img, analysisSize, analysisSize, imaging.Box))
+	scale := float64(gray.Bounds().Dx()) / float64(bounds.Dx())
+	cells := cellEntropy(gray)
+
+	horizontal := cropWidth < bounds.Dx()
+
+	slack := bounds.Dy() - cropHeight
+	if horizontal {
+		slack = bounds.Dx() - cropWidth
+	}
+
+	offsets := make([]int, 0, entropySteps+1)
+	for step := range entropySteps + 1 {
+		offsets = append(offsets, slack*step/entropySteps)
+	}
+	sort.SliceStable(offsets, func(i, j int) bool {
+		return math.Abs(float64(offsets[i]-slack/2)) < math.Abs(float64(offsets[j]-slack/2))
+	})
+
+	best, bestDetail := slack/2, -1.0
+
+	for _, offset := range offsets {
+		window := image.Rect(0, offset, cropWidth, offset+cropHeight)
+		if horizontal {
+			window = image.Rect(offset, 0, offset+cropWidth, cropHeight)
+		}
+
+		detail := 0.0
+		for y, row := range cells {
+			for x, e := range row {
+				// cells count towards a window when their centre is within it
+				centre := image.Pt(
+					int((float64(x*cellSize)+cellSize/2)/scale),
+					int((float64(y*cellSize)+cellSize/2)/scale),
+				)
+				if centre.In(window) {
+					detail += e
+				}
+			}
+		}
+
+		if detail > bestDetail+detailTolerance {
+			best, bestDetail = offset, detail
+		}
+	}
+
+	if horizontal {
+		return bounds.Min.X + best, bounds.Min.Y
+	}
+
+	return bounds.Min.X, bounds.Min.Y + best
+}
+
+// cellEntropy splits gray into cells and returns the entropy of each cell, by row.
+func cellEntropy(gray *image.NRGBA) [][]float64 {
+	b := gray.Bounds()
+
+	var cells [][]float64
+	for y := b.Min.Y; y < b.Max.Y; y += cellSize {
+		var row []float64
+		for x := b.Min.X; x < b.Max.X; x += cellSize {
+			row = append(row, entropy(gray, image.Rect(x, y, x+cellSize, y+cellSize)))
+		}
+		cells = append(cells, row)
+	}
+
+	return cells
+}
+
+// entropy returns the Shannon entropy of the luminance of gray within window.
+func entropy(gray *image.NRGBA, window image.Rectangle) float64 {
+	window = window.Intersect(gray.Bounds())
+	if window.Empty() {
+		return 0
+	}
+
+	var histogram [entropyBins]int
+	for y := window.Min.Y; y < window.Max.Y; y++ {
+		for x := window.Min.X; x < window.Max.X; x++ {
+			histogram[int(gray.Pix[gray.PixOffset(x, y)])*entropyBins/256]++
+		}
+	}
+
+	total := float64(window.Dx() * window.Dy())
+
+	e := 0.0
+	for _, count := range histogram {
+		if count == 0 {
+			continue
+		}
+		p := float64(count) / total
+		e -= p * math.Log2(p)
+	}
+
+	return e
+}
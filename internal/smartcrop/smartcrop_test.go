@@ -0,0 +1,61 @@
+package smartcrop
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRectKeepsFacesInFrame(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 4000, 3000))
+
+	tests := []struct {
+		name   string
+		width  int
+		height int
+		faces  []image.Rectangle
+	}{
+		{name: "portrait screen, face on the right", width: 1080, height: 1920, faces: []image.Rectangle{image.Rect(3500, 500, 3800, 900)}},
+		{name: "portrait screen, faces on both sides", width: 1080, height: 1920, faces: []image.Rectangle{image.Rect(1200, 500, 1500, 900), image.Rect(2300, 600, 2600, 1000)}},
+		{name: "wide screen, face at the bottom", width: 2560, height: 1080, faces: []image.Rectangle{image.Rect(1800, 2600, 2100, 2950)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rect := Rect(img, tt.width, tt.height, tt.faces)
+
+			assert.True(t, rect.In(img.Bounds()), "crop should be within the image")
+			assert.InDelta(t, float64(tt.width)/float64(tt.height), float64(rect.Dx())/float64(rect.Dy()), 0.01)
+
+			for _, face := range tt.faces {
+				assert.True(t, face.In(rect), "face %v should be within crop %v", face, rect)
+			}
+		})
+	}
+}
+
+func TestRectWithoutFacesFollowsDetail(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 3000, 1000))
+
+	// noisy area on the left, flat everywhere else
+	for y := range 1000 {
+		for x := range 800 {
+			img.Set(x, y, color.Gray{Y: uint8((x*31 + y*17) % 256)})
+		}
+	}
+
+	rect := Rect(img, 1, 1, nil)
+	assert.Equal(t, image.Rect(0, 0, 1000, 1000), rect)
+
+	flat := image.NewRGBA(image.Rect(0, 0, 3000, 1000))
+	assert.Equal(t, image.Rect(1000, 0, 2000, 1000), Rect(flat, 1, 1, nil), "featureless images are cropped from the centre")
+}
+
+func TestCropMatchingRatio(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 1920, 1080))
+
+	assert.Same(t, img, Crop(img, 1920, 1080, nil).(*image.RGBA))
+	assert.Equal(t, image.Rect(0, 0, 608, 1080), Crop(img, 1080, 1920, nil).Bounds())
+}
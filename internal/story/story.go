@@ -0,0 +1,148 @@
+// Package story tracks albums and memories being played as stories.
+//
+// A story is an ordered sequence of assets shown one after another, after a title
+// card, before the device returns to its normal rotation. Each device plays at most
+// one story at a time and a story is not told again to the same device within
+// Cooldown.
+//
+// Stories are short lived so they are only tracked in memory.
+package story
+
+import (
+	"sync"
+	"time"
+)
+
+// Cooldown is how long before a device is told the same story again
+const Cooldown = 24 * time.Hour
+
+// Story is an album or memory played as an ordered sequence.
+type Story struct {
+	// ID of the album or memory the story is told from
+	ID string
+	// Title album name or memory title
+	Title string
+	// Start when the first asset was taken
+	Start time.Time
+	// End when the last asset was taken
+	End time.Time
+	// People names of the people in the story
+	People []string
+	// AssetIDs the assets in the order they are shown
+	AssetIDs []string
+	// next index of the next asset to show
+	next int
+}
+
+var (
+	mu      sync.Mutex
+	playing = map[string]*Story{}
+	told    = map[string]time.Time{}
+)
+
+// toldKey returns the key a told story is remembered under.
+func toldKey(deviceID, storyID string) string {
+	return deviceID + "|" + storyID
+}
+
+// Told reports whether a device has been told a story within Cooldown.
+func Told(deviceID, storyID string) bool {
+	mu.Lock()
+	defer mu.Unlock()
+
+	at, ok := told[toldKey(deviceID, storyID)]
+	return ok && time.Since(at) < Cooldown
+}
+
+// Start begins playing a story on a device, replacing any story already playing.
+func Start(deviceID string, s Story) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	s.next = 0
+	playing[deviceID] = &s
+
+	now := time.Now()
+	told[toldKey(deviceID, s.ID)] = now
+
+	for k, at := range told {
+		if now.Sub(at) >= Cooldown {
+			delete(told, k)
+		}
+	}
+}
+
+// Playing reports whether a device is playing a story.
+func Playing(deviceID string) bool {
+	mu.Lock()
+	defer mu.Unlock()
+
+	_, ok := playing[deviceID]
+	return ok
+}
+
+// Next returns the next asset of the story playing on a device and advances it.
+// Once every asset has been returned the story ends and ok is false.
+func Next(deviceID string) (assetID string, ok bool) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	s, found := playing[deviceID]
+	if !found {
+		return "", false
+	}
+
+	if s.next >= len(s.AssetIDs) {
+		delete(playing, deviceID)
+		return "", false
+	}
+
+	assetID = s.AssetIDs[s.next]
+	s.next++
+
+	return assetID, true
+}
+
+// Peek returns the next asset of the story playing on a device without advancing it,
+// for views prefetched before they are shown. Once every asset has been shown the story
+// ends and ok is false.
+func Peek(deviceID string) (assetID string, ok bool) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	s, found := playing[deviceID]
+	if !found {
+		return "", false
+	}
+
+	if s.next >= len(s.AssetIDs) {
+		delete(playing, deviceID)
+		return "", false
+	}
+
+	return s.AssetIDs[s.next], true
+}
+
+// Advance moves the story playing on a device past assetID, if it is the next asset.
+// It reports whether the story advanced.
+func Advance(deviceID, assetID string) bool {
+	mu.Lock()
+	defer mu.Unlock()
+
+	s, found := playing[deviceID]
+	if !found || s.next >= len(s.AssetIDs) || s.AssetIDs[s.next] != assetID {
+		return false
+	}
+
+	s.next++
+
+	return true
+}
+
+// End stops the story playing on a device, returning it to its normal rotation.
+func End(deviceID string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	delete(playing, deviceID)
+}
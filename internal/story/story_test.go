@@ -0,0 +1,66 @@
+package story
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoryPlaysInOrder(t *testing.T) {
+	deviceID := "device-order"
+
+	assert.False(t, Playing(deviceID))
+
+	Start(deviceID, Story{ID: "album-1", AssetIDs: []string{"a", "b", "c"}})
+	assert.True(t, Playing(deviceID))
+
+	for _, want := range []string{"a", "b", "c"} {
+		got, ok := Next(deviceID)
+		assert.True(t, ok)
+		assert.Equal(t, want, got)
+	}
+
+	_, ok := Next(deviceID)
+	assert.False(t, ok, "story should end once every asset has been shown")
+	assert.False(t, Playing(deviceID))
+}
+
+func TestStoryTold(t *testing.T) {
+	deviceID := "device-told"
+
+	assert.False(t, Told(deviceID, "memory-1"))
+
+	Start(deviceID, Story{ID: "memory-1", AssetIDs: []string{"a"}})
+	assert.True(t, Told(deviceID, "memory-1"))
+	assert.False(t, Told("another-device", "memory-1"), "stories are told per device")
+
+	End(deviceID)
+	assert.False(t, Playing(deviceID))
+	assert.True(t, Told(deviceID, "memory-1"), "ending a story still counts as told")
+}
+
+func TestStoryPeekAndAdvance(t *testing.T) {
+	deviceID := "device-peek"
+
+	Start(deviceID, Story{ID: "album-2", AssetIDs: []string{"a", "b"}})
+
+	got, ok := Peek(deviceID)
+	assert.True(t, ok)
+	assert.Equal(t, "a", got)
+
+	got, _ = Peek(deviceID)
+	assert.Equal(t, "a", got, "peeking should not advance the story")
+
+	assert.False(t, Advance(deviceID, "b"), "only the next asset advances the story")
+	assert.True(t, Advance(deviceID, "a"))
+	assert.False(t, Advance(deviceID, "a"), "a view served twice advances the story once")
+
+	got, _ = Peek(deviceID)
+	assert.Equal(t, "b", got)
+
+	assert.True(t, Advance(deviceID, "b"))
+
+	_, ok = Peek(deviceID)
+	assert.False(t, ok, "story should end once every asset has been shown")
+	assert.False(t, Playing(deviceID))
+}
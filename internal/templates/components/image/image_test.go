@@ -1,6 +1,8 @@
 package components
 
 import (
+	"context"
+	"strings"
 	"testing"
 
 	"github.com/damongolding/immich-kiosk/internal/common"
@@ -237,3 +239,101 @@ func TestModifyGIFAssetsNilViewData(t *testing.T) {
 
 	modifyGIFAssets(nil)
 }
+
+func TestMosaicTiles(t *testing.T) {
+	viewData := common.ViewData{
+		Assets: []common.ViewImageData{
+			{ImmichAsset: immich.Asset{ExifInfo: immich.ExifInfo{ExifImageWidth: 3000, ExifImageHeight: 2000}}},
+			{ImmichAsset: immich.Asset{ExifInfo: immich.ExifInfo{ExifImageWidth: 2000, ExifImageHeight: 3000}}},
+			{ImmichAsset: immich.Asset{IsPortrait: true}},
+		},
+	}
+
+	viewData.Layout = kiosk.LayoutSplitview
+	if tiles := mosaicTiles(viewData); tiles != nil {
+		t.Errorf("mosaicTiles() = %v, want nil for non mosaic layouts", tiles)
+	}
+
+	viewData.Layout = kiosk.LayoutMosaic
+	tiles := mosaicTiles(viewData)
+	if len(tiles) != len(viewData.Assets) {
+		t.Fatalf("mosaicTiles() returned %d tiles, want %d", len(tiles), len(viewData.Assets))
+	}
+
+	if style := tileStyle(tiles, 0); style == "" {
+		t.Error("tileStyle() returned an empty style for a mosaic tile")
+	}
+
+	if style := tileStyle(nil, 0); style != "" {
+		t.Errorf("tileStyle() = %q, want empty style without tiles", style)
+	}
+}
+
+func TestRenderImageBackground(t *testing.T) {
+	const placeholder = "data:image/png;base64,iVBORw0KGgo="
+
+	tests := []struct {
+		name      string
+		style     string
+		imageData common.ViewImageData
+		want      []string
+		notWant   []string
+	}{
+		{
+			name:      "Blurred image over placeholder",
+			style:     kiosk.BackgroundStyleBlur,
+			imageData: common.ViewImageData{ImageBlurData: "/image/blur", ImagePlaceholder: placeholder},
+			want:      []string{`src="/image/blur"`, "background:url(" + placeholder + ")"},
+		},
+		{
+			name:      "Thumbhash style",
+			style:     kiosk.BackgroundStyleThumbhash,
+			imageData: common.ViewImageData{ImagePlaceholder: placeholder},
+			want:      []string{"frame--background-thumbhash", `src="` + placeholder + `"`},
+		},
+		{
+			name:      "Mirror style",
+			style:     kiosk.BackgroundStyleMirror,
+			imageData: common.ViewImageData{ImageBlurData: "/image/mirror"},
+			want:      []string{"frame--background-mirror", `src="/image/mirror"`},
+		},
+		{
+			name:      "Gradient style",
+			style:     kiosk.BackgroundStyleGradient,
+			imageData: common.ViewImageData{ImageBackground: "linear-gradient(to right, rgb(255, 0, 0), rgb(0, 0, 255))", ImagePlaceholder: placeholder},
+			want:      []string{"background:linear-gradient(to right, rgb(255, 0, 0), rgb(0, 0, 255));"},
+			notWant:   []string{"<img"},
+		},
+		{
+			name:      "Blur style without a blurred image",
+			style:     kiosk.BackgroundStyleBlur,
+			imageData: common.ViewImageData{ImagePlaceholder: placeholder},
+			notWant:   []string{"frame--background"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viewData := common.ViewData{}
+			viewData.BackgroundBlur = true
+			viewData.BackgroundStyle = tt.style
+
+			var buf strings.Builder
+			if err := renderImageBackground(viewData, tt.imageData).Render(context.Background(), &buf); err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+
+			for _, want := range tt.want {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("rendered %q, want it to contain %q", buf.String(), want)
+				}
+			}
+
+			for _, notWant := range tt.notWant {
+				if strings.Contains(buf.String(), notWant) {
+					t.Errorf("rendered %q, want it not to contain %q", buf.String(), notWant)
+				}
+			}
+		})
+	}
+}
@@ -3,8 +3,11 @@ package partials
 import (
 	"fmt"
 	"testing"
+	"time"
 
+	"github.com/damongolding/immich-kiosk/internal/common"
 	"github.com/damongolding/immich-kiosk/internal/immich"
+	"github.com/goodsign/monday"
 )
 
 func TestAssetCameraData(t *testing.T) {
@@ -123,3 +126,38 @@ func TestTrimFloatToString(t *testing.T) {
 		})
 	}
 }
+
+func TestJourneyDate(t *testing.T) {
+	tests := []struct {
+		name  string
+		taken time.Time
+		want  string
+	}{
+		{
+			name:  "Month and year",
+			taken: time.Date(2014, time.March, 12, 10, 30, 0, 0, time.UTC),
+			want:  "March 2014",
+		},
+		{
+			name:  "No date",
+			taken: time.Time{},
+			want:  "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viewData := common.ViewData{
+				Assets: []common.ViewImageData{
+					{ImmichAsset: immich.Asset{LocalDateTime: tt.taken}},
+				},
+			}
+			viewData.SystemLang = monday.LocaleEnUS
+
+			got := JourneyDate(viewData, 0)
+			if got != tt.want {
+				t.Errorf("JourneyDate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
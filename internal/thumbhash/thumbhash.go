@@ -0,0 +1,288 @@
+// Package thumbhash decodes the ThumbHash Immich stores for every asset into a tiny
+// placeholder image, so something resembling the asset can be shown before it loads.
+//
+// A ThumbHash is a handful of DCT coefficients for the asset's luminance, colour and
+// alpha channels. Decoding follows the reference implementation at
+// https://github.com/evanw/thumbhash.
+package thumbhash
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+)
+
+// MaxSize the width or height of the longest side of a decoded placeholder
+const MaxSize = 32
+
+// ErrInvalid is returned for hashes too short to be a ThumbHash.
+var ErrInvalid = errors.New("invalid thumbhash")
+
+// header the constants at the start of every hash.
+type header struct {
+	lDC, pDC, qDC, aDC     float64
+	lScale, pScale, qScale float64
+	aScale                 float64
+	hasAlpha, isLandscape  bool
+	lx, ly                 int
+	acStart                int
+}
+
+// Parse decodes a base64 encoded ThumbHash, as returned by Immich.
+func Parse(hash string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(hash)
+	if err != nil {
+		return nil, errors.Join(ErrInvalid, err)
+	}
+
+	if len(data) < 5 {
+		return nil, ErrInvalid
+	}
+
+	return data, nil
+}
+
+// DataURL decodes a base64 encoded ThumbHash into a PNG data URL, ready to be used as
+// an image source or CSS background.
+func DataURL(hash string) (string, error) {
+	data, err := Parse(hash)
+	if err != nil {
+		return "", err
+	}
+
+	img, err := Decode(data)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err = png.Encode(&buf, img); err != nil {
+		return "", err
+	}
+
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// readHeader reads the constants at the start of hash.
+func readHeader(hash []byte) (header, error) {
+	if len(hash) < 5 {
+		return header{}, ErrInvalid
+	}
+
+	header24 := uint32(hash[0]) | uint32(hash[1])<<8 | uint32(hash[2])<<16
+	header16 := uint32(hash[3]) | uint32(hash[4])<<8
+
+	h := header{
+		lDC:         float64(header24&63) / 63,
+		pDC:         float64((header24>>6)&63)/31.5 - 1,
+		qDC:         float64((header24>>12)&63)/31.5 - 1,
+		lScale:      float64((header24>>18)&31) / 31,
+		hasAlpha:    header24>>23 != 0,
+		pScale:      float64((header16>>3)&63) / 63,
+		qScale:      float64((header16>>9)&63) / 63,
+		isLandscape: header16>>15 != 0,
+		aDC:         1,
+		acStart:     5,
+	}
+
+	if h.hasAlpha {
+		if len(hash) < 6 {
+			return header{}, ErrInvalid
+		}
+
+		h.aDC = float64(hash[5]&15) / 15
+		h.aScale = float64(hash[5]>>4) / 15
+		h.acStart = 6
+	}
+
+	long := 7
+	if h.hasAlpha {
+		long = 5
+	}
+
+	if h.isLandscape {
+		h.lx, h.ly = long, int(header16&7)
+	} else {
+		h.lx, h.ly = int(header16&7), long
+	}
+
+	h.lx, h.ly = max(3, h.lx), max(3, h.ly)
+
+	return h, nil
+}
+
+// AspectRatio returns the approximate width / height of the image hash was made from.
+func AspectRatio(hash []byte) float64 {
+	if len(hash) < 5 {
+		return 1
+	}
+
+	hasAlpha := hash[2]&0x80 != 0
+	isLandscape := hash[4]&0x80 != 0
+
+	long := 7
+	if hasAlpha {
+		long = 5
+	}
+
+	lx, ly := int(hash[3]&7), long
+	if isLandscape {
+		lx, ly = long, int(hash[3]&7)
+	}
+
+	if lx == 0 || ly == 0 {
+		return 1
+	}
+
+	return float64(lx) / float64(ly)
+}
+
+// AverageColor returns the average colour of the image hash was made from.
+func AverageColor(hash []byte) (color.NRGBA, error) {
+	h, err := readHeader(hash)
+	if err != nil {
+		return color.NRGBA{}, err
+	}
+
+	r, g, b := toRGB(h.lDC, h.pDC, h.qDC)
+
+	return color.NRGBA{R: r, G: g, B: b, A: channel(h.aDC)}, nil
+}
+
+// Decode renders hash as an image no larger than MaxSize on either side.
+func Decode(hash []byte) (image.Image, error) {
+	h, err := readHeader(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	acIndex := 0
+	decodeChannel := func(nx, ny int, scale float64) ([]float64, error) {
+		var ac []float64
+		for cy := range ny {
+			for cx := boolToInt(cy == 0); cx*ny < nx*(ny-cy); cx++ {
+				i := h.acStart + acIndex>>1
+				if i >= len(hash) {
+					return nil, ErrInvalid
+				}
+
+				value := (hash[i] >> ((acIndex & 1) << 2)) & 15
+				ac = append(ac, (float64(value)/7.5-1)*scale)
+				acIndex++
+			}
+		}
+		return ac, nil
+	}
+
+	// saturation is boosted by 1.25x to make up for quantisation
+	lAC, err := decodeChannel(h.lx, h.ly, h.lScale)
+	if err != nil {
+		return nil, err
+	}
+
+	pAC, err := decodeChannel(3, 3, h.pScale*1.25)
+	if err != nil {
+		return nil, err
+	}
+
+	qAC, err := decodeChannel(3, 3, h.qScale*1.25)
+	if err != nil {
+		return nil, err
+	}
+
+	var aAC []float64
+	if h.hasAlpha {
+		if aAC, err = decodeChannel(5, 5, h.aScale); err != nil {
+			return nil, err
+		}
+	}
+
+	ratio := AspectRatio(hash)
+	width, height := MaxSize, int(math.Round(MaxSize/ratio))
+	if ratio <= 1 {
+		width, height = int(math.Round(MaxSize*ratio)), MaxSize
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+
+	nx, ny := max(h.lx, 3), max(h.ly, 3)
+	if h.hasAlpha {
+		nx, ny = max(h.lx, 5), max(h.ly, 5)
+	}
+
+	fx := make([]float64, nx)
+	fy := make([]float64, ny)
+
+	for y := range height {
+		for cy := range ny {
+			fy[cy] = math.Cos(math.Pi / float64(height) * (float64(y) + 0.5) * float64(cy))
+		}
+
+		for x := range width {
+			for cx := range nx {
+				fx[cx] = math.Cos(math.Pi / float64(width) * (float64(x) + 0.5) * float64(cx))
+			}
+
+			l, p, q, a := h.lDC, h.pDC, h.qDC, h.aDC
+
+			for cy, j := 0, 0; cy < h.ly; cy++ {
+				fy2 := fy[cy] * 2
+				for cx := boolToInt(cy == 0); cx*h.ly < h.lx*(h.ly-cy); cx++ {
+					l += lAC[j] * fx[cx] * fy2
+					j++
+				}
+			}
+
+			for cy, j := 0, 0; cy < 3; cy++ {
+				fy2 := fy[cy] * 2
+				for cx := boolToInt(cy == 0); cx < 3-cy; cx++ {
+					f := fx[cx] * fy2
+					p += pAC[j] * f
+					q += qAC[j] * f
+					j++
+				}
+			}
+
+			if h.hasAlpha {
+				for cy, j := 0, 0; cy < 5; cy++ {
+					fy2 := fy[cy] * 2
+					for cx := boolToInt(cy == 0); cx < 5-cy; cx++ {
+						a += aAC[j] * fx[cx] * fy2
+						j++
+					}
+				}
+			}
+
+			r, g, b := toRGB(l, p, q)
+			img.SetNRGBA(x, y, color.NRGBA{R: r, G: g, B: b, A: channel(a)})
+		}
+	}
+
+	return img, nil
+}
+
+// toRGB converts ThumbHash's LPQ colour space to RGB.
+func toRGB(l, p, q float64) (uint8, uint8, uint8) {
+	b := l - 2.0/3.0*p
+	r := (3*l - b + q) / 2
+	g := r - q
+
+	return channel(r), channel(g), channel(b)
+}
+
+// channel scales a 0-1 value to a colour channel, clamping values outside that range.
+func channel(v float64) uint8 {
+	return uint8(max(0, 255*min(1, v)))
+}
+
+// boolToInt returns 1 for true and 0 for false.
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,83 @@
+package thumbhash
+
+import (
+	"encoding/base64"
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// solidHash builds a hash with no variation, so it decodes to a single colour.
+func solidHash(l, p, q uint32, landscape bool) []byte {
+	header24 := l | p<<6 | q<<12
+	header16 := uint32(3)
+	if landscape {
+		header16 |= 1 << 15
+	}
+
+	hash := make([]byte, 20)
+	hash[0], hash[1], hash[2] = byte(header24), byte(header24>>8), byte(header24>>16)
+	hash[3], hash[4] = byte(header16), byte(header16>>8)
+
+	return hash
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name      string
+		landscape bool
+		width     int
+		height    int
+	}{
+		{name: "landscape", landscape: true, width: 32, height: 14},
+		{name: "portrait", landscape: false, width: 14, height: 32},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hash := solidHash(32, 32, 32, tt.landscape)
+
+			img, err := Decode(hash)
+			require.NoError(t, err)
+
+			bounds := img.Bounds()
+			assert.Equal(t, tt.width, bounds.Dx())
+			assert.Equal(t, tt.height, bounds.Dy())
+
+			average, err := AverageColor(hash)
+			require.NoError(t, err)
+			assert.InDelta(t, 128, int(average.R), 6)
+			assert.InDelta(t, 128, int(average.G), 6)
+			assert.InDelta(t, 128, int(average.B), 6)
+			assert.Equal(t, uint8(255), average.A)
+
+			for _, point := range [][2]int{{0, 0}, {bounds.Dx() / 2, bounds.Dy() / 2}, {bounds.Dx() - 1, bounds.Dy() - 1}} {
+				assert.Equal(t, average, color.NRGBAModel.Convert(img.At(point[0], point[1])))
+			}
+		})
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	_, err := Decode([]byte{1, 2, 3})
+	require.ErrorIs(t, err, ErrInvalid)
+
+	// a header promising more coefficients than the hash holds
+	_, err = Decode(solidHash(32, 32, 32, true)[:8])
+	require.ErrorIs(t, err, ErrInvalid)
+
+	_, err = Parse("not base64!")
+	require.ErrorIs(t, err, ErrInvalid)
+}
+
+func TestDataURL(t *testing.T) {
+	dataURL, err := DataURL(base64.StdEncoding.EncodeToString(solidHash(63, 32, 32, true)))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(dataURL, "data:image/png;base64,"))
+
+	_, err = DataURL("")
+	require.Error(t, err)
+}
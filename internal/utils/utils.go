@@ -36,7 +36,12 @@ import (
 	"charm.land/log/v2"
 	"github.com/EdlinOrg/prominentcolor"
 	"github.com/damongolding/immich-kiosk/internal/kiosk"
+	"github.com/damongolding/immich-kiosk/internal/weighting"
 	"github.com/disintegration/imaging"
+	"github.com/gen2brain/avif"
+	"github.com/gen2brain/heic"
+	webpenc "github.com/gen2brain/webp"
+	_ "golang.org/x/image/bmp"
 	"golang.org/x/image/webp"
 
 	"github.com/google/uuid"
@@ -51,6 +56,29 @@ const (
 	sigmaConstant          float64 = 1300.0
 	blurredImageBrightness float64 = -20
 
+	// mirroredBackgroundSize the longest side of a mirrored background. Backgrounds are
+	// stretched to the screen, so little detail is needed
+	mirroredBackgroundSize = 640
+	// edgeFraction how much of an image's edge is averaged for gradient backgrounds
+	edgeFraction = 0.05
+	// edgeSamples roughly how many pixels are sampled along each edge
+	edgeSamples = 200
+
+	// hiddenFacePadding how much each side of a face is grown by before it is hidden, so hair and chins are hidden too
+	hiddenFacePadding = 0.15
+	// hiddenFaceBlocks how many blocks across a pixelated face is
+	hiddenFaceBlocks = 8
+
+	// default encoding quality of each lossy format
+	defaultJpegQuality = 95
+	defaultWebpQuality = 80
+	defaultAvifQuality = 60
+
+	// webpMethod and avifSpeed trade encoding speed for size, favouring speed so
+	// images are ready in time for the next refresh
+	webpMethod = 4
+	avifSpeed  = 8
+
 	// minMemoryWeight is the minimum weight allowed for memory assets.
 	minMemoryWeight float64 = 0.0001
 
@@ -80,6 +108,7 @@ type AssetWithWeighting struct {
 	Asset   WeightedAsset
 	Weight  int     // base weight
 	Penalty float64 // penalty for weight. 1.0 = no penalty, 0.5 = 50% penalty.
+	Share   float64 // fixed share of picks as a percentage (0-100). 0 = use weight.
 }
 
 // GenerateUUID generates a new random UUID string
@@ -117,6 +146,17 @@ func DateToJavascriptLayout(input string) string {
 	return replacer.Replace(input)
 }
 
+// heicBrands are the HEVC ISO base media brands, other than "heic", HEIC originals are
+// written with. The heic package only registers the "heic" brand itself. The generic
+// "mif1" and "msf1" brands are left out as AVIF images are written with them too.
+var heicBrands = []string{"heix", "heim", "heis", "hevc", "hevx", "hevm", "hevs"}
+
+func init() {
+	for _, brand := range heicBrands {
+		image.RegisterFormat("heic", "????ftyp"+brand, heic.Decode, heic.DecodeConfig)
+	}
+}
+
 // ImageToBytes converts an image.Image to a byte slice in JPEG format.
 // It takes an image.Image as input and returns the encoded bytes and any error encountered.
 // The bytes can be used for further processing, transmission, or storage.
@@ -133,8 +173,8 @@ func ImageToBytes(img image.Image) ([]byte, error) {
 
 // BytesToImage converts a byte slice to an image.Image.
 // It takes a byte slice as input and returns an image.Image and any error encountered.
-// It handles both WebP and other common image formats (JPEG, PNG, GIF) automatically
-// by detecting the MIME type and using the appropriate decoder.
+// It handles WebP, AVIF, HEIC, BMP and other common image formats (JPEG, PNG, GIF)
+// automatically by detecting the MIME type and using the appropriate decoder.
 func BytesToImage(imgBytes []byte, isOriginal bool) (image.Image, string, error) {
 	var img image.Image
 	var err error
@@ -319,32 +359,64 @@ func ApplyExifOrientation(img image.Image, orient orientation) image.Image {
 	return img
 }
 
-// ImageToBase64 converts an image.Image to a base64 encoded data URI string with appropriate MIME type
-func ImageToBase64(img image.Image, mimeType string) (string, error) {
+// EncodeImage encodes an image.Image as mimeType. PNG, GIF, WebP and AVIF are kept,
+// every other MIME type is encoded as JPEG. Quality (1-100) applies to the lossy
+// formats; 0 uses each format's default.
+// It returns the encoded bytes and the MIME type used.
+func EncodeImage(img image.Image, mimeType string, quality int) ([]byte, string, error) {
 	var buf bytes.Buffer
 
 	switch mimeType {
+	case kiosk.MimeTypeWebp:
+		if quality == 0 {
+			quality = defaultWebpQuality
+		}
+		err := webpenc.Encode(&buf, img, webpenc.Options{Quality: quality, Method: webpMethod})
+		if err != nil {
+			return nil, "", err
+		}
+	case kiosk.MimeTypeAvif:
+		if quality == 0 {
+			quality = defaultAvifQuality
+		}
+		err := avif.Encode(&buf, img, avif.Options{Quality: quality, Speed: avifSpeed})
+		if err != nil {
+			return nil, "", err
+		}
 	case kiosk.MimeTypePng:
 		err := imaging.Encode(&buf, img, imaging.PNG)
 		if err != nil {
-			return "", err
+			return nil, "", err
 		}
 	case kiosk.MimeTypeGif:
 		err := imaging.Encode(&buf, img, imaging.GIF)
 		if err != nil {
-			return "", err
+			return nil, "", err
 		}
 	case kiosk.MimeTypeJpeg, kiosk.MimeTypeJpg, "":
 		fallthrough
 	default:
 		mimeType = kiosk.MimeTypeJpeg
-		err := imaging.Encode(&buf, img, imaging.JPEG)
+		if quality == 0 {
+			quality = defaultJpegQuality
+		}
+		err := imaging.Encode(&buf, img, imaging.JPEG, imaging.JPEGQuality(quality))
 		if err != nil {
-			return "", err
+			return nil, "", err
 		}
 	}
 
-	base64Encoding := fmt.Sprintf("data:%s;base64,%s", mimeType, base64.StdEncoding.EncodeToString(buf.Bytes()))
+	return buf.Bytes(), mimeType, nil
+}
+
+// ImageToBase64 converts an image.Image to a base64 encoded data URI string with appropriate MIME type
+func ImageToBase64(img image.Image, mimeType string) (string, error) {
+	imgBytes, mimeType, err := EncodeImage(img, mimeType, 0)
+	if err != nil {
+		return "", err
+	}
+
+	base64Encoding := fmt.Sprintf("data:%s;base64,%s", mimeType, base64.StdEncoding.EncodeToString(imgBytes))
 	return base64Encoding, nil
 }
 
@@ -370,7 +442,7 @@ func imageFormat(r io.Reader) (string, error) {
 	return format, err
 }
 
-// ImageMimeType returns the MIME type (gif/jpeg/png/webp) for an image reader
+// ImageMimeType returns the MIME type (gif/jpeg/png/webp/avif/heic/bmp) for an image reader
 func ImageMimeType(r io.Reader) string {
 	format, err := imageFormat(r)
 	if err != nil || format == "" {
@@ -378,6 +450,16 @@ func ImageMimeType(r io.Reader) string {
 		return ""
 	}
 
+	// not every system's MIME table knows these formats
+	switch format {
+	case "avif":
+		return kiosk.MimeTypeAvif
+	case "bmp":
+		return kiosk.MimeTypeBmp
+	case "heic":
+		return kiosk.MimeTypeHeic
+	}
+
 	return mime.TypeByExtension("." + format)
 }
 
@@ -398,6 +480,173 @@ func BlurImage(img image.Image, blurrAmount int, isOptimized bool, clientWidth,
 	return blurredImage, nil
 }
 
+// clientSize returns the client's dimensions, or 1920x1080 when they are unknown.
+func clientSize(clientWidth, clientHeight int) (int, int) {
+	if clientWidth <= 0 || clientHeight <= 0 {
+		return 1920, 1080
+	}
+	return clientWidth, clientHeight
+}
+
+// barsBeside reports whether an image fitted within the client leaves bars to its left
+// and right, rather than above and below.
+func barsBeside(bounds image.Rectangle, clientWidth, clientHeight int) bool {
+	clientWidth, clientHeight = clientSize(clientWidth, clientHeight)
+	return bounds.Dx()*clientHeight < clientWidth*bounds.Dy()
+}
+
+// EdgeColors returns the average colours of the two edges of an image that meet the bars
+// left when it is fitted within the client: left and right, or top and bottom.
+// beside reports which.
+func EdgeColors(img image.Image, clientWidth, clientHeight int) (from, to color.RGBA, beside bool) {
+	bounds := img.Bounds()
+	beside = barsBeside(bounds, clientWidth, clientHeight)
+
+	if beside {
+		depth := max(1, int(float64(bounds.Dx())*edgeFraction))
+		from = averageColor(img, image.Rect(bounds.Min.X, bounds.Min.Y, bounds.Min.X+depth, bounds.Max.Y))
+		to = averageColor(img, image.Rect(bounds.Max.X-depth, bounds.Min.Y, bounds.Max.X, bounds.Max.Y))
+	} else {
+		depth := max(1, int(float64(bounds.Dy())*edgeFraction))
+		from = averageColor(img, image.Rect(bounds.Min.X, bounds.Min.Y, bounds.Max.X, bounds.Min.Y+depth))
+		to = averageColor(img, image.Rect(bounds.Min.X, bounds.Max.Y-depth, bounds.Max.X, bounds.Max.Y))
+	}
+
+	return from, to, beside
+}
+
+// averageColor returns the average colour of a sample of the pixels within area.
+func averageColor(img image.Image, area image.Rectangle) color.RGBA {
+	area = area.Intersect(img.Bounds())
+	if area.Empty() {
+		return color.RGBA{A: 255}
+	}
+
+	stepX := max(1, area.Dx()/edgeSamples)
+	stepY := max(1, area.Dy()/edgeSamples)
+
+	var r, g, b, count uint64
+	for y := area.Min.Y; y < area.Max.Y; y += stepY {
+		for x := area.Min.X; x < area.Max.X; x += stepX {
+			pr, pg, pb, _ := img.At(x, y).RGBA()
+			r += uint64(pr >> 8)
+			g += uint64(pg >> 8)
+			b += uint64(pb >> 8)
+			count++
+		}
+	}
+
+	return color.RGBA{R: uint8(r / count), G: uint8(g / count), B: uint8(b / count), A: 255}
+}
+
+// CSSColor returns c as a CSS rgb() colour.
+func CSSColor(c color.RGBA) string {
+	return fmt.Sprintf("rgb(%d, %d, %d)", c.R, c.G, c.B)
+}
+
+// GradientBackground returns a CSS linear gradient between the colours of an image's
+// edges, running across the bars left when it is fitted within the client.
+func GradientBackground(img image.Image, clientWidth, clientHeight int) string {
+	from, to, beside := EdgeColors(img, clientWidth, clientHeight)
+
+	direction := "to bottom"
+	if beside {
+		direction = "to right"
+	}
+
+	return fmt.Sprintf("linear-gradient(%s, %s, %s)", direction, CSSColor(from), CSSColor(to))
+}
+
+// MirrorImage extends an image to the client's aspect ratio by mirroring it at its
+// edges, so a contained image appears to continue into the bars around it.
+// The result is small, as it is stretched to the screen.
+func MirrorImage(img image.Image, clientWidth, clientHeight int) image.Image {
+	clientWidth, clientHeight = clientSize(clientWidth, clientHeight)
+
+	src := imaging.Fit(img, mirroredBackgroundSize, mirroredBackgroundSize, imaging.Linear)
+	srcWidth, srcHeight := src.Bounds().Dx(), src.Bounds().Dy()
+
+	width, height := srcWidth, srcHeight
+	if barsBeside(src.Bounds(), clientWidth, clientHeight) {
+		width = max(srcWidth, int(math.Round(float64(srcHeight*clientWidth)/float64(clientHeight))))
+	} else {
+		height = max(srcHeight, int(math.Round(float64(srcWidth*clientHeight)/float64(clientWidth))))
+	}
+
+	offsetX, offsetY := (width-srcWidth)/2, (height-srcHeight)/2
+
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		srcY := mirrorIndex(y-offsetY, srcHeight)
+		for x := range width {
+			srcX := mirrorIndex(x-offsetX, srcWidth)
+
+			from := src.PixOffset(srcX, srcY)
+			to := dst.PixOffset(x, y)
+			copy(dst.Pix[to:to+4], src.Pix[from:from+4])
+		}
+	}
+
+	return dst
+}
+
+// mirrorIndex maps i onto 0 to n-1, reflecting back and forth at each end.
+func mirrorIndex(i, n int) int {
+	period := 2 * n
+	i %= period
+	if i < 0 {
+		i += period
+	}
+
+	if i >= n {
+		return period - 1 - i
+	}
+
+	return i
+}
+
+// HideRegions pixelates, or blurs, each of regions in a copy of img so faces in them cannot
+// be recognised. Regions are grown by hiddenFacePadding and clipped to the image.
+// img is returned as is when there are no regions.
+func HideRegions(img image.Image, regions []image.Rectangle, pixelate bool) image.Image {
+	if len(regions) == 0 {
+		return img
+	}
+
+	dst := imaging.Clone(img)
+	bounds := dst.Bounds()
+
+	for _, region := range regions {
+		padX := int(math.Ceil(float64(region.Dx()) * hiddenFacePadding))
+		padY := int(math.Ceil(float64(region.Dy()) * hiddenFacePadding))
+
+		region = region.Inset(-max(padX, padY)).Intersect(bounds)
+		if region.Empty() {
+			continue
+		}
+
+		width, height := region.Dx(), region.Dy()
+		area := imaging.Crop(dst, region)
+
+		var hidden *image.NRGBA
+		if pixelate {
+			blockSize := max(1, max(width, height)/hiddenFaceBlocks)
+			blocks := imaging.Resize(area, max(1, width/blockSize), max(1, height/blockSize), imaging.Box)
+			hidden = imaging.Resize(blocks, width, height, imaging.NearestNeighbor)
+		} else {
+			hidden = imaging.Blur(area, float64(max(width, height))/4)
+		}
+
+		for y := range height {
+			from := hidden.PixOffset(0, y)
+			to := dst.PixOffset(region.Min.X, region.Min.Y+y)
+			copy(dst.Pix[to:to+width*4], hidden.Pix[from:from+width*4])
+		}
+	}
+
+	return dst
+}
+
 // CombineQueries combines URL.Query() and Referer() queries into a single url.Values.
 // Referer query parameters will overwrite URL query parameters with the same names.
 func CombineQueries(urlQueries url.Values, refererURL string) (url.Values, error) {
@@ -497,6 +746,46 @@ func calculateTotalWeight(assets []AssetWithWeighting) float64 {
 	return total
 }
 
+// effectiveWeights returns the selection weight of each asset.
+//
+// Assets with a fixed Share receive that percentage of the total. The remaining
+// percentage is divided between the other assets in proportion to assetWeight.
+// If the shares add up to more than 100% they are scaled down and assets without
+// a share are never picked.
+func effectiveWeights(assets []AssetWithWeighting) []float64 {
+	weights := make([]float64, len(assets))
+
+	totalShare := 0.0
+	totalWeight := 0.0
+	for i, asset := range assets {
+		if asset.Share > 0 {
+			totalShare += asset.Share
+			continue
+		}
+		weights[i] = assetWeight(asset)
+		totalWeight += weights[i]
+	}
+
+	if totalShare == 0 {
+		return weights
+	}
+
+	remaining := max(0, 100-totalShare)
+
+	for i, asset := range assets {
+		if asset.Share > 0 {
+			weights[i] = asset.Share
+			continue
+		}
+
+		if totalWeight > 0 {
+			weights[i] = weights[i] / totalWeight * remaining
+		}
+	}
+
+	return weights
+}
+
 func WeightedRandomItem(assets []AssetWithWeighting) WeightedAsset {
 	switch len(assets) {
 	case 0:
@@ -505,19 +794,9 @@ func WeightedRandomItem(assets []AssetWithWeighting) WeightedAsset {
 		return assets[0].Asset
 	}
 
-	totalWeight := calculateTotalWeight(assets)
-	r := rand.Float64() * totalWeight
+	weights := effectiveWeights(assets)
 
-	for _, asset := range assets {
-		w := assetWeight(asset)
-		if r < w {
-			return asset.Asset
-		}
-		r -= w
-	}
-
-	// Should never happen, but keep a safe fallback
-	return assets[0].Asset
+	return assets[weighting.Pick(weights, nil)].Asset
 }
 
 // Color represents an RGB color with string representations
@@ -608,9 +887,18 @@ func linearize(value float64) float64 {
 func PickRandomImageType(useWeighting bool, assetBuckets []AssetWithWeighting) WeightedAsset {
 	var pickedImage WeightedAsset
 
-	if useWeighting {
+	switch {
+	case useWeighting:
 		pickedImage = WeightedRandomItem(assetBuckets)
-	} else {
+	case hasCustomWeighting(assetBuckets):
+		// ignore asset counts but still honour custom multipliers and shares
+		equalBuckets := make([]AssetWithWeighting, len(assetBuckets))
+		for i, item := range assetBuckets {
+			equalBuckets[i] = item
+			equalBuckets[i].Weight = 1
+		}
+		pickedImage = WeightedRandomItem(equalBuckets)
+	default:
 		var assetsWithoutWeighting []WeightedAsset
 		for _, item := range assetBuckets {
 			assetsWithoutWeighting = append(assetsWithoutWeighting, item.Asset)
@@ -621,9 +909,19 @@ func PickRandomImageType(useWeighting bool, assetBuckets []AssetWithWeighting) W
 	return pickedImage
 }
 
-// parseTimeString parses a time string in various formats and returns a time.Time value.
+// hasCustomWeighting reports whether any bucket has a custom multiplier or share.
+func hasCustomWeighting(assetBuckets []AssetWithWeighting) bool {
+	for _, item := range assetBuckets {
+		if item.Share > 0 || (item.Penalty > 0 && item.Penalty != 1) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseTimeString parses a time string in various formats and returns a time.Time value.
 // It accepts formats like "1", "12", "130", "1430" and converts them to hours and minutes.
-func parseTimeString(timeStr string) (time.Time, error) {
+func ParseTimeString(timeStr string) (time.Time, error) {
 	// Trim whitespace and validate
 	timeStr = strings.TrimSpace(timeStr)
 	if timeStr == "" {
@@ -690,13 +988,13 @@ func parseTimeString(timeStr string) (time.Time, error) {
 // It handles periods that cross midnight by adjusting the times accordingly.
 func IsSleepTime(sleepStartTime, sleepEndTime string, currentTime time.Time) (bool, error) {
 	// Parse start and end times
-	startTime, err := parseTimeString(sleepStartTime)
+	startTime, err := ParseTimeString(sleepStartTime)
 	if err != nil {
 		log.Error("parsing sleep start time:", err)
 		return false, err
 	}
 
-	endTime, err := parseTimeString(sleepEndTime)
+	endTime, err := ParseTimeString(sleepEndTime)
 	if err != nil {
 		log.Error("parsing sleep end time:", err)
 		return false, err
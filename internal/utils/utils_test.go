@@ -1,13 +1,21 @@
 package utils
 
 import (
+	"bytes"
+	"image"
+	"image/color"
 	"math"
 	"net/url"
 	"reflect"
 	"testing"
 	"time"
 
+	"github.com/gen2brain/avif"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/image/bmp"
+
+	"github.com/damongolding/immich-kiosk/internal/kiosk"
 )
 
 // TestCombineQueries test to see if referer queries overwrite url queries
@@ -281,7 +289,7 @@ func TestParseTimeString(t *testing.T) {
 	}
 
 	for _, test := range tests {
-		parsed, err := parseTimeString(test.input)
+		parsed, err := ParseTimeString(test.input)
 		if test.hasError {
 			if err == nil {
 				t.Errorf("Expected error for input %s, but got none", test.input)
@@ -864,3 +872,181 @@ func TestContainsWholeWord(t *testing.T) {
 		})
 	}
 }
+
+func TestEffectiveWeights(t *testing.T) {
+	tests := []struct {
+		name   string
+		assets []AssetWithWeighting
+		want   []float64
+	}{
+		{
+			name: "Shares and weights split the remainder",
+			assets: []AssetWithWeighting{
+				{Asset: WeightedAsset{ID: "A"}, Weight: 30, Share: 25},
+				{Asset: WeightedAsset{ID: "B"}, Weight: 100, Penalty: 1},
+				{Asset: WeightedAsset{ID: "C"}, Weight: 100, Penalty: 1},
+			},
+			want: []float64{25, 37.5, 37.5},
+		},
+		{
+			name: "Shares over 100 leave nothing for weighted buckets",
+			assets: []AssetWithWeighting{
+				{Asset: WeightedAsset{ID: "A"}, Share: 60},
+				{Asset: WeightedAsset{ID: "B"}, Share: 60},
+				{Asset: WeightedAsset{ID: "C"}, Weight: 100},
+			},
+			want: []float64{60, 60, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := effectiveWeights(tt.assets)
+			for i := range tt.want {
+				if math.Abs(got[i]-tt.want[i]) > 0.0001 {
+					t.Errorf("effectiveWeights()[%d] = %f, want %f", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// TestBytesToImageFormats tests originals Kiosk could not previously decode
+func TestBytesToImageFormats(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 16, 8))
+	for x := range 16 {
+		src.Set(x, 4, color.RGBA{R: 255, A: 255})
+	}
+
+	tests := []struct {
+		name     string
+		encode   func(*bytes.Buffer) error
+		wantMime string
+	}{
+		{
+			name:     "bmp",
+			encode:   func(buf *bytes.Buffer) error { return bmp.Encode(buf, src) },
+			wantMime: kiosk.MimeTypeBmp,
+		},
+		{
+			name:     "avif",
+			encode:   func(buf *bytes.Buffer) error { return avif.Encode(buf, src) },
+			wantMime: kiosk.MimeTypeAvif,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, tt.encode(&buf))
+
+			assert.Equal(t, tt.wantMime, ImageMimeType(bytes.NewReader(buf.Bytes())))
+
+			img, mimeType, err := BytesToImage(buf.Bytes(), true)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantMime, mimeType)
+			assert.Equal(t, src.Bounds(), img.Bounds())
+		})
+	}
+}
+
+// TestGenericHEIFBrandsAreNotHEIC tests images with the generic HEIF brands, which AVIF
+// images use too, are not sent to the HEIC decoder
+func TestGenericHEIFBrandsAreNotHEIC(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, avif.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 8, 8))))
+
+	for _, brand := range []string{"mif1", "msf1"} {
+		data := bytes.Clone(buf.Bytes())
+		copy(data[8:12], brand)
+
+		_, format, _ := image.DecodeConfig(bytes.NewReader(data))
+		assert.NotEqual(t, "heic", format, brand)
+	}
+}
+
+// TestBytesToImageInvalid tests undecodable bytes return an error rather than an image
+func TestBytesToImageInvalid(t *testing.T) {
+	img, _, err := BytesToImage([]byte("not an image"), true)
+	assert.Error(t, err)
+	assert.Nil(t, img)
+}
+
+// splitImage returns an image whose left half is left and right half is right.
+func splitImage(width, height int, left, right color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			if x < width/2 {
+				img.SetRGBA(x, y, left)
+			} else {
+				img.SetRGBA(x, y, right)
+			}
+		}
+	}
+	return img
+}
+
+func TestGradientBackground(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+	blue := color.RGBA{B: 255, A: 255}
+
+	// a portrait image on a landscape screen leaves bars beside it
+	portrait := splitImage(300, 400, red, blue)
+
+	from, to, beside := EdgeColors(portrait, 1920, 1080)
+	assert.True(t, beside)
+	assert.Equal(t, red, from)
+	assert.Equal(t, blue, to)
+	assert.Equal(t, "linear-gradient(to right, rgb(255, 0, 0), rgb(0, 0, 255))", GradientBackground(portrait, 1920, 1080))
+
+	// a panorama leaves bars above and below, whose edges are both half red and half blue
+	from, to, beside = EdgeColors(splitImage(800, 100, red, blue), 1920, 1080)
+	assert.False(t, beside)
+	assert.Equal(t, from, to)
+	assert.InDelta(t, 127, int(from.R), 2)
+	assert.InDelta(t, 127, int(from.B), 2)
+}
+
+func TestMirrorImage(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+	blue := color.RGBA{B: 255, A: 255}
+
+	mirrored := MirrorImage(splitImage(300, 400, red, blue), 1920, 1080)
+
+	bounds := mirrored.Bounds()
+	assert.Equal(t, 400, bounds.Dy())
+	assert.InDelta(t, 711, bounds.Dx(), 1, "extended to the client's aspect ratio")
+
+	offset := (bounds.Dx() - 300) / 2
+
+	// the pixels either side of each edge mirror each other
+	assert.Equal(t, color.NRGBAModel.Convert(red), mirrored.At(offset-1, 0))
+	assert.Equal(t, color.NRGBAModel.Convert(blue), mirrored.At(offset+300, 0))
+	assert.Equal(t, color.NRGBAModel.Convert(red), mirrored.At(offset+300+160, 0), "reflects back to the far side")
+}
+
+func TestHideRegions(t *testing.T) {
+	src := splitImage(100, 100, color.RGBA{R: 255, A: 255}, color.RGBA{B: 255, A: 255})
+	face := image.Rect(41, 40, 61, 60)
+
+	assert.Same(t, src, HideRegions(src, nil, false))
+
+	for _, pixelate := range []bool{false, true} {
+		hidden := HideRegions(src, []image.Rectangle{face}, pixelate)
+
+		assert.Equal(t, src.Bounds(), hidden.Bounds())
+		assert.Equal(t, src.At(5, 5), color.RGBAModel.Convert(hidden.At(5, 5)), "pixels away from faces are untouched")
+		assert.NotEqual(t, color.RGBAModel.Convert(src.At(49, 50)), color.RGBAModel.Convert(hidden.At(49, 50)), "faces are hidden, pixelate %v", pixelate)
+	}
+
+	// the source is left untouched
+	assert.Equal(t, color.RGBA{R: 255, A: 255}, src.At(49, 50))
+}
+
+func TestMirrorIndex(t *testing.T) {
+	want := map[int]int{0: 0, 2: 2, 3: 2, 5: 0, 6: 0, -1: 0, -3: 2, -4: 2}
+	for i, expected := range want {
+		assert.Equal(t, expected, mirrorIndex(i, 3), "mirrorIndex(%d, 3)", i)
+	}
+}
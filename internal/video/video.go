@@ -3,6 +3,7 @@ package video
 import (
 	"context"
 	"errors"
+	"image"
 	"os"
 	"path/filepath"
 	"slices"
@@ -14,8 +15,13 @@ import (
 	"github.com/damongolding/immich-kiosk/internal/cache"
 	"github.com/damongolding/immich-kiosk/internal/common"
 	"github.com/damongolding/immich-kiosk/internal/config"
+	"github.com/damongolding/immich-kiosk/internal/diskcache"
+	"github.com/damongolding/immich-kiosk/internal/effects"
+	"github.com/damongolding/immich-kiosk/internal/imagestore"
 	"github.com/damongolding/immich-kiosk/internal/immich"
 	"github.com/damongolding/immich-kiosk/internal/kiosk"
+	"github.com/damongolding/immich-kiosk/internal/processing"
+	"github.com/damongolding/immich-kiosk/internal/thumbhash"
 	"github.com/damongolding/immich-kiosk/internal/utils"
 )
 
@@ -116,6 +122,41 @@ func (v *Manager) RemoveVideo(id string) {
 	}
 }
 
+// Remove deletes a downloaded video
+func (v *Manager) Remove(id string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.RemoveVideo(id)
+}
+
+// CacheEntries describes the downloaded videos as cache entries. Videos expire
+// MaxAge after they were last watched.
+func (v *Manager) CacheEntries() []cache.Entry {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	entries := make([]cache.Entry, 0, len(v.Videos))
+	for _, video := range v.Videos {
+		entry := cache.Entry{
+			Key:      video.ID,
+			Category: cache.CategoryVideo,
+			URL:      "/video/" + video.ID,
+			Expires:  video.LastAccessed.Add(v.MaxAge),
+		}
+
+		entry.TTL = max(int64(time.Until(entry.Expires).Seconds()), 0)
+
+		if info, err := os.Stat(filepath.Join(customTempVideoDir, video.FileName)); err == nil {
+			entry.Size = info.Size()
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
 // cleanup removes videos that have exceeded their maximum age
 func (v *Manager) cleanup() {
 	v.mu.Lock()
@@ -163,7 +204,15 @@ func (v *Manager) GetVideo(id string) (Video, error) {
 }
 
 // AddVideoToViewCache adds a downloaded video to the cache
-func (v *Manager) AddVideoToViewCache(id, fileName, filePath, contentType string, requestConfig *config.Config, deviceID, requestURL string, immichAsset immich.Asset, imageData, imageBlurData string) {
+func (v *Manager) AddVideoToViewCache(id, fileName, filePath, contentType string, requestConfig *config.Config, deviceID, requestURL string, immichAsset immich.Asset, imageData, imageBlurData, imageBackground string) {
+	var placeholder string
+	if immichAsset.Thumbhash != "" {
+		var err error
+		if placeholder, err = thumbhash.DataURL(immichAsset.Thumbhash); err != nil {
+			log.Debug("Invalid thumbhash", "assetID", immichAsset.ID, "err", err)
+		}
+	}
+
 	v.mu.Lock()
 	defer v.mu.Unlock()
 
@@ -181,9 +230,11 @@ func (v *Manager) AddVideoToViewCache(id, fileName, filePath, contentType string
 		Config:   *requestConfig,
 		Assets: []common.ViewImageData{
 			{
-				ImmichAsset:   immichAsset,
-				ImageData:     imageData,
-				ImageBlurData: imageBlurData,
+				ImmichAsset:      immichAsset,
+				ImageData:        imageData,
+				ImageBlurData:    imageBlurData,
+				ImagePlaceholder: placeholder,
+				ImageBackground:  imageBackground,
 			},
 		},
 	}
@@ -256,11 +307,11 @@ func (v *Manager) DownloadVideo(immichAsset immich.Asset, requestConfig config.C
 		return
 	}
 
-	var imageData, imageBlurData string
+	var imageData, imageBlurData, imageBackground string
 
 	defer func() {
 		log.Debug(kiosk.DebugID+" Downloaded video", "path", filePath)
-		v.AddVideoToViewCache(videoID, filename, filePath, contentType, &requestConfig, deviceID, requestURL, immichAsset, imageData, imageBlurData)
+		v.AddVideoToViewCache(videoID, filename, filePath, contentType, &requestConfig, deviceID, requestURL, immichAsset, imageData, imageBlurData, imageBackground)
 	}()
 
 	imgBytes, _, imgBytesErr := immichAsset.ImagePreview()
@@ -269,30 +320,133 @@ func (v *Manager) DownloadVideo(immichAsset immich.Asset, requestConfig config.C
 		return
 	}
 
-	img, _, imgErr := utils.BytesToImage(imgBytes, false)
+	// video previews are prepared in the background, so they never hold up a device
+	img, imgErr := processing.Run(processing.Background, processing.StageDecode, func() (image.Image, error) {
+		img, _, err := utils.BytesToImage(imgBytes, false)
+		return img, err
+	})
 	if imgErr != nil {
 		log.Error("Image BytesToImage", "err", imgErr)
+		return
+	}
+
+	if requestConfig.FacePrivacyKey() != "" {
+		if len(immichAsset.People)+len(immichAsset.UnassignedFaces) == 0 {
+			immichAsset.CheckForFaces(kiosk.DebugID, deviceID)
+		}
+
+		bounds := img.Bounds()
+		regions := immichAsset.PrivateFaceRects(bounds.Dx(), bounds.Dy(), requestConfig.FacePrivacyAllowedPeople)
+		pixelate := requestConfig.FacePrivacy == kiosk.FacePrivacyPixelate
+
+		img, imgErr = processing.Run(processing.Background, processing.StageFacePrivacy, func() (image.Image, error) {
+			return utils.HideRegions(img, regions, pixelate), nil
+		})
+		if imgErr != nil {
+			// never show a preview with faces that should be hidden
+			log.Error("Hiding faces", "err", imgErr)
+			return
+		}
 	}
 
 	if requestConfig.OptimizeImages {
-		img, imgErr = utils.OptimizeImage(img, requestConfig.ClientData.Width, requestConfig.ClientData.Height)
+		width, height := requestConfig.ClientData.Width, requestConfig.ClientData.Height
+		bounds := img.Bounds()
+
+		img, imgErr = diskcache.Image(immichAsset.CacheKey("optimized", bounds.Dx(), bounds.Dy(), width, height, requestConfig.FacePrivacyKey()), func() (image.Image, error) {
+			return processing.Run(processing.Background, processing.StageOptimize, func() (image.Image, error) {
+				return utils.OptimizeImage(img, width, height)
+			})
+		})
 		if imgErr != nil {
 			log.Error("OptimizeImages", "err", imgErr)
+			return
 		}
 	}
 
-	imgBlur, imgBlurErr := utils.BlurImage(img, requestConfig.BackgroundBlurAmount, false, 0, 0)
-	if imgBlurErr != nil {
-		log.Error("Getting image preview", "err", imgBlurErr)
+	if chain, _ := effects.ParseAll(requestConfig.Effects); len(chain) > 0 {
+		bounds := img.Bounds()
+
+		img, imgErr = diskcache.Image(immichAsset.CacheKey("effects", bounds.Dx(), bounds.Dy(), effects.Signature(chain), requestConfig.FacePrivacyKey()), func() (image.Image, error) {
+			return processing.Run(processing.Background, processing.StageEffects, func() (image.Image, error) {
+				return effects.Apply(img, chain), nil
+			})
+		})
+		if imgErr != nil {
+			log.Error("Applying effects", "err", imgErr)
+			return
+		}
 	}
 
-	imageData, imageDataErr := utils.ImageToBase64(img, kiosk.MimeTypeJpeg)
-	if imageDataErr != nil {
-		log.Error("Converting image to base64", "err", imageDataErr)
+	imgBytes, imgBytesErr = processing.Run(processing.Background, processing.StageEncode, func() ([]byte, error) {
+		imgBytes, _, err := utils.EncodeImage(img, kiosk.MimeTypeJpeg, 0)
+		return imgBytes, err
+	})
+	if imgBytesErr != nil {
+		log.Error("Encoding image", "err", imgBytesErr)
+	} else {
+		imageData = imagestore.PutFor(imgBytes, kiosk.MimeTypeJpeg, cache.Expiration(requestConfig.Duration, requestConfig.CacheDuration))
 	}
 
-	imageBlurData, err := utils.ImageToBase64(imgBlur, kiosk.MimeTypeJpeg)
-	if err != nil {
-		log.Error("Converting image to base64", "err", err)
+	imageBlurData, imageBackground = previewBackground(img, immichAsset, requestConfig)
+}
+
+// previewBackground makes the background for a video's preview image, as set by
+// background_style. It returns the URL of a blurred or mirrored background, or the CSS
+// of a colour or gradient background.
+func previewBackground(img image.Image, immichAsset immich.Asset, requestConfig config.Config) (string, string) {
+	bounds := img.Bounds()
+
+	// backgroundImage makes, encodes and stores a background image
+	backgroundImage := func(cacheKey string, stage processing.Stage, makeImage func() (image.Image, error)) string {
+		imgBytes, err := diskcache.Bytes(cacheKey, func() ([]byte, error) {
+			return processing.Run(processing.Background, stage, func() ([]byte, error) {
+				background, err := makeImage()
+				if err != nil {
+					return nil, err
+				}
+
+				imgBytes, _, err := utils.EncodeImage(background, kiosk.MimeTypeJpeg, 0)
+				return imgBytes, err
+			})
+		})
+		if err != nil {
+			log.Error("Making image preview background", "style", requestConfig.BackgroundStyle, "err", err)
+			return ""
+		}
+
+		return imagestore.PutFor(imgBytes, kiosk.MimeTypeJpeg, cache.Expiration(requestConfig.Duration, requestConfig.CacheDuration))
+	}
+
+	switch requestConfig.BackgroundStyle {
+	case kiosk.BackgroundStyleThumbhash:
+		// the thumbhash placeholder is used as the background instead
+		return "", ""
+
+	case kiosk.BackgroundStyleColour:
+		dominantColor, err := utils.ExtractDominantColor(img)
+		if err != nil {
+			log.Error("Extracting image preview colour", "err", err)
+			return "", ""
+		}
+		return "", utils.CSSColor(dominantColor)
+
+	case kiosk.BackgroundStyleGradient:
+		return "", utils.GradientBackground(img, requestConfig.ClientData.Width, requestConfig.ClientData.Height)
+
+	case kiosk.BackgroundStyleMirror:
+		width, height := requestConfig.ClientData.Width, requestConfig.ClientData.Height
+		cacheKey := immichAsset.CacheKey("mirror", bounds.Dx(), bounds.Dy(), width, height, 0, requestConfig.Effects, requestConfig.FacePrivacyKey())
+
+		return backgroundImage(cacheKey, processing.StageBackground, func() (image.Image, error) {
+			return utils.MirrorImage(img, width, height), nil
+		}), ""
+
+	default:
+		cacheKey := immichAsset.CacheKey("blur", bounds.Dx(), bounds.Dy(), requestConfig.BackgroundBlurAmount, false, 0, 0, 0, requestConfig.Effects, requestConfig.FacePrivacyKey())
+
+		return backgroundImage(cacheKey, processing.StageBlur, func() (image.Image, error) {
+			return utils.BlurImage(img, requestConfig.BackgroundBlurAmount, false, 0, 0)
+		}), ""
 	}
 }
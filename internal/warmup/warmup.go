@@ -0,0 +1,265 @@
+// Package warmup remembers the last asset request each device made, so the cache can be
+// warmed for every known device before it wakes, and decides when a warm up is due.
+//
+// Profiles are always tracked in memory. Once Initialize has been called they are also
+// written to disk, shortly after changing, so devices are known straight after a restart.
+package warmup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"charm.land/log/v2"
+	"github.com/robfig/cron/v3"
+
+	"github.com/damongolding/immich-kiosk/internal/utils"
+)
+
+const (
+	// DefaultPath is the file device profiles are persisted to
+	DefaultPath = "./kiosk-data/devices.json"
+
+	// MaxAge is how long a device is remembered after its last request
+	MaxAge = 30 * 24 * time.Hour
+
+	// saveDelay batches changes into a single write
+	saveDelay = 30 * time.Second
+)
+
+// secretFields are form and query values that are never stored
+var secretFields = []string{"password", "authsecret"}
+
+// Profile is the last asset request a device made.
+type Profile struct {
+	DeviceID string     `json:"deviceID"`
+	Method   string     `json:"method"`
+	URL      string     `json:"url"`
+	Form     url.Values `json:"form"`
+	LastSeen time.Time  `json:"lastSeen"`
+}
+
+var (
+	mu       sync.RWMutex
+	profiles = map[string]Profile{}
+
+	path      = DefaultPath
+	persist   = false
+	saveTimer *time.Timer
+)
+
+// Initialize enables persisting profiles to disk and loads any previously persisted
+// profiles from filePath. An empty filePath uses DefaultPath.
+func Initialize(filePath string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if filePath != "" {
+		path = filePath
+	}
+
+	persist = true
+	profiles = map[string]Profile{}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("reading device profiles: %w", err)
+	}
+
+	if err = json.Unmarshal(data, &profiles); err != nil {
+		profiles = map[string]Profile{}
+		return fmt.Errorf("parsing device profiles: %w", err)
+	}
+
+	prune()
+
+	return nil
+}
+
+// Remember records a device's asset request. Passwords and auth secrets are removed
+// from the URL and form first.
+func Remember(deviceID, method string, requestURL url.URL, form url.Values) {
+	if deviceID == "" {
+		return
+	}
+
+	query := requestURL.Query()
+	form = cloneValues(form)
+
+	for _, field := range secretFields {
+		form.Del(field)
+
+		// only re-encode the query when needed, as the URL is part of the view cache key
+		if query.Has(field) {
+			query.Del(field)
+			requestURL.RawQuery = query.Encode()
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	profiles[deviceID] = Profile{
+		DeviceID: deviceID,
+		Method:   method,
+		URL:      requestURL.String(),
+		Form:     form,
+		LastSeen: time.Now(),
+	}
+
+	scheduleSave()
+}
+
+// Profiles returns every known device's profile, ordered by device ID.
+func Profiles() []Profile {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	all := make([]Profile, 0, len(profiles))
+	for _, profile := range profiles {
+		all = append(all, profile)
+	}
+
+	slices.SortFunc(all, func(a, b Profile) int {
+		return strings.Compare(a.DeviceID, b.DeviceID)
+	})
+
+	return all
+}
+
+// Flush writes any pending changes to disk immediately.
+func Flush() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if saveTimer != nil {
+		saveTimer.Stop()
+		saveTimer = nil
+	}
+
+	save()
+}
+
+// ParseSchedule parses a standard five field cron expression, e.g. "50 6 * * *".
+func ParseSchedule(expr string) (cron.Schedule, error) {
+	return cron.ParseStandard(expr)
+}
+
+// Due reports whether schedule fires in the minute starting at now.
+func Due(schedule cron.Schedule, now time.Time) bool {
+	if schedule == nil {
+		return false
+	}
+
+	minute := now.Truncate(time.Minute)
+	return schedule.Next(minute.Add(-time.Second)).Equal(minute)
+}
+
+// DueBeforeSleepEnd reports whether sleepEnd is lead away from the minute starting at now.
+func DueBeforeSleepEnd(sleepEnd string, lead time.Duration, now time.Time) bool {
+	if sleepEnd == "" || lead <= 0 {
+		return false
+	}
+
+	end, err := utils.ParseTimeString(sleepEnd)
+	if err != nil {
+		return false
+	}
+
+	warmAt := time.Date(now.Year(), now.Month(), now.Day(), end.Hour(), end.Minute(), 0, 0, now.Location()).Add(-lead)
+
+	return warmAt.Hour() == now.Hour() && warmAt.Minute() == now.Minute()
+}
+
+// Run calls warm straight away with atStartup set, then at the start of every minute
+// until ctx is done.
+func Run(ctx context.Context, warm func(now time.Time, atStartup bool)) {
+	warm(time.Now(), true)
+
+	for {
+		now := time.Now()
+		next := now.Truncate(time.Minute).Add(time.Minute)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(next.Sub(now)):
+			warm(next, false)
+		}
+	}
+}
+
+// cloneValues returns a copy of values that is never nil.
+func cloneValues(values url.Values) url.Values {
+	clone := make(url.Values, len(values))
+	for key, value := range values {
+		clone[key] = slices.Clone(value)
+	}
+	return clone
+}
+
+// prune removes profiles not seen within MaxAge. mu must be held.
+func prune() {
+	cutoff := time.Now().Add(-MaxAge)
+	for deviceID, profile := range profiles {
+		if profile.LastSeen.Before(cutoff) {
+			delete(profiles, deviceID)
+		}
+	}
+}
+
+// scheduleSave queues a write to disk if one is not already pending. mu must be held.
+func scheduleSave() {
+	if !persist || saveTimer != nil {
+		return
+	}
+
+	saveTimer = time.AfterFunc(saveDelay, func() {
+		mu.Lock()
+		defer mu.Unlock()
+
+		saveTimer = nil
+		save()
+	})
+}
+
+// save writes the profiles to disk. mu must be held.
+func save() {
+	if !persist {
+		return
+	}
+
+	prune()
+
+	data, err := json.Marshal(profiles)
+	if err != nil {
+		log.Error("Failed to marshal device profiles", "err", err)
+		return
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		log.Error("Failed to create device profiles directory", "err", err)
+		return
+	}
+
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0o600); err != nil {
+		log.Error("Failed to write device profiles", "err", err)
+		return
+	}
+
+	if err = os.Rename(tmp, path); err != nil {
+		log.Error("Failed to save device profiles", "err", err)
+	}
+}
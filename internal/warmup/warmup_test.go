@@ -0,0 +1,74 @@
+package warmup
+
+import (
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemember(t *testing.T) {
+	requestURL, err := url.Parse("/asset/new?person=a&password=secret&album=b")
+	require.NoError(t, err)
+
+	Remember("", "POST", *requestURL, nil)
+	Remember("device", "POST", *requestURL, url.Values{"history": {"1"}, "authsecret": {"secret"}})
+
+	profiles := Profiles()
+	require.Len(t, profiles, 1)
+	assert.Equal(t, "device", profiles[0].DeviceID)
+	assert.Equal(t, "/asset/new?album=b&person=a", profiles[0].URL)
+	assert.Equal(t, url.Values{"history": {"1"}}, profiles[0].Form)
+
+	unchanged, err := url.Parse("/asset/new?person=a&album=b")
+	require.NoError(t, err)
+
+	Remember("device", "POST", *unchanged, nil)
+	assert.Equal(t, "/asset/new?person=a&album=b", Profiles()[0].URL, "the query is kept as sent")
+}
+
+func TestDue(t *testing.T) {
+	schedule, err := ParseSchedule("50 6 * * *")
+	require.NoError(t, err)
+
+	day := time.Date(2026, 3, 1, 0, 0, 0, 0, time.Local)
+
+	assert.True(t, Due(schedule, day.Add(6*time.Hour+50*time.Minute)))
+	assert.True(t, Due(schedule, day.Add(6*time.Hour+50*time.Minute+30*time.Second)))
+	assert.False(t, Due(schedule, day.Add(6*time.Hour+51*time.Minute)))
+	assert.False(t, Due(nil, day))
+
+	_, err = ParseSchedule("every morning")
+	require.Error(t, err)
+}
+
+func TestDueBeforeSleepEnd(t *testing.T) {
+	day := time.Date(2026, 3, 1, 0, 0, 0, 0, time.Local)
+
+	assert.True(t, DueBeforeSleepEnd("07:00", 3*time.Minute, day.Add(6*time.Hour+57*time.Minute)))
+	assert.False(t, DueBeforeSleepEnd("07:00", 3*time.Minute, day.Add(7*time.Hour)))
+	assert.True(t, DueBeforeSleepEnd("0002", 5*time.Minute, day.Add(23*time.Hour+57*time.Minute)), "crosses midnight")
+	assert.False(t, DueBeforeSleepEnd("", 3*time.Minute, day))
+	assert.False(t, DueBeforeSleepEnd("07:00", 0, day.Add(7*time.Hour)))
+}
+
+func TestPersistence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "devices.json")
+
+	require.NoError(t, Initialize(file))
+
+	Remember("device", "POST", url.URL{Path: "/asset/new"}, url.Values{"client": {"lounge"}})
+	Flush()
+
+	// reload from disk
+	require.NoError(t, Initialize(file))
+
+	profiles := Profiles()
+	require.Len(t, profiles, 1)
+	assert.Equal(t, "/asset/new", profiles[0].URL)
+	assert.Equal(t, url.Values{"client": {"lounge"}}, profiles[0].Form)
+	assert.WithinDuration(t, time.Now(), profiles[0].LastSeen, time.Minute)
+}
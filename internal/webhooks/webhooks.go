@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"charm.land/log/v2"
+	"github.com/damongolding/immich-kiosk/internal/cache"
 	"github.com/damongolding/immich-kiosk/internal/common"
 	"github.com/damongolding/immich-kiosk/internal/config"
 	"github.com/damongolding/immich-kiosk/internal/immich"
@@ -28,6 +29,8 @@ const (
 	PreviousHistoryAsset WebhookEvent = "asset.history.previous"
 	PrefetchAsset        WebhookEvent = "asset.prefetch"
 	CacheFlush           WebhookEvent = "cache.flush"
+	CacheFlushPartial    WebhookEvent = "cache.flush.partial"
+	CacheFlushChanges    WebhookEvent = "cache.flush.changes"
 
 	// UserInteractionClick          WebhookEvent = "user.interaction.click"
 	UserWebhookTriggerInfoOverlay WebhookEvent = "user.webhook.trigger.info_overlay"
@@ -57,6 +60,8 @@ type Payload struct {
 	Assets     []immich.Asset `json:"assets"`
 	Config     config.Config  `json:"config"`
 	AssetCount int            `json:"assetCount"`
+	// Flushed what was removed from the cache, for cache.flush events
+	Flushed *cache.Report `json:"flushed,omitempty"`
 }
 
 // newHTTPClient creates a new HTTP client with the specified timeout duration.
@@ -77,6 +82,17 @@ func newHTTPClient(timeout time.Duration) *http.Client {
 // event specifies which webhook event (NewAsset, PreviousAsset, etc) triggered this webhook.
 // viewData contains the images and other view context for the current request.
 func Trigger(ctx context.Context, requestData *common.RouteRequestData, kioskVersion string, event WebhookEvent, viewData common.ViewData) {
+	trigger(ctx, requestData, kioskVersion, event, viewData, nil)
+}
+
+// TriggerCacheFlush sends a cache.flush, cache.flush.partial or cache.flush.changes
+// webhook reporting what was removed from the cache.
+func TriggerCacheFlush(ctx context.Context, requestData *common.RouteRequestData, kioskVersion string, event WebhookEvent, flushed cache.Report) {
+	trigger(ctx, requestData, kioskVersion, event, common.ViewData{}, &flushed)
+}
+
+// trigger sends the webhook payload for event to every webhook configured for it.
+func trigger(ctx context.Context, requestData *common.RouteRequestData, kioskVersion string, event WebhookEvent, viewData common.ViewData, flushed *cache.Report) {
 	if viewData.Kiosk.DemoMode {
 		return
 	}
@@ -104,7 +120,7 @@ func Trigger(ctx context.Context, requestData *common.RouteRequestData, kioskVer
 		images := make([]immich.Asset, len(viewData.Assets))
 
 		for i, image := range viewData.Assets {
-			images[i] = image.ImmichAsset
+			images[i] = image.ImmichAsset.Outbound()
 		}
 
 		payload := Payload{
@@ -115,6 +131,7 @@ func Trigger(ctx context.Context, requestData *common.RouteRequestData, kioskVer
 			AssetCount: len(images),
 			Assets:     images,
 			Config:     requestConfig,
+			Flushed:    flushed,
 			Meta: Meta{
 				Source:  "immich-kiosk",
 				Version: kioskVersion,
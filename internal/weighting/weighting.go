@@ -0,0 +1,215 @@
+// Package weighting biases which assets are picked from within a bucket.
+//
+// A Strategy scores each candidate asset and Order returns the candidates in a
+// weighted random order, so higher scoring assets tend to be shown first.
+// Strategies can be combined, in which case their weights are multiplied.
+package weighting
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Strategy names as used in the weighting_strategy option.
+const (
+	StrategyRecency    = "recency"
+	StrategyRating     = "rating"
+	StrategyFavourites = "favourites"
+	StrategyLeastShown = "least-shown"
+)
+
+// Names lists the available strategy names.
+var Names = []string{StrategyRecency, StrategyRating, StrategyFavourites, StrategyLeastShown}
+
+const (
+	// minWeight stops a strategy from ever excluding an asset entirely.
+	minWeight = 0.01
+
+	// leastShownHalfLife is how long it takes a shown asset to regain half of its weight.
+	leastShownHalfLife = 24 * time.Hour
+)
+
+// Candidate holds the asset properties strategies can weight on.
+type Candidate struct {
+	ID        string
+	TakenAt   time.Time
+	Rating    int
+	Favourite bool
+	LastShown time.Time
+}
+
+// Strategy scores a candidate. Higher weights are picked more often.
+type Strategy interface {
+	Weight(c Candidate, now time.Time) float64
+}
+
+// Options configures the strategies returned by New.
+type Options struct {
+	// HalfLife is the age at which the recency strategy halves an asset's weight.
+	HalfLife time.Duration
+	// FavouriteBoost multiplies the weight of favourited assets.
+	FavouriteBoost float64
+}
+
+// Recency prefers recently taken assets. An asset's weight halves every HalfLife.
+type Recency struct {
+	HalfLife time.Duration
+}
+
+func (r Recency) Weight(c Candidate, now time.Time) float64 {
+	if r.HalfLife <= 0 || c.TakenAt.IsZero() {
+		return 1
+	}
+
+	age := max(0, now.Sub(c.TakenAt))
+
+	return max(minWeight, math.Pow(0.5, float64(age)/float64(r.HalfLife)))
+}
+
+// Rating prefers higher rated assets. Each star adds the base weight again,
+// so a five star asset is six times as likely as an unrated one.
+type Rating struct{}
+
+func (Rating) Weight(c Candidate, _ time.Time) float64 {
+	return 1 + float64(max(0, c.Rating))
+}
+
+// Favourites boosts favourited assets.
+type Favourites struct {
+	Boost float64
+}
+
+func (f Favourites) Weight(c Candidate, _ time.Time) float64 {
+	if c.Favourite && f.Boost > 0 {
+		return f.Boost
+	}
+	return 1
+}
+
+// LeastShown prefers assets that have not been shown recently.
+// Assets that have never been shown get the full weight, a shown asset
+// recovers half of its weight every leastShownHalfLife.
+type LeastShown struct{}
+
+func (LeastShown) Weight(c Candidate, now time.Time) float64 {
+	if c.LastShown.IsZero() {
+		return 1
+	}
+
+	since := max(0, now.Sub(c.LastShown))
+
+	return max(minWeight, 1-math.Pow(0.5, float64(since)/float64(leastShownHalfLife)))
+}
+
+// Combined multiplies the weights of its strategies.
+type Combined []Strategy
+
+func (s Combined) Weight(c Candidate, now time.Time) float64 {
+	weight := 1.0
+	for _, strategy := range s {
+		weight *= strategy.Weight(c, now)
+	}
+	return weight
+}
+
+// New returns the strategy for the given names. Unknown names return an error
+// and no names returns nil, meaning assets keep their existing order.
+func New(names []string, opts Options) (Strategy, error) {
+	var strategies Combined
+
+	for _, name := range names {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case StrategyRecency:
+			strategies = append(strategies, Recency{HalfLife: opts.HalfLife})
+		case StrategyRating:
+			strategies = append(strategies, Rating{})
+		case StrategyFavourites, "favorites":
+			strategies = append(strategies, Favourites{Boost: opts.FavouriteBoost})
+		case StrategyLeastShown:
+			strategies = append(strategies, LeastShown{})
+		case "":
+		default:
+			return nil, fmt.Errorf("unknown weighting strategy %q", name)
+		}
+	}
+
+	switch len(strategies) {
+	case 0:
+		return nil, nil
+	case 1:
+		return strategies[0], nil
+	}
+
+	return strategies, nil
+}
+
+// Order returns the indexes of candidates in a weighted random order.
+// Each candidate's chance of coming before another is proportional to its weight.
+// A nil rng uses the global random source.
+func Order(strategy Strategy, candidates []Candidate, now time.Time, rng *rand.Rand) []int {
+	type keyed struct {
+		index int
+		key   float64
+	}
+
+	random := rand.Float64
+	if rng != nil {
+		random = rng.Float64
+	}
+
+	keys := make([]keyed, len(candidates))
+
+	for i, c := range candidates {
+		weight := max(minWeight, strategy.Weight(c, now))
+
+		// Efraimidis-Spirakis: key = u^(1/w), compared in log space
+		u := max(random(), math.SmallestNonzeroFloat64)
+		keys[i] = keyed{index: i, key: math.Log(u) / weight}
+	}
+
+	slices.SortStableFunc(keys, func(a, b keyed) int {
+		return cmp.Compare(b.key, a.key)
+	})
+
+	order := make([]int, len(keys))
+	for i, k := range keys {
+		order[i] = k.index
+	}
+
+	return order
+}
+
+// Pick returns the index of a weighted random item, or -1 if there are no items.
+// A nil rng uses the global random source.
+func Pick(weights []float64, rng *rand.Rand) int {
+	if len(weights) == 0 {
+		return -1
+	}
+
+	total := 0.0
+	for _, w := range weights {
+		total += w
+	}
+
+	random := rand.Float64
+	if rng != nil {
+		random = rng.Float64
+	}
+
+	r := random() * total
+
+	for i, w := range weights {
+		if r < w {
+			return i
+		}
+		r -= w
+	}
+
+	// Should never happen, but keep a safe fallback
+	return 0
+}
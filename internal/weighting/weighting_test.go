@@ -0,0 +1,96 @@
+package weighting
+
+import (
+	"math/rand/v2"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStrategyWeights(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	year := 365 * 24 * time.Hour
+
+	tests := []struct {
+		name      string
+		strategy  Strategy
+		candidate Candidate
+		want      float64
+	}{
+		{name: "Recency new asset", strategy: Recency{HalfLife: year}, candidate: Candidate{TakenAt: now}, want: 1},
+		{name: "Recency one half-life", strategy: Recency{HalfLife: year}, candidate: Candidate{TakenAt: now.Add(-year)}, want: 0.5},
+		{name: "Recency two half-lives", strategy: Recency{HalfLife: year}, candidate: Candidate{TakenAt: now.Add(-2 * year)}, want: 0.25},
+		{name: "Recency floor", strategy: Recency{HalfLife: year}, candidate: Candidate{TakenAt: now.Add(-50 * year)}, want: minWeight},
+		{name: "Recency unknown date", strategy: Recency{HalfLife: year}, candidate: Candidate{}, want: 1},
+		{name: "Rating unrated", strategy: Rating{}, candidate: Candidate{}, want: 1},
+		{name: "Rating five stars", strategy: Rating{}, candidate: Candidate{Rating: 5}, want: 6},
+		{name: "Rating rejected", strategy: Rating{}, candidate: Candidate{Rating: -1}, want: 1},
+		{name: "Favourite", strategy: Favourites{Boost: 3}, candidate: Candidate{Favourite: true}, want: 3},
+		{name: "Not favourite", strategy: Favourites{Boost: 3}, candidate: Candidate{}, want: 1},
+		{name: "Never shown", strategy: LeastShown{}, candidate: Candidate{}, want: 1},
+		{name: "Shown a day ago", strategy: LeastShown{}, candidate: Candidate{LastShown: now.Add(-leastShownHalfLife)}, want: 0.5},
+		{name: "Just shown", strategy: LeastShown{}, candidate: Candidate{LastShown: now}, want: minWeight},
+		{name: "Combined", strategy: Combined{Rating{}, Favourites{Boost: 2}}, candidate: Candidate{Rating: 2, Favourite: true}, want: 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.InDelta(t, tt.want, tt.strategy.Weight(tt.candidate, now), 0.0001)
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	strategy, err := New(nil, Options{})
+	require.NoError(t, err)
+	assert.Nil(t, strategy)
+
+	strategy, err = New([]string{"rating"}, Options{})
+	require.NoError(t, err)
+	assert.Equal(t, Rating{}, strategy)
+
+	strategy, err = New([]string{"Recency", "favorites"}, Options{HalfLife: time.Hour, FavouriteBoost: 2})
+	require.NoError(t, err)
+	assert.Equal(t, Combined{Recency{HalfLife: time.Hour}, Favourites{Boost: 2}}, strategy)
+
+	_, err = New([]string{"popularity"}, Options{})
+	assert.Error(t, err)
+}
+
+func TestOrder(t *testing.T) {
+	candidates := []Candidate{
+		{ID: "unrated"},
+		{ID: "five-stars", Rating: 5},
+	}
+
+	rng := rand.New(rand.NewPCG(1, 2))
+
+	first := make(map[string]int)
+	for range 10_000 {
+		order := Order(Rating{}, candidates, time.Now(), rng)
+		require.Len(t, order, 2)
+		first[candidates[order[0]].ID]++
+	}
+
+	// five stars is 6x the weight so should come first roughly 6 times in 7
+	assert.InDelta(t, 6.0/7.0, float64(first["five-stars"])/10_000, 0.02)
+}
+
+func TestOrderSeeded(t *testing.T) {
+	candidates := []Candidate{{ID: "a"}, {ID: "b", Favourite: true}, {ID: "c"}, {ID: "d"}}
+	strategy := Favourites{Boost: 3}
+
+	a := Order(strategy, candidates, time.Now(), rand.New(rand.NewPCG(42, 42)))
+	b := Order(strategy, candidates, time.Now(), rand.New(rand.NewPCG(42, 42)))
+
+	assert.Equal(t, a, b, "the same seed should give the same order")
+	assert.ElementsMatch(t, []int{0, 1, 2, 3}, a)
+}
+
+func TestPick(t *testing.T) {
+	assert.Equal(t, -1, Pick(nil, nil))
+	assert.Equal(t, 0, Pick([]float64{1}, nil))
+	assert.Equal(t, 1, Pick([]float64{0, 5, 0}, rand.New(rand.NewPCG(7, 7))))
+}
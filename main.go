@@ -29,11 +29,18 @@ import (
 	"github.com/damongolding/immich-kiosk/internal/cache"
 	"github.com/damongolding/immich-kiosk/internal/common"
 	"github.com/damongolding/immich-kiosk/internal/config"
+	"github.com/damongolding/immich-kiosk/internal/deck"
+	"github.com/damongolding/immich-kiosk/internal/diskcache"
 	"github.com/damongolding/immich-kiosk/internal/i18n"
+	"github.com/damongolding/immich-kiosk/internal/imagestore"
 	"github.com/damongolding/immich-kiosk/internal/immich"
+	"github.com/damongolding/immich-kiosk/internal/playback"
+	"github.com/damongolding/immich-kiosk/internal/processing"
 	"github.com/damongolding/immich-kiosk/internal/routes"
+	"github.com/damongolding/immich-kiosk/internal/seen"
 	"github.com/damongolding/immich-kiosk/internal/utils"
 	"github.com/damongolding/immich-kiosk/internal/video"
+	"github.com/damongolding/immich-kiosk/internal/warmup"
 	"github.com/damongolding/immich-kiosk/internal/weather"
 )
 
@@ -106,8 +113,41 @@ func main() {
 		cache.DemoMode = true
 	}
 
+	cache.Backend = cache.BackendType(baseConfig.Kiosk.CacheBackend.Type)
+	cache.MaxMemory = baseConfig.Kiosk.CacheBackend.MaxMemoryBytes
+	cache.Path = baseConfig.Kiosk.CacheBackend.Path
 	cache.Initialize()
 
+	if baseConfig.Kiosk.PersistPlaybackPosition {
+		if playbackErr := playback.Initialize(playback.DefaultPath); playbackErr != nil {
+			log.Error("Failed to load playback positions", "err", playbackErr)
+		}
+	}
+
+	if baseConfig.Kiosk.PersistSeenAssets {
+		if seenErr := seen.Initialize(seen.DefaultPath); seenErr != nil {
+			log.Error("Failed to load seen assets", "err", seenErr)
+		}
+	}
+
+	if baseConfig.Kiosk.PersistDecks {
+		if deckErr := deck.Initialize(deck.DefaultPath); deckErr != nil {
+			log.Error("Failed to load decks", "err", deckErr)
+		}
+	}
+
+	if baseConfig.Kiosk.WarmUp.Enabled {
+		if warmUpErr := warmup.Initialize(warmup.DefaultPath); warmUpErr != nil {
+			log.Error("Failed to load device profiles", "err", warmUpErr)
+		}
+	}
+
+	if diskCacheErr := diskcache.Initialize(diskcache.DefaultPath, baseConfig.Kiosk.DiskCacheSizeBytes); diskCacheErr != nil {
+		log.Error("Failed to initialize image cache", "err", diskCacheErr)
+	}
+
+	processing.Initialize(baseConfig.Kiosk.Processing.Workers, baseConfig.Kiosk.Processing.QueueSize)
+
 	immich.HTTPClient.Timeout = time.Second * time.Duration(baseConfig.Kiosk.HTTPTimeout)
 
 	videoManager, videoManagerErr := video.New(c.Context())
@@ -123,6 +163,14 @@ func main() {
 		baseConfig.WatchConfig(c.Context())
 	}
 
+	if baseConfig.Kiosk.WatchImmichInterval > 0 && !baseConfig.Kiosk.DemoMode {
+		go routes.WatchImmichChanges(c.Context(), baseConfig)
+	}
+
+	if baseConfig.Kiosk.WarmUp.Enabled && !baseConfig.Kiosk.DemoMode {
+		go routes.WarmUpCache(c.Context(), baseConfig)
+	}
+
 	if baseConfig.Kiosk.Debug {
 		log.SetLevel(log.DebugLevel)
 		if baseConfig.Kiosk.DebugVerbose {
@@ -182,6 +230,8 @@ func main() {
 
 	e.GET("/image/:imageID", routes.ImageWithID(baseConfig, c), AssetCacheMiddlewareWithConfig(baseConfig))
 
+	e.GET("/img/:name", routes.StoredImage)
+
 	e.POST("/asset/new", routes.NewAsset(baseConfig, c))
 
 	e.POST("/asset/offline", routes.OfflineMode(baseConfig, c))
@@ -204,6 +254,14 @@ func main() {
 	e.GET("/sleep", routes.Sleep(baseConfig))
 
 	e.GET("/cache/flush", routes.FlushCache(baseConfig, c))
+	e.GET("/cache/entries", routes.CacheEntries(baseConfig))
+	e.GET("/cache/stats", routes.CacheStats(baseConfig))
+	e.POST("/cache/invalidate", routes.InvalidateCache(baseConfig, c))
+
+	e.GET("/processing/stats", routes.ProcessingStats(baseConfig))
+
+	e.POST("/playback/reset", routes.ResetPlayback(baseConfig))
+	e.POST("/playback/seek", routes.SeekPlayback(baseConfig))
 
 	e.POST("/refresh/check", routes.RefreshCheck(baseConfig))
 
@@ -241,6 +299,13 @@ func main() {
 
 	// Shutting down, clean up
 	video.DeleteTmpDir()
+	if cacheErr := cache.Close(); cacheErr != nil {
+		log.Error("Failed to close cache", "err", cacheErr)
+	}
+	seen.Flush()
+	deck.Flush()
+	playback.Flush()
+	warmup.Flush()
 
 	fmt.Println("")
 	if logLevel == log.ErrorLevel || logLevel == log.WarnLevel {
@@ -260,16 +325,19 @@ func addMiddleware(e *echo.Echo, baseConfig *config.Config) {
 	e.Use(middleware.GzipWithConfig(middleware.GzipConfig{
 		Level: 6,
 		Skipper: func(c *echo.Context) bool {
-			return strings.Contains(c.Path(), "image")
+			return strings.Contains(c.Path(), "image") || strings.HasPrefix(c.Path(), imagestore.URLPrefix)
 		},
 	}))
 
 	if baseConfig.Kiosk.Password != "" {
 		e.Use(middleware.KeyAuthWithConfig(middleware.KeyAuthConfig{
 			Skipper: func(c *echo.Context) bool {
-				// skip auth for assets and /health endpoint
+				// skip auth for assets and /health endpoint.
+				// stored images are skipped too, so image URLs do not need the password added.
+				// They are named by the SHA-256 of their content, so their URLs cannot be guessed
+				// and are only known to pages that were served with the password.
 				path := c.Request().URL.Path
-				return strings.HasPrefix(path, "/assets/") || path == "/health" || path == "/favicon.ico"
+				return strings.HasPrefix(path, "/assets/") || strings.HasPrefix(path, imagestore.URLPrefix) || path == "/health" || path == "/favicon.ico"
 			},
 			KeyLookup: "header:Authorization,header:X-Api-Key,query:authsecret,query:password,form:authsecret,form:password",
 			Validator: func(c *echo.Context, key string, _ middleware.ExtractorSource) (bool, error) {